@@ -0,0 +1,208 @@
+package httpx
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLatencyWindow      = 512
+	defaultAdaptiveMultiplier = 3
+	defaultAdaptivePercentile = 0.99
+	defaultAdaptiveMinSamples = 20
+	defaultAdaptiveMinTimeout = 50 * time.Millisecond
+)
+
+// LatencyTracker keeps a sliding window of recent attempt latencies per service.
+// It is safe for concurrent use and meant to be shared by all agents calling
+// the same services.
+type LatencyTracker struct {
+	window int
+
+	mu       sync.Mutex
+	services map[string]*latencyWindow
+}
+
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// NewLatencyTracker returns a tracker keeping the last window samples per
+// service; zero means 512.
+func NewLatencyTracker(window int) *LatencyTracker {
+	if window <= 0 {
+		window = defaultLatencyWindow
+	}
+	return &LatencyTracker{window: window, services: map[string]*latencyWindow{}}
+}
+
+// DefaultLatencyTracker is used by AdaptiveTimeout when no tracker is given.
+var DefaultLatencyTracker = NewLatencyTracker(0)
+
+// Record adds one latency sample for service.
+func (t *LatencyTracker) Record(service string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.services[service]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, t.window)}
+		t.services[service] = w
+	}
+	w.samples[w.next] = latency
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// Percentile returns the latency at quantile q in (0, 1] for service and the
+// number of samples it is based on.
+func (t *LatencyTracker) Percentile(service string, q float64) (time.Duration, int) {
+	t.mu.Lock()
+	w, ok := t.services[service]
+	if !ok {
+		t.mu.Unlock()
+		return 0, 0
+	}
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	samples := append([]time.Duration(nil), w.samples[:n]...)
+	t.mu.Unlock()
+	if n == 0 {
+		return 0, 0
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(math.Ceil(q*float64(n))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	return samples[idx], n
+}
+
+// AdaptiveTimeoutOptions configures per-attempt timeouts derived from observed
+// latency. Each attempt gets Multiplier × the Percentile latency of its
+// service, bounded by the authoritative deadline. Earlier attempts leave
+// enough budget for the remaining retry attempts to run with the same
+// timeout, so the last attempt still has a chance to succeed.
+type AdaptiveTimeoutOptions struct {
+	// Tracker stores the latency samples. The default is DefaultLatencyTracker.
+	Tracker *LatencyTracker
+	// Multiplier is k in k × percentile latency. The default is 3.
+	Multiplier float64
+	// Percentile is the latency quantile. The default is 0.99.
+	Percentile float64
+	// MinSamples is the sample count below which attempts keep the static
+	// deadline. The default is 20.
+	MinSamples int
+	// MinTimeout is the lower bound of the attempt timeout. The default is 50ms.
+	MinTimeout time.Duration
+	// MaxTimeout is the upper bound of the attempt timeout. Zero means no bound.
+	MaxTimeout time.Duration
+}
+
+// AdaptiveTimeoutConfig is the config file shape of AdaptiveTimeoutOptions.
+type AdaptiveTimeoutConfig struct {
+	Multiplier float64       `json:"multiplier" yaml:"multiplier" mapstructure:"multiplier"`
+	Percentile float64       `json:"percentile" yaml:"percentile" mapstructure:"percentile"`
+	MinSamples int           `json:"min_samples" yaml:"min_samples" mapstructure:"min_samples"`
+	MinTimeout time.Duration `json:"min_timeout" yaml:"min_timeout" mapstructure:"min_timeout"`
+	MaxTimeout time.Duration `json:"max_timeout" yaml:"max_timeout" mapstructure:"max_timeout"`
+}
+
+// AdaptiveTimeout enables per-attempt timeouts derived from observed latency.
+// Latency is tracked per Attempt.Service.
+func AdaptiveTimeout(opt AdaptiveTimeoutOptions) AgentOpFunc {
+	return func(agent *Agent) error {
+		if opt.Tracker == nil {
+			opt.Tracker = DefaultLatencyTracker
+		}
+		if opt.Multiplier <= 0 {
+			opt.Multiplier = defaultAdaptiveMultiplier
+		}
+		if opt.Percentile <= 0 || opt.Percentile > 1 {
+			opt.Percentile = defaultAdaptivePercentile
+		}
+		if opt.MinSamples <= 0 {
+			opt.MinSamples = defaultAdaptiveMinSamples
+		}
+		if opt.MinTimeout <= 0 {
+			opt.MinTimeout = defaultAdaptiveMinTimeout
+		}
+		agent.adaptiveTimeout = &opt
+		return nil
+	}
+}
+
+// attemptTimeout returns the timeout of the attempt with 1-based number out
+// of limit attempts, or 0 to keep the authoritative deadline.
+func (o *AdaptiveTimeoutOptions) attemptTimeout(service string, number int, limit int, remaining time.Duration) time.Duration {
+	latency, samples := o.Tracker.Percentile(service, o.Percentile)
+	if samples < o.MinSamples {
+		return 0
+	}
+	target := time.Duration(float64(latency) * o.Multiplier)
+	if target < o.MinTimeout {
+		target = o.MinTimeout
+	}
+	if o.MaxTimeout > 0 && target > o.MaxTimeout {
+		target = o.MaxTimeout
+	}
+	left := limit - number + 1
+	if left < 1 {
+		left = 1
+	}
+	// Leave at least as much budget to every later attempt as this one takes.
+	if share := remaining / time.Duration(left); target > share {
+		target = share
+	}
+	if target >= remaining {
+		return 0
+	}
+	return target
+}
+
+// withAttemptTimeout bounds req by the adaptive attempt timeout. The returned
+// cancel function must be called once the attempt is done.
+func (a *Agent) withAttemptTimeout(req *http.Request, service string) (*http.Request, context.CancelFunc) {
+	if a.adaptiveTimeout == nil {
+		return req, func() {}
+	}
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return req, func() {}
+	}
+	timeout := a.adaptiveTimeout.attemptTimeout(service, a.attempts, a.attemptLimit, time.Until(deadline))
+	if timeout <= 0 {
+		return req, func() {}
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	req = req.WithContext(ctx)
+	req.Header.Set(HeaderRemainingTimeoutMS, strconv.FormatInt(timeout.Milliseconds(), 10))
+	return req, cancel
+}
+
+// recordLatency feeds the tracker with attempts that received a response or
+// ran into their adaptive timeout, so that a slowing service raises its own
+// timeout instead of timing out forever.
+func (a *Agent) recordLatency(attemptCtx context.Context, service string, latency time.Duration, gotResponse bool) {
+	if a.adaptiveTimeout == nil {
+		return
+	}
+	if !gotResponse && (attemptCtx.Err() != context.DeadlineExceeded || a.ctx.Err() != nil) {
+		return
+	}
+	a.adaptiveTimeout.Tracker.Record(service, latency)
+}
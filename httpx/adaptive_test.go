@@ -0,0 +1,125 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTrackerPercentileUsesSlidingWindow(t *testing.T) {
+	tracker := NewLatencyTracker(100)
+	for i := 1; i <= 100; i++ {
+		tracker.Record("inventory", time.Duration(i)*time.Millisecond)
+	}
+	p99, n := tracker.Percentile("inventory", 0.99)
+	require.Equal(t, 99*time.Millisecond, p99)
+	require.Equal(t, 100, n)
+	p50, _ := tracker.Percentile("inventory", 0.5)
+	require.Equal(t, 50*time.Millisecond, p50)
+
+	for i := 0; i < 100; i++ {
+		tracker.Record("inventory", time.Millisecond)
+	}
+	p99, n = tracker.Percentile("inventory", 0.99)
+	require.Equal(t, time.Millisecond, p99, "old samples leave the window")
+	require.Equal(t, 100, n)
+
+	_, n = tracker.Percentile("search", 0.99)
+	require.Zero(t, n)
+}
+
+func TestAdaptiveAttemptTimeout(t *testing.T) {
+	tracker := NewLatencyTracker(0)
+	for i := 0; i < 100; i++ {
+		tracker.Record("inventory", 100*time.Millisecond)
+	}
+	opt := AdaptiveTimeoutOptions{Tracker: tracker, Multiplier: 3, Percentile: 0.99, MinSamples: 20, MinTimeout: 50 * time.Millisecond}
+
+	require.Equal(t, 300*time.Millisecond, opt.attemptTimeout("inventory", 1, 3, 5*time.Second))
+	require.Equal(t, 300*time.Millisecond, opt.attemptTimeout("inventory", 2, 3, 700*time.Millisecond))
+	require.Equal(t, 200*time.Millisecond, opt.attemptTimeout("inventory", 1, 3, 600*time.Millisecond), "a short budget is split evenly")
+	require.Zero(t, opt.attemptTimeout("inventory", 3, 3, 200*time.Millisecond), "the last attempt keeps the deadline")
+	require.Zero(t, opt.attemptTimeout("search", 1, 3, 5*time.Second), "too few samples keep the deadline")
+
+	opt.MaxTimeout = 150 * time.Millisecond
+	require.Equal(t, 150*time.Millisecond, opt.attemptTimeout("inventory", 1, 1, 5*time.Second))
+
+	fast := NewLatencyTracker(0)
+	for i := 0; i < 20; i++ {
+		fast.Record("cache", time.Millisecond)
+	}
+	opt.Tracker = fast
+	require.Equal(t, 50*time.Millisecond, opt.attemptTimeout("cache", 1, 1, 5*time.Second))
+}
+
+func TestAdaptiveTimeoutRetriesSlowAttempt(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		mu      sync.Mutex
+		budgets []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		budgets = append(budgets, r.Header.Get(HeaderRemainingTimeoutMS))
+		mu.Unlock()
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	service := strings.TrimPrefix(server.URL, "http://")
+	tracker := NewLatencyTracker(0)
+	for i := 0; i < 20; i++ {
+		tracker.Record(service, 10*time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := Get(server.URL,
+		Context(ctx),
+		AdaptiveTimeout(AdaptiveTimeoutOptions{Tracker: tracker, MinTimeout: 100 * time.Millisecond}),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	).Do()
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), time.Second)
+	require.EqualValues(t, 2, calls.Load())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, "100", budgets[0], "the downstream sees the attempt budget")
+	require.NotEqual(t, "100", budgets[1], "the last attempt keeps the authoritative deadline")
+
+	_, n := tracker.Percentile(service, 0.99)
+	require.Equal(t, 22, n, "timed out and successful attempts are both recorded")
+}
+
+func TestAdaptiveTimeoutFromProfileConfig(t *testing.T) {
+	profile, err := NewProfileFromConfig(ProfileConfig{
+		Name:            "inventory",
+		BaseURL:         "http://inventory.internal",
+		AdaptiveTimeout: &AdaptiveTimeoutConfig{Multiplier: 4, MinSamples: 50},
+	})
+	require.NoError(t, err)
+
+	agent := profile.Get("/api")
+	require.NoError(t, agent.init())
+	defer agent.cancel()
+	require.Equal(t, 4.0, agent.adaptiveTimeout.Multiplier)
+	require.Equal(t, 50, agent.adaptiveTimeout.MinSamples)
+	require.Equal(t, defaultAdaptivePercentile, agent.adaptiveTimeout.Percentile)
+	require.Same(t, DefaultLatencyTracker, agent.adaptiveTimeout.Tracker)
+}
@@ -8,6 +8,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"reflect"
 	"time"
 
@@ -43,7 +44,18 @@ type Agent struct {
 	retryOpt            *RetryOpt
 	timeoutQuota        time.Duration
 	service             ServiceOptions
+	triedInstances      map[string]struct{}
+	interceptors        []Interceptor
+	signers             []Signer
+	metrics             MetricsHook
+	adaptiveTimeout     *AdaptiveTimeoutOptions
+	attemptLimit        int
+	maxResponseSize     int64
+	decompress          []string
+	fallback            *fallbackOp
+	attempts            int
 	cancel              context.CancelFunc
+	cleanups            []func()
 
 	existedOps []AgentOp
 }
@@ -79,18 +91,13 @@ func (f AgentOpFunc) InitialAgent(agent *Agent) error {
 }
 
 // NewClient returns a net/http client with finite connect timeout and safe pooling defaults.
+// Use ClientOptions to configure proxies, TLS, HTTP/2 or pool sizes.
 func NewClient() *http.Client {
-	dialer := &net.Dialer{
-		Timeout:   defaultConnectTimout,
-		KeepAlive: 30 * time.Second,
+	client, err := ClientOptions{}.Build()
+	if err != nil {
+		panic(err)
 	}
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.DialContext = dialer.DialContext
-	transport.MaxIdleConns = 100
-	transport.MaxIdleConnsPerHost = 100
-	transport.IdleConnTimeout = 90 * time.Second
-	transport.TLSHandshakeTimeout = defaultConnectTimout
-	return &http.Client{Transport: transport}
+	return client
 }
 
 // Do executes the HTTP call.
@@ -102,7 +109,7 @@ func (a *Agent) Do() error {
 		defer a.cancel()
 	}
 	_, err := a.executeHTTP(executeHandle)
-	return err
+	return a.finishWithFallback(err)
 }
 
 // DoStream executes the HTTP call and returns a successful response stream.
@@ -137,6 +144,8 @@ func (a *Agent) init() error {
 	if len(a.expectedStatusCodes) == 0 {
 		a.expectedStatusCodes = append(a.expectedStatusCodes, http.StatusOK)
 	}
+	a.triedInstances = nil
+	a.attempts = 0
 	if a.ctx == nil {
 		a.ctx = context.Background()
 	}
@@ -154,6 +163,11 @@ func (a *Agent) init() error {
 		}
 		a.ctx, a.cancel = contextWithAuthoritativeDeadline(a.ctx, time.Now().Add(timeout))
 	}
+	cancel := a.cancel
+	a.cancel = func() {
+		cancel()
+		a.runCleanups()
+	}
 	if a.client == nil {
 		a.client = DefaultClient
 	}
@@ -163,40 +177,58 @@ func (a *Agent) init() error {
 	return nil
 }
 
-func (a *Agent) prepareRequest() (*http.Request, string, error) {
+// runCleanups releases per-call resources such as request body spill files.
+func (a *Agent) runCleanups() {
+	cleanups := a.cleanups
+	a.cleanups = nil
+	for _, cleanup := range cleanups {
+		cleanup()
+	}
+}
+
+func (a *Agent) prepareRequest(result *attemptResult) (*http.Request, error) {
 	if deadline, ok := a.ctx.Deadline(); ok && time.Until(deadline) <= 0 {
-		return nil, "", ErrTimeoutBudgetExhausted
+		return nil, ErrTimeoutBudgetExhausted
 	}
 	req, err := http.NewRequestWithContext(a.ctx, a.method, a.url, nil)
 	if err != nil {
-		return nil, "", fmt.Errorf("new request failed: %w", err)
+		return nil, fmt.Errorf("new request failed: %w", err)
 	}
 	for _, h := range a.reqPreHandlers {
 		newReq, handleErr := h.PreHandleRequest(req)
 		if handleErr != nil {
-			return nil, "", handleErr
+			return nil, handleErr
 		}
 		if newReq != nil {
 			req = newReq
 		}
 	}
 	ctx, requestID, err := injectTraceHeaders(a.ctx, req)
+	result.requestID = requestID
 	if err != nil {
-		return nil, requestID, err
+		return nil, err
 	}
 	a.ctx = ctx
 	if a.service.EnableDiscovery {
 		traceID := req.Header.Get(HeaderTraceID)
-		resolved, originalHost, err := resolveURL(a.ctx, req.URL, a.service, traceID, requestID)
+		resolved, originalHost, inst, err := resolveURL(a.ctx, req.URL, a.service, traceID, requestID, a.triedInstances)
 		if err != nil {
-			return nil, requestID, err
+			result.discoveryFailed = true
+			return nil, err
 		}
 		req.URL = resolved
 		if originalHost != "" {
 			req.Host = originalHost
 		}
+		result.instance = inst
+		if inst != nil {
+			if a.triedInstances == nil {
+				a.triedInstances = map[string]struct{}{}
+			}
+			a.triedInstances[instanceKey(*inst)] = struct{}{}
+		}
 	}
-	return req, requestID, nil
+	return req, nil
 }
 
 type executeMode int
@@ -208,6 +240,7 @@ const (
 
 func (a *Agent) executeHTTP(mode executeMode) (*http.Response, error) {
 	if a.retryOpt == nil {
+		a.attemptLimit = 1
 		_, resp, err := a.doHTTP(mode)
 		return resp, err
 	}
@@ -222,6 +255,7 @@ func (a *Agent) retryDoHTTP(mode executeMode) (*http.Response, error) {
 	if !a.canRetryMethod() {
 		attempts = 1
 	}
+	a.attemptLimit = attempts
 	var lastErr error
 	for attempt := 1; attempt <= attempts; attempt++ {
 		result, resp, err := a.doHTTP(mode)
@@ -236,6 +270,7 @@ func (a *Agent) retryDoHTTP(mode executeMode) (*http.Response, error) {
 		if deadline, ok := a.ctx.Deadline(); ok && time.Until(deadline) <= delay {
 			return nil, lastErr
 		}
+		a.observe(MetricsRetry, result.statusCode, err, 0)
 		timer := time.NewTimer(delay)
 		select {
 		case <-a.ctx.Done():
@@ -250,11 +285,20 @@ func (a *Agent) retryDoHTTP(mode executeMode) (*http.Response, error) {
 type attemptResult struct {
 	statusCode int
 	requestID  string
+	// instance is the discovery instance chosen for this attempt, if any.
+	instance *Instance
+	// discoveryFailed is set when the attempt failed to resolve an instance.
+	discoveryFailed bool
 }
 
 func (a *Agent) doHTTP(mode executeMode) (result *attemptResult, resp *http.Response, err error) {
-	req, requestID, err := a.prepareRequest()
-	result = &attemptResult{requestID: requestID}
+	result = &attemptResult{}
+	var sentAt time.Time
+	defer func() {
+		a.observeAttempt(result, !sentAt.IsZero(), err, time.Since(sentAt))
+	}()
+	req, err := a.prepareRequest(result)
+	requestID := result.requestID
 	defer func() {
 		if err != nil {
 			err = a.wrapCallError(requestID, err)
@@ -264,8 +308,32 @@ func (a *Agent) doHTTP(mode executeMode) (result *attemptResult, resp *http.Resp
 		return result, nil, err
 	}
 	start := time.Now()
+	if result.instance != nil {
+		done := statsOrDefault(a.service.Stats).Start(*result.instance)
+		defer func() {
+			failed := isInstanceFailure(result.statusCode, err)
+			done(time.Since(start), failed)
+			if a.service.OutlierDetector != nil {
+				a.service.OutlierDetector.Record(*result.instance, failed)
+			}
+		}()
+	}
+	a.attempts++
+	sentAt = time.Now()
 	logging.CtxInfof(a.ctx, "httpx request start method=%s path=%s", req.Method, req.URL.Path)
-	resp, err = a.client.Do(req)
+	service := a.serviceName()
+	a.setAcceptEncoding(req)
+	req, cancelAttempt := a.withAttemptTimeout(req, service)
+	streamed := false
+	defer func() {
+		if !streamed {
+			cancelAttempt()
+		}
+	}()
+	attempt := &Attempt{Request: req, Number: a.attempts, RequestID: requestID, Service: service, Instance: result.instance}
+	resp, err = a.invoke(attempt)
+	a.recordLatency(req.Context(), service, time.Since(sentAt), err == nil)
+	req = attempt.Request
 	if err != nil {
 		cause := fmt.Errorf("request do failed: %w", err)
 		if isRetryableTransportError(err) {
@@ -278,12 +346,19 @@ func (a *Agent) doHTTP(mode executeMode) (result *attemptResult, resp *http.Resp
 	}
 
 	result.statusCode = resp.StatusCode
+	if err = a.limitResponse(resp); err != nil {
+		a.logEnd(req, resp.StatusCode, start, a.wrapCallError(requestID, err))
+		return result, nil, err
+	}
 	if !a.isInExpectedStatusCodes(resp.StatusCode) {
-		body, readErr := io.ReadAll(resp.Body)
+		body, truncated, readErr := readErrorBody(resp.Body)
 		var cause error = datax.NewErrHttp(resp.StatusCode, body)
 		if readErr != nil {
 			cause = fmt.Errorf("%w: read body failed: %v", cause, readErr)
 		}
+		if truncated {
+			cause = &truncatedBodyError{err: cause}
+		}
 		if a.isInRetryStatusCodes(resp.StatusCode) {
 			cause = datax.WithRetryableError(cause)
 		}
@@ -293,12 +368,14 @@ func (a *Agent) doHTTP(mode executeMode) (result *attemptResult, resp *http.Resp
 	}
 	if mode == executeStream {
 		a.logEnd(req, resp.StatusCode, start, nil)
+		streamed = true
+		resp.Body = cancelOnCloseReadCloser{ReadCloser: resp.Body, cancel: cancelAttempt}
 		return result, resp, nil
 	}
 	defer resp.Body.Close()
 	if a.respHandler != nil {
 		if err := a.respHandler.HandleResponse(resp, a.respWrapper); err != nil {
-			if a.retryOpt != nil && a.retryOpt.RetryAppError {
+			if a.retryOpt != nil && a.retryOpt.RetryAppError && !errors.Is(err, ErrResponseTooLarge) {
 				err = datax.WithRetryableError(err)
 			}
 			a.logEnd(req, resp.StatusCode, start, a.wrapCallError(requestID, err))
@@ -320,7 +397,8 @@ func (a *Agent) wrapCallError(requestID string, err error) error {
 	upstreamErr := datax.NewUpstreamError(a.url, a.method, requestID, err)
 	var httpErr *datax.ErrHttp
 	if errors.As(err, &httpErr) {
-		statusErr := &HTTPStatusError{StatusCode: httpErr.StatusCode, ExpectedStatusCodes: a.expectedStatusCodes, Body: httpErr.Body, Cause: err}
+		var truncated *truncatedBodyError
+		statusErr := &HTTPStatusError{StatusCode: httpErr.StatusCode, ExpectedStatusCodes: a.expectedStatusCodes, Body: httpErr.Body, BodyTruncated: errors.As(err, &truncated), Cause: err}
 		return newCallError(a.method, a.url, requestID, httpErr.StatusCode, datax.NewUpstreamError(a.url, a.method, requestID, statusErr))
 	}
 	return newCallError(a.method, a.url, requestID, 0, upstreamErr)
@@ -351,6 +429,17 @@ func (a *Agent) canRetryMethod() bool {
 	}
 }
 
+// serviceName is the discovery service name, or the URL host without discovery.
+func (a *Agent) serviceName() string {
+	if a.service.ServiceName != "" {
+		return a.service.ServiceName
+	}
+	if u, err := url.Parse(a.url); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
 func (a *Agent) shouldRetry(err error, result *attemptResult) bool {
 	return datax.IsRetryableError(err)
 }
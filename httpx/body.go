@@ -0,0 +1,203 @@
+package httpx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+const defaultSpillMemoryLimit = 4 << 20
+
+// ErrBodyReleased is returned when a SpillBody is opened after Close removed
+// its spill file, e.g. when a large ReaderReq body is sent by a second Do.
+var ErrBodyReleased = errors.New("request body spill file was released")
+
+// ReplayableBody produces a fresh request body for every attempt so retries and
+// redirects can resend it.
+type ReplayableBody interface {
+	// Open returns a reader positioned at the start of the body.
+	// Readers returned by earlier calls must not be used after Open is called again.
+	Open() (io.ReadCloser, error)
+	// Size returns the body length, or -1 when it is unknown.
+	Size() int64
+}
+
+// SeekableBody replays a body by seeking back to the position it had when created.
+// The body is never buffered.
+func SeekableBody(body io.ReadSeeker) (ReplayableBody, error) {
+	start, err := body.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("seek request body failed: %w", err)
+	}
+	end, err := body.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, fmt.Errorf("seek request body failed: %w", err)
+	}
+	if _, err := body.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek request body failed: %w", err)
+	}
+	return &seekableBody{body: body, start: start, size: end - start}, nil
+}
+
+type seekableBody struct {
+	body  io.ReadSeeker
+	start int64
+	size  int64
+}
+
+func (b *seekableBody) Open() (io.ReadCloser, error) {
+	if _, err := b.body.Seek(b.start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("rewind request body failed: %w", err)
+	}
+	return io.NopCloser(io.LimitReader(b.body, b.size)), nil
+}
+
+func (b *seekableBody) Size() int64 {
+	return b.size
+}
+
+// FileBody replays a body by reopening the file at path on every attempt.
+func FileBody(path string) (ReplayableBody, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stat request body file failed: %w", err)
+	}
+	return fileBody{path: path, size: info.Size()}, nil
+}
+
+type fileBody struct {
+	path string
+	size int64
+}
+
+func (b fileBody) Open() (io.ReadCloser, error) {
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("open request body file failed: %w", err)
+	}
+	return f, nil
+}
+
+func (b fileBody) Size() int64 {
+	return b.size
+}
+
+// SpillBody replays a one-shot reader. The first Open reads the source, keeping up
+// to the memory limit in memory and spilling the rest to a temporary file.
+// Close removes the temporary file; a body that spilled cannot be opened again
+// and Open returns ErrBodyReleased.
+type SpillBody struct {
+	src      io.Reader
+	memLimit int64
+
+	once sync.Once
+	err  error
+	mem  []byte
+	file *os.File
+	size int64
+}
+
+// NewSpillBody returns a SpillBody over src. A memLimit of zero means 4 MiB.
+func NewSpillBody(src io.Reader, memLimit int64) *SpillBody {
+	if memLimit <= 0 {
+		memLimit = defaultSpillMemoryLimit
+	}
+	return &SpillBody{src: src, memLimit: memLimit, size: -1}
+}
+
+// Open implements ReplayableBody.
+func (b *SpillBody) Open() (io.ReadCloser, error) {
+	b.once.Do(b.fill)
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.file == nil {
+		return io.NopCloser(bytes.NewReader(b.mem)), nil
+	}
+	spilled := io.NewSectionReader(b.file, 0, b.size-int64(len(b.mem)))
+	return io.NopCloser(io.MultiReader(bytes.NewReader(b.mem), spilled)), nil
+}
+
+// Size implements ReplayableBody. It is -1 until the first Open.
+func (b *SpillBody) Size() int64 {
+	return b.size
+}
+
+// Close removes the spill file, if any. Bodies kept in memory stay replayable.
+func (b *SpillBody) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	closeErr := b.file.Close()
+	b.file, b.mem = nil, nil
+	b.err = ErrBodyReleased
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove request body spill file failed: %w", err)
+	}
+	return closeErr
+}
+
+func (b *SpillBody) fill() {
+	if b.src == nil {
+		b.size = 0
+		return
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(b.src, b.memLimit))
+	if err != nil {
+		b.err = fmt.Errorf("read request body failed: %w", err)
+		return
+	}
+	b.mem = buf.Bytes()
+	b.size = n
+	if n < b.memLimit {
+		return
+	}
+	f, err := os.CreateTemp("", "httpx-body-*")
+	if err != nil {
+		b.err = fmt.Errorf("create request body spill file failed: %w", err)
+		return
+	}
+	b.file = f
+	spilled, err := io.Copy(f, b.src)
+	if err != nil {
+		b.err = fmt.Errorf("spill request body failed: %w", err)
+		return
+	}
+	b.size += spilled
+}
+
+// BodyReq sends a replayable body. Every attempt opens a fresh reader and the
+// request GetBody is set so net/http can replay it on redirects.
+func BodyReq(contentType string, body ReplayableBody) AgentOp {
+	return AgentOpFunc(func(agent *Agent) error {
+		agent.reqPreHandlers = append(agent.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
+			return setReplayableBody(req, contentType, body)
+		}))
+		return nil
+	})
+}
+
+func setReplayableBody(req *http.Request, contentType string, body ReplayableBody) (*http.Request, error) {
+	rc, err := body.Open()
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Body = rc
+	req.GetBody = body.Open
+	req.ContentLength = body.Size()
+	if req.ContentLength == 0 {
+		// A zero ContentLength with a non-nil Body means unknown to net/http.
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	}
+	return req, nil
+}
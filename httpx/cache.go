@@ -0,0 +1,547 @@
+package httpx
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/trace/logging"
+)
+
+const (
+	// HeaderCacheStatus reports how the cache answered: HIT, STALE, REVALIDATED or MISS.
+	HeaderCacheStatus = "X-Httpx-Cache"
+
+	// CacheHit means a fresh cached response was served without a request.
+	CacheHit = "HIT"
+	// CacheStale means a stale cached response was served by stale-while-revalidate or stale-if-error.
+	CacheStale = "STALE"
+	// CacheRevalidated means the upstream confirmed the cached response with 304.
+	CacheRevalidated = "REVALIDATED"
+	// CacheMiss means the response came from the upstream.
+	CacheMiss = "MISS"
+
+	defaultCacheMaxBodySize       = 1 << 20
+	defaultCacheValidatorTTL      = 10 * time.Minute
+	defaultCacheRevalidateTimeout = 5 * time.Second
+)
+
+// CachedResponse is a stored response.
+type CachedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	// Vary holds the request values of the headers named by the Vary response header.
+	Vary map[string]string `json:"vary,omitempty"`
+	// StoredAt is when the response was received or last revalidated.
+	StoredAt time.Time `json:"stored_at"`
+	// InitialAge is the Age reported by the upstream when the response was stored.
+	InitialAge time.Duration `json:"initial_age"`
+}
+
+// CacheStore stores cached responses. Entries must be treated as immutable.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*CachedResponse, bool, error)
+	Set(ctx context.Context, key string, entry *CachedResponse, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// CacheOptions configures an HTTPCache.
+type CacheOptions struct {
+	// Store holds the entries. The default is an in-memory LRU of 1024 entries.
+	Store CacheStore
+	// MaxBodySize is the largest body that is cached. The default is 1MiB.
+	MaxBodySize int64
+	// ValidatorTTL keeps expired responses with an ETag or Last-Modified for
+	// conditional revalidation. The default is 10 minutes.
+	ValidatorTTL time.Duration
+	// StaleWhileRevalidate and StaleIfError apply when the response does not carry the directive.
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	// RevalidateTimeout bounds background revalidation. The default is 5 seconds.
+	RevalidateTimeout time.Duration
+	// KeyHeaders are request headers added to the cache key besides the
+	// ofa-pass-* headers and the Authorization header.
+	KeyHeaders []string
+	// KeyFunc returns the identity of the credential req is sent with, e.g.
+	// the client id behind its TokenSource. It is added to the cache key and
+	// is required when the cache runs before the interceptor that authenticates.
+	KeyFunc func(req *http.Request) string
+}
+
+// HTTPCache is a private HTTP cache for GET and HEAD requests honoring
+// Cache-Control, Expires, Age, ETag, Last-Modified and Vary. Cache keys include
+// every ofa-pass-* header except the trace id, a hash of Authorization and
+// the KeyFunc identity, so responses do not leak across tenants, apps,
+// operators or credentials.
+//
+// Install it with CacheResp after TokenAuth, so the key sees the token the
+// request is sent with; signing runs after every interceptor. A cache placed
+// before authentication cannot tell credentials apart unless KeyFunc does.
+// Requests that carry their own conditional headers bypass the cache.
+type HTTPCache struct {
+	opt CacheOptions
+	now func() time.Time
+
+	revalidating sync.Map
+}
+
+// NewHTTPCache returns a cache with opt.
+func NewHTTPCache(opt CacheOptions) *HTTPCache {
+	if opt.Store == nil {
+		opt.Store = NewMemoryCacheStore(1024)
+	}
+	if opt.MaxBodySize <= 0 {
+		opt.MaxBodySize = defaultCacheMaxBodySize
+	}
+	if opt.ValidatorTTL <= 0 {
+		opt.ValidatorTTL = defaultCacheValidatorTTL
+	}
+	if opt.RevalidateTimeout <= 0 {
+		opt.RevalidateTimeout = defaultCacheRevalidateTimeout
+	}
+	return &HTTPCache{opt: opt, now: time.Now}
+}
+
+// CacheResp serves GET and HEAD calls from cache.
+func CacheResp(cache *HTTPCache) AgentOpFunc {
+	return Interceptors(cache)
+}
+
+// Intercept implements Interceptor.
+func (c *HTTPCache) Intercept(attempt *Attempt, next Invoker) (*http.Response, error) {
+	req := attempt.Request
+	if (req.Method != http.MethodGet && req.Method != http.MethodHead) || isConditionalRequest(req) {
+		return next(attempt)
+	}
+	reqCC := parseCacheControl(req.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return next(attempt)
+	}
+	ctx := req.Context()
+	key := c.key(req)
+	entry := c.lookup(ctx, key, req)
+	if entry == nil {
+		resp, err := next(attempt)
+		if err != nil {
+			return resp, err
+		}
+		return c.store(ctx, key, req, resp), nil
+	}
+
+	now := c.now()
+	respCC := parseCacheControl(entry.Header)
+	age := entry.age(now)
+	lifetime := freshnessLifetime(entry.Header, respCC)
+	_, reqNoCache := reqCC["no-cache"]
+	_, respNoCache := respCC["no-cache"]
+	maxAge, hasMaxAge := directiveSeconds(reqCC, "max-age")
+	if !reqNoCache && !respNoCache && age < lifetime && (!hasMaxAge || age <= maxAge) {
+		return cachedResponse(req, entry, now, CacheHit), nil
+	}
+
+	staleness := age - lifetime
+	if !reqNoCache && !respNoCache && staleness < c.staleWindow(respCC, "stale-while-revalidate", c.opt.StaleWhileRevalidate) {
+		c.revalidateInBackground(attempt, next, key, entry)
+		return cachedResponse(req, entry, now, CacheStale), nil
+	}
+
+	conditional := conditionalRequest(req, entry)
+	revalidate := *attempt
+	revalidate.Request = conditional
+	resp, err := next(&revalidate)
+	staleIfError := max(c.staleWindow(respCC, "stale-if-error", c.opt.StaleIfError), c.staleWindow(reqCC, "stale-if-error", 0))
+	if (err != nil || resp.StatusCode >= http.StatusInternalServerError) && staleness < staleIfError {
+		if resp != nil {
+			drainAndClose(resp)
+		}
+		return cachedResponse(req, entry, now, CacheStale), nil
+	}
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusNotModified && conditional != req {
+		drainAndClose(resp)
+		refreshed := c.refresh(ctx, key, entry, resp.Header)
+		return cachedResponse(req, refreshed, c.now(), CacheRevalidated), nil
+	}
+	return c.store(ctx, key, req, resp), nil
+}
+
+func (c *HTTPCache) lookup(ctx context.Context, key string, req *http.Request) *CachedResponse {
+	entry, ok, err := c.opt.Store.Get(ctx, key)
+	if err != nil {
+		logging.CtxWarnf(ctx, "httpx cache get failed method=%s path=%s error=%v", req.Method, req.URL.Path, err)
+		return nil
+	}
+	if !ok || entry == nil {
+		return nil
+	}
+	for name, value := range entry.Vary {
+		if strings.Join(headerValues(req.Header, name), ",") != value {
+			return nil
+		}
+	}
+	return entry
+}
+
+// store caches resp when it is cacheable and returns a response serving the same body.
+func (c *HTTPCache) store(ctx context.Context, key string, req *http.Request, resp *http.Response) *http.Response {
+	respCC := parseCacheControl(resp.Header)
+	if !cacheableStatus(resp.StatusCode) || hasDirective(respCC, "no-store") {
+		return resp
+	}
+	vary, ok := varyValues(req, resp.Header)
+	if !ok {
+		return resp
+	}
+	lifetime := freshnessLifetime(resp.Header, respCC)
+	hasValidators := resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+	if lifetime <= 0 && !hasValidators {
+		return resp
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.opt.MaxBodySize+1))
+	if err != nil || int64(len(body)) > c.opt.MaxBodySize {
+		resp.Body = &prefixedReadCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	initialAge, _ := strconv.Atoi(resp.Header.Get("Age"))
+	entry := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		Vary:       vary,
+		StoredAt:   c.now(),
+		InitialAge: time.Duration(initialAge) * time.Second,
+	}
+	entry.Header.Del(HeaderCacheStatus)
+	c.set(ctx, key, entry, lifetime, respCC, hasValidators)
+	resp.Header.Set(HeaderCacheStatus, CacheMiss)
+	return resp
+}
+
+// refresh merges the 304 headers into entry and stores the result as a new entry.
+func (c *HTTPCache) refresh(ctx context.Context, key string, entry *CachedResponse, header http.Header) *CachedResponse {
+	refreshed := *entry
+	refreshed.Header = entry.Header.Clone()
+	for k, v := range header {
+		if strings.EqualFold(k, "Content-Length") {
+			continue
+		}
+		refreshed.Header[k] = v
+	}
+	initialAge, _ := strconv.Atoi(header.Get("Age"))
+	refreshed.InitialAge = time.Duration(initialAge) * time.Second
+	refreshed.StoredAt = c.now()
+	respCC := parseCacheControl(refreshed.Header)
+	lifetime := freshnessLifetime(refreshed.Header, respCC)
+	c.set(ctx, key, &refreshed, lifetime, respCC, true)
+	return &refreshed
+}
+
+func (c *HTTPCache) set(ctx context.Context, key string, entry *CachedResponse, lifetime time.Duration, respCC map[string]string, hasValidators bool) {
+	ttl := max(lifetime, 0) + max(c.staleWindow(respCC, "stale-while-revalidate", c.opt.StaleWhileRevalidate), c.staleWindow(respCC, "stale-if-error", c.opt.StaleIfError))
+	if hasValidators {
+		ttl = max(ttl, c.opt.ValidatorTTL)
+	}
+	if ttl <= 0 {
+		return
+	}
+	if err := c.opt.Store.Set(ctx, key, entry, ttl); err != nil {
+		logging.CtxWarnf(ctx, "httpx cache set failed error=%v", err)
+	}
+}
+
+// revalidateInBackground refreshes entry once per key. It calls next so the
+// inner interceptors, authentication and signing still apply.
+func (c *HTTPCache) revalidateInBackground(attempt *Attempt, next Invoker, key string, entry *CachedResponse) {
+	if _, running := c.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(attempt.Request.Context()), c.opt.RevalidateTimeout)
+	background := *attempt
+	background.Request = conditionalRequest(attempt.Request.Clone(ctx), entry)
+	go func() {
+		defer cancel()
+		defer c.revalidating.Delete(key)
+		resp, err := next(&background)
+		if err != nil {
+			logging.CtxWarnf(ctx, "httpx cache revalidate failed path=%s error=%v", background.Request.URL.Path, err)
+			return
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			drainAndClose(resp)
+			c.refresh(ctx, key, entry, resp.Header)
+			return
+		}
+		resp = c.store(ctx, key, background.Request, resp)
+		drainAndClose(resp)
+	}()
+}
+
+func (c *HTTPCache) staleWindow(cc map[string]string, directive string, fallback time.Duration) time.Duration {
+	if d, ok := directiveSeconds(cc, directive); ok {
+		return d
+	}
+	return fallback
+}
+
+// key identifies the logical request. After discovery rewrite req.Host keeps the service host.
+func (c *HTTPCache) key(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	parts := []string{req.Method, req.URL.Scheme, host, req.URL.RequestURI()}
+	var names []string
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "ofa-pass-") && lower != strings.ToLower(HeaderTraceID) {
+			names = append(names, lower)
+		}
+	}
+	for _, name := range c.opt.KeyHeaders {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+strings.Join(headerValues(req.Header, name), ","))
+	}
+	if auth := headerValues(req.Header, "Authorization"); len(auth) > 0 {
+		parts = append(parts, "authorization="+sha256Hex([]byte(strings.Join(auth, ","))))
+	}
+	if c.opt.KeyFunc != nil {
+		parts = append(parts, "credential="+c.opt.KeyFunc(req))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *CachedResponse) age(now time.Time) time.Duration {
+	return e.InitialAge + max(now.Sub(e.StoredAt), 0)
+}
+
+func cachedResponse(req *http.Request, entry *CachedResponse, now time.Time, status string) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.Itoa(int(entry.age(now).Seconds())))
+	header.Set(HeaderCacheStatus, status)
+	return &http.Response{
+		Status:        strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// conditionalRequest returns a clone of req with the validators of entry, or req without validators.
+func conditionalRequest(req *http.Request, entry *CachedResponse) *http.Request {
+	etag, lastModified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return req
+	}
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+	return conditional
+}
+
+func isConditionalRequest(req *http.Request) bool {
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range", "Range"} {
+		if len(headerValues(req.Header, name)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheableStatus(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+// varyValues returns the request values of the Vary headers, or false for Vary: *.
+func varyValues(req *http.Request, header http.Header) (map[string]string, bool) {
+	var vary map[string]string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				return nil, false
+			}
+			if vary == nil {
+				vary = map[string]string{}
+			}
+			vary[name] = strings.Join(headerValues(req.Header, name), ",")
+		}
+	}
+	return vary, true
+}
+
+func freshnessLifetime(header http.Header, cc map[string]string) time.Duration {
+	if d, ok := directiveSeconds(cc, "max-age"); ok {
+		return d
+	}
+	expires := header.Get("Expires")
+	if expires == "" {
+		return 0
+	}
+	expiresAt, err := http.ParseTime(expires)
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		date = time.Now()
+	}
+	return expiresAt.Sub(date)
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, value := range headerValues(header, "Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, arg, _ := strings.Cut(part, "=")
+			cc[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+		}
+	}
+	return cc
+}
+
+func hasDirective(cc map[string]string, name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func directiveSeconds(cc map[string]string, name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func drainAndClose(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, defaultCacheMaxBodySize))
+	_ = resp.Body.Close()
+}
+
+type prefixedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// MemoryCacheStore is an in-memory LRU CacheStore.
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	now        func() time.Time
+}
+
+type memoryCacheItem struct {
+	key      string
+	entry    *CachedResponse
+	expireAt time.Time
+}
+
+// NewMemoryCacheStore returns an LRU store holding at most maxEntries entries.
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = 1024
+	}
+	return &MemoryCacheStore{maxEntries: maxEntries, entries: map[string]*list.Element{}, lru: list.New(), now: time.Now}
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(_ context.Context, key string) (*CachedResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	item := elem.Value.(*memoryCacheItem)
+	if !s.now().Before(item.expireAt) {
+		s.remove(elem)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(elem)
+	return item.entry, true, nil
+}
+
+// Set implements CacheStore.
+func (s *MemoryCacheStore) Set(_ context.Context, key string, entry *CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := &memoryCacheItem{key: key, entry: entry, expireAt: s.now().Add(ttl)}
+	if elem, ok := s.entries[key]; ok {
+		elem.Value = item
+		s.lru.MoveToFront(elem)
+		return nil
+	}
+	s.entries[key] = s.lru.PushFront(item)
+	for s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Delete implements CacheStore.
+func (s *MemoryCacheStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries, including expired ones not yet evicted.
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+func (s *MemoryCacheStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryCacheItem).key)
+}
@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const defaultRedisCacheKeyPrefix = "httpx:cache:"
+
+// RedisCacheStore is a CacheStore sharing entries through Redis, e.g. with the
+// client used by dkit/redis.
+type RedisCacheStore struct {
+	cli    goredis.UniversalClient
+	prefix string
+}
+
+// NewRedisCacheStore returns a Redis store. An empty prefix uses "httpx:cache:".
+func NewRedisCacheStore(cli goredis.UniversalClient, prefix string) *RedisCacheStore {
+	if prefix == "" {
+		prefix = defaultRedisCacheKeyPrefix
+	}
+	return &RedisCacheStore{cli: cli, prefix: prefix}
+}
+
+// Get implements CacheStore.
+func (s *RedisCacheStore) Get(ctx context.Context, key string) (*CachedResponse, bool, error) {
+	content, err := s.cli.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("redis get cache entry failed: %w", err)
+	}
+	var entry CachedResponse
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false, fmt.Errorf("decode cache entry failed: %w", err)
+	}
+	return &entry, true, nil
+}
+
+// Set implements CacheStore.
+func (s *RedisCacheStore) Set(ctx context.Context, key string, entry *CachedResponse, ttl time.Duration) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode cache entry failed: %w", err)
+	}
+	if err := s.cli.Set(ctx, s.prefix+key, content, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set cache entry failed: %w", err)
+	}
+	return nil
+}
+
+// Delete implements CacheStore.
+func (s *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	if err := s.cli.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("redis delete cache entry failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dev-ofa/core-go/pass"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCache(opt CacheOptions) (*HTTPCache, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	store := NewMemoryCacheStore(16)
+	store.now = clock.Now
+	if opt.Store == nil {
+		opt.Store = store
+	}
+	cache := NewHTTPCache(opt)
+	cache.now = clock.Now
+	return cache, clock
+}
+
+func cachedGet(t *testing.T, ctx context.Context, cache *HTTPCache, url string, ops ...AgentOp) (string, string) {
+	t.Helper()
+	var (
+		resp http.Response
+		body []byte
+	)
+	ops = append([]AgentOp{Context(ctx), CacheResp(cache), RawResp(&resp, &body)}, ops...)
+	require.NoError(t, Get(url, ops...).Do())
+	return string(body), resp.Header.Get(HeaderCacheStatus)
+}
+
+func TestCacheFreshnessAndValidators(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("fresh"))
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			_, _ = w.Write([]byte("tagged"))
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+			_, _ = w.Write([]byte("private"))
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			_, _ = w.Write([]byte(r.Header.Get("Accept-Language")))
+		}
+	}))
+	defer server.Close()
+	cache, clock := newTestCache(CacheOptions{})
+	ctx := context.Background()
+
+	body, status := cachedGet(t, ctx, cache, server.URL+"/fresh")
+	require.Equal(t, "fresh", body)
+	require.Equal(t, CacheMiss, status)
+	body, status = cachedGet(t, ctx, cache, server.URL+"/fresh")
+	require.Equal(t, "fresh", body)
+	require.Equal(t, CacheHit, status)
+	require.Equal(t, int32(1), calls.Load())
+	clock.Advance(61 * time.Second)
+	_, status = cachedGet(t, ctx, cache, server.URL+"/fresh")
+	require.Equal(t, CacheMiss, status, "expired without validators")
+
+	calls.Store(0)
+	cachedGet(t, ctx, cache, server.URL+"/etag")
+	body, status = cachedGet(t, ctx, cache, server.URL+"/etag")
+	require.Equal(t, "tagged", body)
+	require.Equal(t, CacheRevalidated, status)
+	require.Equal(t, int32(2), calls.Load())
+
+	calls.Store(0)
+	cachedGet(t, ctx, cache, server.URL+"/no-store")
+	_, status = cachedGet(t, ctx, cache, server.URL+"/no-store")
+	require.Empty(t, status)
+	require.Equal(t, int32(2), calls.Load())
+
+	body, _ = cachedGet(t, ctx, cache, server.URL+"/vary", SetHeader(http.Header{"Accept-Language": {"en"}}))
+	require.Equal(t, "en", body)
+	body, status = cachedGet(t, ctx, cache, server.URL+"/vary", SetHeader(http.Header{"Accept-Language": {"fr"}}))
+	require.Equal(t, "fr", body)
+	require.Equal(t, CacheMiss, status)
+}
+
+func TestCacheKeysAreTenantSafe(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get(HeaderTenantID)))
+	}))
+	defer server.Close()
+	cache, _ := newTestCache(CacheOptions{})
+
+	for _, tenant := range []string{"t1", "t2", "t1", "t2"} {
+		body, _ := cachedGet(t, pass.CtxSetTenantID(context.Background(), tenant), cache, server.URL)
+		require.Equal(t, tenant, body)
+	}
+	require.Equal(t, int32(2), calls.Load(), "trace ids differ per call but tenants share their own entry")
+}
+
+type cacheCredentialKey struct{}
+
+func TestCacheKeysSeparateCredentialsOfOneTenant(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	ctx := pass.CtxSetTenantID(context.Background(), "t1")
+	sources := map[string]TokenSource{}
+	for _, client := range []string{"alice", "bob"} {
+		sources[client] = TokenSourceFunc(func(context.Context) (*Token, error) {
+			return &Token{AccessToken: client}, nil
+		})
+	}
+	get := func(t *testing.T, ctx context.Context, ops ...AgentOp) string {
+		t.Helper()
+		var body []byte
+		ops = append([]AgentOp{Context(ctx), RawResp(&http.Response{}, &body)}, ops...)
+		require.NoError(t, Get(server.URL, ops...).Do())
+		return string(body)
+	}
+
+	t.Run("cache inside token auth", func(t *testing.T) {
+		calls.Store(0)
+		cache, _ := newTestCache(CacheOptions{})
+		for _, client := range []string{"alice", "bob", "alice", "bob"} {
+			body := get(t, ctx, TokenAuth(sources[client]), CacheResp(cache))
+			require.Equal(t, "Bearer "+client, body)
+		}
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("cache before token auth with KeyFunc", func(t *testing.T) {
+		calls.Store(0)
+		cache, _ := newTestCache(CacheOptions{KeyFunc: func(req *http.Request) string {
+			client, _ := req.Context().Value(cacheCredentialKey{}).(string)
+			return client
+		}})
+		for _, client := range []string{"alice", "bob", "alice", "bob"} {
+			body := get(t, context.WithValue(ctx, cacheCredentialKey{}, client), CacheResp(cache), TokenAuth(sources[client]))
+			require.Equal(t, "Bearer "+client, body)
+		}
+		require.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestCacheStaleWhileRevalidateAndStaleIfError(t *testing.T) {
+	var (
+		calls   atomic.Int32
+		failing atomic.Bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=10, stale-while-revalidate=30, stale-if-error=300")
+		_, _ = w.Write([]byte{byte('0' + n)})
+	}))
+	defer server.Close()
+	cache, clock := newTestCache(CacheOptions{})
+	ctx := context.Background()
+
+	body, _ := cachedGet(t, ctx, cache, server.URL)
+	require.Equal(t, "1", body)
+
+	clock.Advance(20 * time.Second)
+	body, status := cachedGet(t, ctx, cache, server.URL)
+	require.Equal(t, "1", body)
+	require.Equal(t, CacheStale, status)
+	require.Eventually(t, func() bool {
+		body, status := cachedGet(t, ctx, cache, server.URL)
+		return body == "2" && status == CacheHit
+	}, time.Second, 5*time.Millisecond, "background revalidation refreshes the entry")
+
+	failing.Store(true)
+	clock.Advance(60 * time.Second)
+	body, status = cachedGet(t, ctx, cache, server.URL)
+	require.Equal(t, "2", body)
+	require.Equal(t, CacheStale, status, "served stale on upstream error")
+
+	clock.Advance(time.Hour)
+	err := Get(server.URL, CacheResp(cache)).Do()
+	require.Error(t, err, "beyond stale-if-error the failure surfaces")
+}
+
+func TestRedisCacheStore(t *testing.T) {
+	srv, err := miniredis.Run()
+	require.NoError(t, err)
+	defer srv.Close()
+	cli := goredis.NewClient(&goredis.Options{Addr: srv.Addr()})
+	defer cli.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = w.Write([]byte("shared"))
+	}))
+	defer server.Close()
+
+	store := NewRedisCacheStore(cli, "")
+	first := NewHTTPCache(CacheOptions{Store: store})
+	second := NewHTTPCache(CacheOptions{Store: store})
+	ctx := context.Background()
+	cachedGet(t, ctx, first, server.URL)
+	body, status := cachedGet(t, ctx, second, server.URL)
+	require.Equal(t, "shared", body)
+	require.Equal(t, CacheHit, status, "entries are shared through redis")
+	require.Len(t, srv.Keys(), 1)
+	require.Greater(t, srv.TTL(srv.Keys()[0]), time.Duration(0))
+}
+
+func TestMemoryCacheStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCacheStore(2)
+	for _, key := range []string{"a", "b"} {
+		require.NoError(t, store.Set(ctx, key, &CachedResponse{}, time.Minute))
+	}
+	_, ok, _ := store.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, store.Set(ctx, "c", &CachedResponse{}, time.Minute))
+	_, ok, _ = store.Get(ctx, "b")
+	require.False(t, ok)
+	_, ok, _ = store.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, 2, store.Len())
+}
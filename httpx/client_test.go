@@ -0,0 +1,292 @@
+package httpx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "httpx test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, serial int64, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func newMTLSServer(t *testing.T, ca *testCA, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, 100, "server", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}, ClientCAs: pool, ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	return server
+}
+
+func TestClientOptionsMTLSReloadsRotatedCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	server := newMTLSServer(t, ca, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].SerialNumber.String()))
+	})
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writePair := func(serial int64, modTime time.Time) {
+		certPEM, keyPEM := ca.issue(t, serial, "client", x509.ExtKeyUsageClientAuth)
+		require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+		require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+		require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+		require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+	}
+	writePair(7, time.Now().Add(-time.Minute))
+
+	client, err := ClientOptions{TLS: &TLSOptions{
+		CAPEM:              ca.pem,
+		DisableSystemCAs:   true,
+		CertFile:           certFile,
+		KeyFile:            keyFile,
+		CertReloadInterval: time.Millisecond,
+	}}.Build()
+	require.NoError(t, err)
+
+	var serial []byte
+	require.NoError(t, Get(server.URL, Client(client), RawResp(nil, &serial)).Do())
+	require.Equal(t, "7", string(serial))
+
+	writePair(8, time.Now())
+	time.Sleep(5 * time.Millisecond)
+	client.CloseIdleConnections()
+	require.NoError(t, Get(server.URL, Client(client), RawResp(nil, &serial)).Do())
+	require.Equal(t, "8", string(serial))
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	require.NoError(t, os.Chtimes(keyFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	time.Sleep(5 * time.Millisecond)
+	client.CloseIdleConnections()
+	require.NoError(t, Get(server.URL, Client(client), RawResp(nil, &serial)).Do())
+	require.Equal(t, "8", string(serial), "a broken rotation keeps the last good certificate")
+}
+
+func TestClientOptionsTLSVerification(t *testing.T) {
+	ca := newTestCA(t)
+	server := newMTLSServer(t, ca, func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+
+	t.Run("rejects an untrusted server", func(t *testing.T) {
+		client, err := ClientOptions{TLS: &TLSOptions{DisableSystemCAs: true}}.Build()
+		require.NoError(t, err)
+		require.Error(t, Get(server.URL, Client(client)).Do())
+	})
+
+	t.Run("enforces the minimum version", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+		server.StartTLS()
+		defer server.Close()
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+
+		client, err := ClientOptions{TLS: &TLSOptions{MinVersion: tls.VersionTLS13}}.Build()
+		require.NoError(t, err)
+		client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+		require.ErrorContains(t, Get(server.URL, Client(client)).Do(), "protocol version")
+	})
+
+	t.Run("validates the certificate pair", func(t *testing.T) {
+		_, err := ClientOptions{TLS: &TLSOptions{CertFile: "client.crt"}}.Build()
+		require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+		_, err = ClientOptions{TLS: &TLSOptions{CAPEM: []byte("not a pem")}}.Build()
+		require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+	})
+}
+
+func TestClientOptionsHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	for _, tc := range []struct {
+		name  string
+		http2 HTTP2Options
+		proto string
+	}{
+		{name: "negotiated by default", proto: "HTTP/2.0"},
+		{name: "disabled", http2: HTTP2Options{Disable: true}, proto: "HTTP/1.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := ClientOptions{TLS: &TLSOptions{}, HTTP2: tc.http2}.Build()
+			require.NoError(t, err)
+			client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+
+			var proto []byte
+			require.NoError(t, Get(server.URL, Client(client), RawResp(nil, &proto)).Do())
+			require.Equal(t, tc.proto, string(proto))
+		})
+	}
+}
+
+func TestClientOptionsProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		_, _ = w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+	direct := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer direct.Close()
+
+	client, err := ClientOptions{Proxy: &ProxyOptions{URL: proxy.URL, NoProxy: []string{"127.0.0.1"}}}.Build()
+	require.NoError(t, err)
+
+	var body []byte
+	require.NoError(t, Get("http://inventory.internal/api", Client(client), RawResp(nil, &body)).Do())
+	require.Equal(t, "via proxy", string(body))
+	require.Equal(t, []string{"http://inventory.internal/api"}, proxied)
+
+	require.NoError(t, Get(direct.URL, Client(client), RawResp(nil, &body)).Do())
+	require.Equal(t, "direct", string(body))
+
+	_, err = ClientOptions{Proxy: &ProxyOptions{URL: "ftp://proxy:21"}}.Build()
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestNoProxyRules(t *testing.T) {
+	rules := parseNoProxy([]string{"example.com, .internal", "10.0.0.0/8", "192.168.1.1", "api.test:8443"})
+	for target, want := range map[string]bool{
+		"http://example.com":          true,
+		"https://a.example.com":       true,
+		"http://notexample.com":       false,
+		"http://svc.internal":         true,
+		"http://internal":             false,
+		"http://10.1.2.3:8080":        true,
+		"http://11.0.0.1":             false,
+		"http://192.168.1.1:9000":     true,
+		"https://api.test:8443/v1":    true,
+		"https://api.test/v1":         false,
+		"socks5://elsewhere.org:1080": false,
+	} {
+		u, err := url.Parse(target)
+		require.NoError(t, err)
+		require.Equal(t, want, rules.match(u), target)
+	}
+	require.True(t, parseNoProxy([]string{"*"}).match(&url.URL{Scheme: "http", Host: "any"}))
+}
+
+func TestClientOptionsHostPools(t *testing.T) {
+	client, err := ClientOptions{
+		Pool:      PoolOptions{MaxConnsPerHost: 10},
+		HostPools: map[string]PoolOptions{"Search.internal": {MaxConnsPerHost: 2, MaxIdleConnsPerHost: 2}},
+	}.Build()
+	require.NoError(t, err)
+
+	transport := client.Transport.(*hostPoolTransport)
+	require.Equal(t, 10, transport.base.MaxConnsPerHost)
+	require.Equal(t, defaultMaxIdleConnsPerHost, transport.base.MaxIdleConnsPerHost)
+	search := transport.hosts["search.internal"]
+	require.Equal(t, 2, search.MaxConnsPerHost)
+	require.Equal(t, 2, search.MaxIdleConnsPerHost)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	client, err = ClientOptions{HostPools: map[string]PoolOptions{server.Listener.Addr().String(): {MaxConnsPerHost: 1}}}.Build()
+	require.NoError(t, err)
+	require.NoError(t, Get(server.URL, Client(client)).Do())
+	require.NoError(t, Get(server.URL, Client(client)).Do())
+	client.CloseIdleConnections()
+}
+
+func TestNewClientFromConfig(t *testing.T) {
+	client, err := NewClientFromConfig(ClientConfig{
+		ConnectTimeout: time.Second,
+		Pool:           PoolConfig{MaxIdleConnsPerHost: 8},
+		TLS:            &TLSConfig{MinVersion: "1.3"},
+		HTTP2:          HTTP2Config{Disable: true},
+	})
+	require.NoError(t, err)
+	transport := client.Transport.(*http.Transport)
+	require.Equal(t, 8, transport.MaxIdleConnsPerHost)
+	require.Equal(t, time.Second, transport.TLSHandshakeTimeout)
+	require.Equal(t, uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+	require.False(t, transport.Protocols.HTTP2())
+
+	_, err = NewClientFromConfig(ClientConfig{TLS: &TLSConfig{MinVersion: "1.4"}})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	profile, err := NewProfileFromConfig(ProfileConfig{
+		Name:   "search",
+		Client: &ClientConfig{Proxy: &ProxyConfig{URL: "socks5://proxy.internal:1080"}},
+	})
+	require.NoError(t, err)
+	proxyURL, err := profile.Client.Transport.(*http.Transport).Proxy(httptest.NewRequest(http.MethodGet, "http://search.internal", nil))
+	require.NoError(t, err)
+	require.Equal(t, "socks5://proxy.internal:1080", proxyURL.String())
+}
@@ -0,0 +1,54 @@
+// Command httpx-openapi-gen generates a typed httpx client from an OpenAPI 3 document.
+//
+// Usage with go generate:
+//
+//	//go:generate go run github.com/dev-ofa/core-go/httpx/cmd/httpx-openapi-gen -spec api.yaml -package api -out client_gen.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dev-ofa/core-go/httpx/openapi"
+)
+
+func main() {
+	var (
+		spec          = flag.String("spec", "", "OpenAPI 3 document in YAML or JSON")
+		pkg           = flag.String("package", os.Getenv("GOPACKAGE"), "Go package name, defaults to $GOPACKAGE")
+		out           = flag.String("out", "", "output file, stdout when empty")
+		client        = flag.String("client", "Client", "generated client type name")
+		commonWrapper = flag.Bool("common-wrapper", false, "decode responses from the CommonWrapper data field")
+	)
+	flag.Parse()
+	if err := run(*spec, *pkg, *out, *client, *commonWrapper); err != nil {
+		fmt.Fprintln(os.Stderr, "httpx-openapi-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(spec string, pkg string, out string, client string, commonWrapper bool) error {
+	if spec == "" {
+		return fmt.Errorf("-spec is required")
+	}
+	doc, err := openapi.Load(spec)
+	if err != nil {
+		return err
+	}
+	code, err := openapi.Generate(doc, openapi.Options{
+		Package:       pkg,
+		ClientName:    client,
+		CommonWrapper: commonWrapper,
+		Source:        filepath.Base(spec),
+	})
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return os.WriteFile(out, code, 0o644)
+}
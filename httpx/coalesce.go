@@ -0,0 +1,155 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dev-ofa/core-go/pass"
+)
+
+// Coalescer deduplicates identical in-flight GET and HEAD attempts. The first
+// attempt goes to the network and every identical attempt waiting meanwhile
+// receives its own copy of the response, so each caller runs its own status
+// checks and decoding. The key is the method, the logical URL, the tenant from
+// pass, a hash of Authorization and the configured key headers.
+//
+// Install it after TokenAuth so the key sees the token the request is sent
+// with. Placed before authentication it shares responses across credentials.
+//
+// Every caller waits with its own context. The shared request is canceled
+// only when all of its callers have gone. Shared response bodies are buffered
+// in memory, so do not coalesce large downloads.
+type Coalescer struct {
+	keyHeaders []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// NewCoalescer returns a coalescer adding keyHeaders to the request key.
+func NewCoalescer(keyHeaders ...string) *Coalescer {
+	return &Coalescer{keyHeaders: keyHeaders, calls: map[string]*coalescedCall{}}
+}
+
+// Coalesce shares identical in-flight reads through c. Use one Coalescer per
+// downstream, e.g. on Profile.Interceptors after the token auth interceptor,
+// so calls from different agents meet.
+func Coalesce(c *Coalescer) AgentOpFunc {
+	return Interceptors(c)
+}
+
+// Intercept implements Interceptor.
+func (c *Coalescer) Intercept(attempt *Attempt, next Invoker) (*http.Response, error) {
+	req := attempt.Request
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return next(attempt)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		return next(attempt)
+	}
+	key := c.key(req)
+
+	c.mu.Lock()
+	call, ok := c.calls[key]
+	if ok {
+		call.waiters++
+	} else {
+		ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+		call = &coalescedCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.calls[key] = call
+		shared := *attempt
+		shared.Request = req.Clone(ctx)
+		go c.do(key, call, &shared, next)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return copyCoalescedResponse(req, call), nil
+	case <-req.Context().Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Callers arriving now must not join a canceled call.
+			if c.calls[key] == call {
+				delete(c.calls, key)
+			}
+		}
+		c.mu.Unlock()
+		return nil, req.Context().Err()
+	}
+}
+
+func (c *Coalescer) do(key string, call *coalescedCall, attempt *Attempt, next Invoker) {
+	defer call.cancel()
+	resp, err := next(attempt)
+	if err == nil {
+		call.body, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			err = fmt.Errorf("read coalesced response failed: %w", err)
+		}
+	}
+	call.resp, call.err = resp, err
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+func (c *Coalescer) key(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	parts := []string{req.Method, req.URL.Scheme, host, req.URL.RequestURI()}
+	tenantID, _ := pass.CtxGetTenantID(req.Context())
+	if tenantID == "" {
+		tenantID = strings.Join(headerValues(req.Header, HeaderTenantID), ",")
+	}
+	parts = append(parts, "tenant="+tenantID)
+	names := make([]string, 0, len(c.keyHeaders))
+	for _, name := range c.keyHeaders {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+strings.Join(headerValues(req.Header, name), ","))
+	}
+	if auth := headerValues(req.Header, "Authorization"); len(auth) > 0 {
+		parts = append(parts, "authorization="+sha256Hex([]byte(strings.Join(auth, ","))))
+	}
+	return sha256Hex([]byte(strings.Join(parts, "\n")))
+}
+
+func copyCoalescedResponse(req *http.Request, call *coalescedCall) *http.Response {
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Trailer = call.resp.Trailer.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.ContentLength = int64(len(call.body))
+	resp.Request = req
+	return &resp
+}
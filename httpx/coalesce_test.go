@@ -0,0 +1,166 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescerSharesInFlightReads(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = fmt.Fprintf(w, `{"tenant":%q,"items":[1,2]}`, r.Header.Get(HeaderTenantID))
+	}))
+	defer server.Close()
+	coalescer := NewCoalescer("X-Version")
+
+	type result struct {
+		Tenant string `json:"tenant"`
+		Items  []int  `json:"items"`
+	}
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []*result
+	)
+	call := func(tenant string) {
+		defer wg.Done()
+		var out result
+		ctx := pass.CtxSetTenantID(context.Background(), tenant)
+		require.NoError(t, Get(server.URL+"/items", Context(ctx), Coalesce(coalescer), JSONResp(&out)).Do())
+		mu.Lock()
+		results = append(results, &out)
+		mu.Unlock()
+	}
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go call("t1")
+		go call("t2")
+	}
+
+	canceled := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(pass.CtxSetTenantID(context.Background(), "t1"), 20*time.Millisecond)
+		defer cancel()
+		canceled <- Get(server.URL+"/items", Context(ctx), Coalesce(coalescer)).Do()
+	}()
+	require.Error(t, <-canceled, "a caller's own context still applies while waiting")
+
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(2), calls.Load(), "one network call per tenant")
+	require.Len(t, results, 40)
+	results[0].Items[0] = 99
+	for _, r := range results[1:] {
+		require.Equal(t, []int{1, 2}, r.Items, "every caller decodes its own copy")
+	}
+
+	require.NoError(t, Get(server.URL+"/items", Coalesce(coalescer), SetHeader(http.Header{"X-Version": {"2"}})).Do())
+	require.Equal(t, int32(3), calls.Load(), "later calls are not coalesced with finished ones")
+}
+
+func TestCoalescerSeparatesCredentialsOfOneTenant(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+	coalescer := NewCoalescer()
+	ctx := pass.CtxSetTenantID(context.Background(), "t1")
+
+	var wg sync.WaitGroup
+	for _, client := range []string{"alice", "bob"} {
+		source := TokenSourceFunc(func(context.Context) (*Token, error) {
+			return &Token{AccessToken: client}, nil
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var body []byte
+			require.NoError(t, Get(server.URL, Context(ctx), TokenAuth(source), Coalesce(coalescer),
+				RawResp(&http.Response{}, &body)).Do())
+			require.Equal(t, "Bearer "+client, string(body))
+		}()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 2 }, time.Second, 5*time.Millisecond,
+		"each credential sends its own request")
+	releaseOnce.Do(func() { close(release) })
+	wg.Wait()
+}
+
+func TestCoalescerCancelsSharedRequestWhenAllCallersLeave(t *testing.T) {
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer server.Close()
+	coalescer := NewCoalescer()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Error(t, Get(server.URL, Context(ctx), Coalesce(coalescer)).Do())
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("shared request was not canceled")
+	}
+}
+
+func TestCoalescerDoesNotJoinCanceledCall(t *testing.T) {
+	var calls atomic.Int32
+	unblock := make(chan struct{})
+	next := func(attempt *Attempt) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			// The first shared request returns late, after its callers left.
+			<-attempt.Request.Context().Done()
+			<-unblock
+			return nil, attempt.Request.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	}
+	coalescer := NewCoalescer()
+	newAttempt := func(ctx context.Context) *Attempt {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://svc/items", nil)
+		require.NoError(t, err)
+		return &Attempt{Request: req}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+		cancel()
+	}()
+	_, err := coalescer.Intercept(newAttempt(ctx), next)
+	require.ErrorIs(t, err, context.Canceled)
+
+	live, liveCancel := context.WithTimeout(context.Background(), time.Second)
+	defer liveCancel()
+	resp, err := coalescer.Intercept(newAttempt(live), next)
+	require.NoError(t, err, "a caller arriving after every earlier waiter left starts a new call")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(2), calls.Load())
+
+	close(unblock)
+	require.Eventually(t, func() bool {
+		coalescer.mu.Lock()
+		defer coalescer.mu.Unlock()
+		return len(coalescer.calls) == 0
+	}, time.Second, time.Millisecond, "the finished old call does not linger or remove newer calls")
+}
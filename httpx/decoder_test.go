@@ -0,0 +1,147 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestXMLAndProtoResp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/xml":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(`<item><name>widget</name><count>3</count></item>`))
+		case "/proto":
+			require.Equal(t, "application/x-protobuf", r.Header.Get("Accept"))
+			body, err := proto.Marshal(wrapperspb.String("hello"))
+			require.NoError(t, err)
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	var item struct {
+		Name  string `xml:"name"`
+		Count int    `xml:"count"`
+	}
+	require.NoError(t, Get(server.URL+"/xml", XMLResp(&item)).Do())
+	require.Equal(t, "widget", item.Name)
+	require.Equal(t, 3, item.Count)
+
+	msg := &wrapperspb.StringValue{}
+	require.NoError(t, Get(server.URL+"/proto", ProtoResp(msg)).Do())
+	require.Equal(t, "hello", msg.GetValue())
+
+	var notPtr struct{}
+	require.Error(t, Get(server.URL+"/xml", XMLResp(notPtr)).Do())
+	var nilMsg *wrapperspb.StringValue
+	require.Error(t, Get(server.URL+"/proto", ProtoResp(nilMsg)).Do())
+}
+
+func TestNDJSONIter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		_, _ = w.Write([]byte("{\"id\":1}\n\n{\"id\":2}\n{\"id\":"))
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL).DoStream()
+	require.NoError(t, err)
+	it := NewNDJSONIter[struct {
+		ID int `json:"id"`
+	}](resp)
+	defer it.Close()
+
+	var ids []int
+	for it.Next() {
+		ids = append(ids, it.Item().ID)
+	}
+	require.Equal(t, []int{1, 2}, ids)
+	require.Error(t, it.Err())
+	require.False(t, it.Next())
+}
+
+func TestSSEStreamReconnectsWithLastEventID(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+		traceIDs    []string
+		lastIDs     []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		conn := connections
+		traceIDs = append(traceIDs, r.Header.Get(HeaderTraceID))
+		lastIDs = append(lastIDs, r.Header.Get(HeaderLastEventID))
+		mu.Unlock()
+		require.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		require.NotEmpty(t, r.Header.Get(HeaderRemainingTimeoutMS))
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch conn {
+		case 1:
+			_, _ = fmt.Fprint(w, ": keep-alive\r\nretry: 5\r\nid: 1\r\nevent: update\r\ndata: first\r\ndata: line\r\n\r\n")
+			_, _ = fmt.Fprint(w, "data: dropped because the event is incomplete")
+		default:
+			_, _ = fmt.Fprint(w, "id: 2\ndata:second\n\ndata\n\n")
+		}
+	}))
+	defer server.Close()
+
+	stream := NewSSEStream(context.Background(), server.URL, SSEOptions{MaxReconnects: 1, Timeout: 5 * time.Second})
+	defer stream.Close()
+
+	var events []SSEEvent
+	for stream.Next() {
+		events = append(events, stream.Event())
+	}
+	require.NoError(t, stream.Err())
+	require.Equal(t, []SSEEvent{
+		{ID: "1", Event: "update", Data: "first\nline"},
+		{ID: "2", Event: "message", Data: "second"},
+		{ID: "2", Event: "message", Data: ""},
+	}, events)
+	require.Equal(t, "2", stream.LastEventID())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 2, connections)
+	require.Equal(t, []string{"", "1"}, lastIDs)
+	require.NotEmpty(t, traceIDs[0])
+	require.Equal(t, traceIDs[0], traceIDs[1])
+}
+
+func TestSSEStreamStopsOnBudgetAndContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("retry: 10000\ndata: once\n\n"))
+	}))
+	defer server.Close()
+
+	stream := NewSSEStream(context.Background(), server.URL, SSEOptions{Timeout: time.Second})
+	defer stream.Close()
+	require.True(t, stream.Next())
+	require.False(t, stream.Next())
+	require.ErrorIs(t, stream.Err(), ErrTimeoutBudgetExhausted)
+
+	stream = NewSSEStream(context.Background(), server.URL+"/json", SSEOptions{})
+	defer stream.Close()
+	require.False(t, stream.Next())
+	require.True(t, strings.Contains(stream.Err().Error(), "unexpected event stream content type"))
+}
@@ -56,6 +56,11 @@ type ServiceOptions struct {
 	Picker InstancePicker
 	// InstanceOverride bypasses resolver output for explicit debug scopes.
 	InstanceOverride *Instance
+	// Stats receives per-instance attempt results. The default is DefaultInstanceStats.
+	// Stats-aware pickers must read from the same tracker.
+	Stats *InstanceStats
+	// OutlierDetector ejects instances that keep failing. Nil disables ejection.
+	OutlierDetector *OutlierDetector
 }
 
 // ResolveRequest is the caller-facing discovery request.
@@ -125,30 +130,55 @@ type RandomPicker struct{}
 
 // Pick implements InstancePicker.
 func (RandomPicker) Pick(_ context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
-	if resp == nil || len(resp.Instances) == 0 {
+	candidates := filterCandidates(req, resp)
+	if len(candidates) == 0 {
 		return nil, ErrNoHealthyInstance
 	}
+	selected := candidates[weightedRandomIndex(candidates)]
+	return &selected, nil
+}
+
+// filterCandidates drops non-callable instances unless ResolveModeAll is requested,
+// then narrows the result by PreferredZone and PreferredLabelSelector. Each soft
+// preference is skipped when it would leave no candidate.
+func filterCandidates(req ResolveRequest, resp *ResolveResponse) []Instance {
+	if resp == nil || len(resp.Instances) == 0 {
+		return nil
+	}
 	candidates := make([]Instance, 0, len(resp.Instances))
 	for _, inst := range resp.Instances {
-		if req.ResolveMode != ResolveModeAll && inst.HealthStatus != "" && inst.HealthStatus != HealthStatusHealthy {
-			continue
-		}
-		if req.PreferredZone != "" && inst.Zone != "" && inst.Zone != req.PreferredZone {
+		if req.ResolveMode != ResolveModeAll && !isCallable(inst) {
 			continue
 		}
 		candidates = append(candidates, inst)
 	}
-	if len(candidates) == 0 && req.PreferredZone != "" {
-		for _, inst := range resp.Instances {
-			if req.ResolveMode != ResolveModeAll && inst.HealthStatus != "" && inst.HealthStatus != HealthStatusHealthy {
-				continue
-			}
-			candidates = append(candidates, inst)
+	if req.PreferredZone != "" {
+		candidates = preferInstances(candidates, func(inst Instance) bool {
+			return inst.Zone == "" || inst.Zone == req.PreferredZone
+		})
+	}
+	if len(req.PreferredLabelSelector) > 0 {
+		candidates = preferInstances(candidates, func(inst Instance) bool {
+			return matchLabels(inst.Labels, req.PreferredLabelSelector)
+		})
+	}
+	return candidates
+}
+
+func preferInstances(candidates []Instance, preferred func(Instance) bool) []Instance {
+	ret := make([]Instance, 0, len(candidates))
+	for _, inst := range candidates {
+		if preferred(inst) {
+			ret = append(ret, inst)
 		}
 	}
-	if len(candidates) == 0 {
-		return nil, ErrNoHealthyInstance
+	if len(ret) == 0 {
+		return candidates
 	}
+	return ret
+}
+
+func weightedRandomIndex(candidates []Instance) int {
 	total := 0
 	for _, inst := range candidates {
 		if inst.Weight > 0 {
@@ -156,34 +186,34 @@ func (RandomPicker) Pick(_ context.Context, req ResolveRequest, resp *ResolveRes
 		}
 	}
 	if total <= 0 {
-		selected := candidates[rand.Intn(len(candidates))] // #nosec G404: load balancing does not require crypto randomness.
-		return &selected, nil
+		return rand.Intn(len(candidates)) // #nosec G404: load balancing does not require crypto randomness.
 	}
 	pick := rand.Intn(total) // #nosec G404: load balancing does not require crypto randomness.
-	for _, inst := range candidates {
+	for i, inst := range candidates {
 		if inst.Weight <= 0 {
 			continue
 		}
 		pick -= inst.Weight
 		if pick < 0 {
-			selected := inst
-			return &selected, nil
+			return i
 		}
 	}
-	selected := candidates[len(candidates)-1]
-	return &selected, nil
+	return len(candidates) - 1
 }
 
-func resolveURL(ctx context.Context, original *url.URL, opt ServiceOptions, traceID string, requestID string) (*url.URL, string, error) {
+// resolveURL picks an instance for original. Instances in tried, which holds the
+// instance keys of earlier attempts of the same call, are avoided when others remain.
+func resolveURL(ctx context.Context, original *url.URL, opt ServiceOptions, traceID string, requestID string, tried map[string]struct{}) (*url.URL, string, *Instance, error) {
 	if !opt.EnableDiscovery {
-		return original, "", nil
+		return original, "", nil, nil
 	}
 	if opt.InstanceOverride != nil {
-		u := rewriteURLToInstance(original, *opt.InstanceOverride)
-		return u, original.Host, nil
+		inst := *opt.InstanceOverride
+		u := rewriteURLToInstance(original, inst)
+		return u, original.Host, &inst, nil
 	}
 	if opt.Resolver == nil {
-		return nil, "", ErrServiceDiscoveryDisabled
+		return nil, "", nil, ErrServiceDiscoveryDisabled
 	}
 	serviceName := opt.ServiceName
 	namespace := opt.Namespace
@@ -191,7 +221,7 @@ func resolveURL(ctx context.Context, original *url.URL, opt ServiceOptions, trac
 		serviceName, namespace = parseServiceIdentifier(original.Hostname(), namespace)
 	}
 	if serviceName == "" || namespace == "" {
-		return nil, "", datax.NewValidationError("service discovery requires service name and namespace", nil, nil)
+		return nil, "", nil, datax.NewValidationError("service discovery requires service name and namespace", nil, nil)
 	}
 	mode := opt.ResolveMode
 	if mode == "" {
@@ -209,18 +239,40 @@ func resolveURL(ctx context.Context, original *url.URL, opt ServiceOptions, trac
 	}
 	resp, err := opt.Resolver.Resolve(ctx, req)
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
+	resp = narrowResolveResponse(resp, opt.OutlierDetector, tried)
 	picker := opt.Picker
 	if picker == nil {
 		picker = RandomPicker{}
 	}
 	inst, err := picker.Pick(ctx, req, resp)
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
 	u := rewriteURLToInstance(original, *inst)
-	return u, original.Host, nil
+	return u, original.Host, inst, nil
+}
+
+// narrowResolveResponse drops ejected and already tried instances from a copy of resp.
+// Tried instances are only dropped when another instance remains.
+func narrowResolveResponse(resp *ResolveResponse, detector *OutlierDetector, tried map[string]struct{}) *ResolveResponse {
+	if resp == nil || (detector == nil && len(tried) == 0) {
+		return resp
+	}
+	instances := resp.Instances
+	if detector != nil {
+		instances = detector.Filter(instances)
+	}
+	if len(tried) > 0 {
+		instances = preferInstances(instances, func(inst Instance) bool {
+			_, ok := tried[instanceKey(inst)]
+			return !ok
+		})
+	}
+	narrowed := *resp
+	narrowed.Instances = instances
+	return &narrowed
 }
 
 func parseServiceIdentifier(host string, namespace string) (string, string) {
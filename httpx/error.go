@@ -12,6 +12,10 @@ const (
 	ErrCodeHTTPTimeoutBudgetExhausted = 10110
 	// ErrCodeHTTPNoHealthyInstance means service discovery returned no callable healthy instance.
 	ErrCodeHTTPNoHealthyInstance = 10111
+	// ErrCodeHTTPServiceNotFound means the resolver has no registration for the requested service.
+	ErrCodeHTTPServiceNotFound = 10112
+	// ErrCodeHTTPResponseTooLarge means a response body exceeded the configured size limit.
+	ErrCodeHTTPResponseTooLarge = 10114
 	// ErrCodeHTTPServiceDiscoveryDisabled means a discovery-only option was used without a resolver.
 	ErrCodeHTTPServiceDiscoveryDisabled = 20110
 	// ErrCodeHTTPWrapperDefault is used when a wrapper error does not carry an application code.
@@ -23,6 +27,10 @@ var (
 	ErrTimeoutBudgetExhausted = datax.NewError(ErrCodeHTTPTimeoutBudgetExhausted, "httpx: timeout budget exhausted", nil)
 	// ErrNoHealthyInstance means service discovery returned no callable healthy instance.
 	ErrNoHealthyInstance = datax.NewError(ErrCodeHTTPNoHealthyInstance, "httpx: no healthy service instance", nil)
+	// ErrServiceNotFound means the resolver has no registration for the requested service.
+	ErrServiceNotFound = datax.NewError(ErrCodeHTTPServiceNotFound, "httpx: service not found", nil)
+	// ErrResponseTooLarge means a response body exceeded the configured size limit.
+	ErrResponseTooLarge = datax.NewError(ErrCodeHTTPResponseTooLarge, "httpx: response body too large", nil)
 	// ErrServiceDiscoveryDisabled means a discovery-only option was used without a resolver.
 	ErrServiceDiscoveryDisabled = datax.NewError(ErrCodeHTTPServiceDiscoveryDisabled, "httpx: service discovery is disabled", nil)
 )
@@ -35,6 +43,8 @@ type HTTPStatusError struct {
 	ExpectedStatusCodes []int
 	// Body contains the response body read from the failed response.
 	Body []byte
+	// BodyTruncated reports that Body was cut at the response size limit.
+	BodyTruncated bool
 	// ReadBodyErr stores the error raised while reading the failed response body.
 	ReadBodyErr error
 	// Cause stores the normalized HTTP error used by the new error chain.
@@ -0,0 +1,229 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/dev-ofa/core-go/trace/logging"
+)
+
+// FallbackPredicate decides whether the final error of a call may be replaced
+// by a fallback result.
+type FallbackPredicate func(err error) bool
+
+// FallbackFunc produces a degraded result for a failed call, e.g. by filling
+// the value passed to JSONResp with defaults. It receives the final call error
+// and a context that is no longer bound to the call deadline. A returned error
+// keeps the original call error.
+type FallbackFunc func(ctx context.Context, err error) error
+
+// FallbackOnRetryable matches errors marked with datax.WithRetryableError,
+// e.g. transport failures and retryable status codes.
+func FallbackOnRetryable(err error) bool {
+	return datax.IsRetryableError(err)
+}
+
+// FallbackOnBudgetExhausted matches calls whose timeout budget was spent.
+func FallbackOnBudgetExhausted(err error) bool {
+	return errors.Is(err, ErrTimeoutBudgetExhausted)
+}
+
+// FallbackOnServerError matches unexpected 5xx status codes.
+func FallbackOnServerError(err error) bool {
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= http.StatusInternalServerError
+}
+
+// DefaultFallbackPredicates are used when a fallback option has no predicate.
+var DefaultFallbackPredicates = []FallbackPredicate{
+	FallbackOnRetryable,
+	FallbackOnBudgetExhausted,
+	FallbackOnServerError,
+}
+
+type fallbackOp struct {
+	when []FallbackPredicate
+	run  FallbackFunc
+	// succeeded is called after a successful call, e.g. to store a last-good value.
+	succeeded func(ctx context.Context)
+}
+
+// Fallback runs fn when the final error of Do matches one of when, or
+// DefaultFallbackPredicates without predicates. Non-retryable 4xx status
+// errors and canceled calls never fall back, whatever the predicates say.
+// A call served by fn returns nil and is marked degraded, see IsDegraded.
+// DoStream does not use fallbacks.
+func Fallback(fn FallbackFunc, when ...FallbackPredicate) AgentOpFunc {
+	return func(agent *Agent) error {
+		if fn == nil {
+			return datax.NewValidationError("fallback func is required", nil, nil)
+		}
+		agent.fallback = &fallbackOp{when: when, run: fn}
+		return nil
+	}
+}
+
+// FallbackLastGood stores every successfully decoded *target in store and
+// copies the last good value back into *target when the call fails as
+// described by Fallback. Entries are keyed by key and the tenant from pass,
+// so tenants never see each other's data; an empty key uses the method and URL.
+// T is copied shallowly, so treat stored values as immutable.
+func FallbackLastGood[T any](store *LastGood[T], key string, target *T, when ...FallbackPredicate) AgentOpFunc {
+	return func(agent *Agent) error {
+		if store == nil || target == nil {
+			return datax.NewValidationError("last-good fallback requires a store and a target", nil, nil)
+		}
+		storeKey := func(ctx context.Context) string {
+			k := key
+			if k == "" {
+				k = agent.method + " " + agent.url
+			}
+			tenantID, _ := pass.CtxGetTenantID(ctx)
+			return tenantID + "\n" + k
+		}
+		agent.fallback = &fallbackOp{
+			when: when,
+			run: func(ctx context.Context, err error) error {
+				value, ok := store.Get(storeKey(ctx))
+				if !ok {
+					return errors.New("no last-good value")
+				}
+				*target = value
+				return nil
+			},
+			succeeded: func(ctx context.Context) {
+				store.Set(storeKey(ctx), *target)
+			},
+		}
+		return nil
+	}
+}
+
+func (f *fallbackOp) matches(ctx context.Context, err error) bool {
+	if errors.Is(err, context.Canceled) && ctx.Err() == context.Canceled {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && !datax.IsRetryableError(err) {
+		return false
+	}
+	when := f.when
+	if len(when) == 0 {
+		when = DefaultFallbackPredicates
+	}
+	for _, predicate := range when {
+		if predicate(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// finishWithFallback applies the fallback of the agent to the result of Do.
+func (a *Agent) finishWithFallback(err error) error {
+	if a.fallback == nil {
+		return err
+	}
+	if err == nil {
+		if a.fallback.succeeded != nil {
+			a.fallback.succeeded(a.ctx)
+		}
+		return nil
+	}
+	if !a.fallback.matches(a.ctx, err) {
+		return err
+	}
+	if fallbackErr := a.fallback.run(context.WithoutCancel(a.ctx), err); fallbackErr != nil {
+		logging.CtxWarnf(a.ctx, "httpx fallback unavailable method=%s url=%s fallback_error=%v error=%v", a.method, a.url, fallbackErr, err)
+		return err
+	}
+	markDegraded(a.ctx, err)
+	logging.CtxWarnf(a.ctx, "httpx call degraded method=%s url=%s error=%v", a.method, a.url, err)
+	return nil
+}
+
+// LastGood keeps the last successfully decoded value per key for FallbackLastGood.
+// It is safe for concurrent use.
+type LastGood[T any] struct {
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	values map[string]lastGoodValue[T]
+}
+
+type lastGoodValue[T any] struct {
+	value    T
+	storedAt time.Time
+}
+
+// NewLastGood returns a store whose values expire after maxAge; zero keeps them forever.
+func NewLastGood[T any](maxAge time.Duration) *LastGood[T] {
+	return &LastGood[T]{maxAge: maxAge, now: time.Now, values: map[string]lastGoodValue[T]{}}
+}
+
+// Get returns the value stored under key if it has not expired.
+func (s *LastGood[T]) Get(key string) (T, bool) {
+	s.mu.RLock()
+	entry, ok := s.values[key]
+	s.mu.RUnlock()
+	if !ok || (s.maxAge > 0 && s.now().Sub(entry.storedAt) > s.maxAge) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Set stores value under key.
+func (s *LastGood[T]) Set(key string, value T) {
+	s.mu.Lock()
+	s.values[key] = lastGoodValue[T]{value: value, storedAt: s.now()}
+	s.mu.Unlock()
+}
+
+type degradationKey struct{}
+
+// Degradation collects the calls served by a fallback within a context.
+type Degradation struct {
+	mu     sync.Mutex
+	causes []error
+}
+
+// WithDegradation returns a context in which calls served by a fallback are
+// recorded, e.g. once per inbound request so that the handler can flag its
+// own response as degraded.
+func WithDegradation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, degradationKey{}, &Degradation{})
+}
+
+// IsDegraded reports whether a call in ctx was served by a fallback. ctx must
+// come from WithDegradation.
+func IsDegraded(ctx context.Context) bool {
+	return len(DegradedCauses(ctx)) > 0
+}
+
+// DegradedCauses returns the call errors replaced by fallbacks in ctx.
+func DegradedCauses(ctx context.Context) []error {
+	d, ok := ctx.Value(degradationKey{}).(*Degradation)
+	if !ok {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]error(nil), d.causes...)
+}
+
+func markDegraded(ctx context.Context, cause error) {
+	d, ok := ctx.Value(degradationKey{}).(*Degradation)
+	if !ok {
+		return
+	}
+	d.mu.Lock()
+	d.causes = append(d.causes, cause)
+	d.mu.Unlock()
+}
@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defaults := func(out *[]string) FallbackFunc {
+		return func(ctx context.Context, err error) error {
+			*out = []string{"default"}
+			return nil
+		}
+	}
+
+	t.Run("serves retryable failures and marks the context degraded", func(t *testing.T) {
+		ctx := WithDegradation(context.Background())
+		var out []string
+		err := Get(server.URL+"/unavailable", Context(ctx), JSONResp(&out), Fallback(defaults(&out))).Do()
+		require.NoError(t, err)
+		require.Equal(t, []string{"default"}, out)
+		require.True(t, IsDegraded(ctx))
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, DegradedCauses(ctx)[0], &statusErr)
+		require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	})
+
+	t.Run("never hides non-retryable 4xx errors", func(t *testing.T) {
+		ctx := WithDegradation(context.Background())
+		var out []string
+		always := func(error) bool { return true }
+		err := Get(server.URL+"/missing", Context(ctx), JSONResp(&out), Fallback(defaults(&out), always)).Do()
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+		require.Nil(t, out)
+		require.False(t, IsDegraded(ctx))
+	})
+
+	t.Run("serves exhausted budgets", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+		var out []string
+		err := Get(server.URL, Context(ctx), Fallback(func(ctx context.Context, err error) error {
+			require.NoError(t, ctx.Err(), "the fallback is not bound to the call deadline")
+			return defaults(&out)(ctx, err)
+		}, FallbackOnBudgetExhausted)).Do()
+		require.NoError(t, err)
+		require.Equal(t, []string{"default"}, out)
+	})
+
+	t.Run("ignores errors outside the predicates", func(t *testing.T) {
+		var out []string
+		err := Get(server.URL+"/unavailable", Fallback(defaults(&out), FallbackOnBudgetExhausted)).Do()
+		require.Error(t, err)
+		require.Nil(t, out)
+	})
+
+	t.Run("keeps the call error when the fallback fails", func(t *testing.T) {
+		err := Get(server.URL+"/unavailable", Fallback(func(ctx context.Context, err error) error {
+			return errors.New("no defaults")
+		})).Do()
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		require.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	})
+
+	_, err := Get(server.URL, Fallback(nil)).DoStream()
+	require.Error(t, err)
+}
+
+func TestFallbackLastGood(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"tenant":"` + r.Header.Get("ofa-pass-tenant-id") + `"}`))
+	}))
+	defer server.Close()
+
+	type profile struct {
+		Tenant string `json:"tenant"`
+	}
+	store := NewLastGood[profile](time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	call := func(ctx context.Context) (profile, error) {
+		var out profile
+		err := Get(server.URL, Context(ctx), JSONResp(&out), FallbackLastGood(store, "profile", &out)).Do()
+		return out, err
+	}
+
+	acme := WithDegradation(pass.CtxSetTenantID(context.Background(), "acme"))
+	out, err := call(acme)
+	require.NoError(t, err)
+	require.Equal(t, "acme", out.Tenant)
+	require.False(t, IsDegraded(acme))
+
+	down.Store(true)
+	out, err = call(acme)
+	require.NoError(t, err)
+	require.Equal(t, "acme", out.Tenant)
+	require.True(t, IsDegraded(acme))
+
+	_, err = call(pass.CtxSetTenantID(context.Background(), "globex"))
+	require.Error(t, err, "tenants never see each other's last-good values")
+
+	now = now.Add(2 * time.Minute)
+	_, err = call(acme)
+	require.Error(t, err, "expired values are not served")
+}
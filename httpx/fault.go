@@ -0,0 +1,233 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/dev-ofa/core-go/trace/logging"
+)
+
+const (
+	// HeaderChaos is the pass header conventionally used to opt a call chain into fault rules.
+	HeaderChaos = "ofa-pass-chaos"
+	// HeaderFault names the fault rule on responses produced or altered by a FaultInjector.
+	HeaderFault = "X-Httpx-Fault"
+)
+
+// FaultConfig configures fault injection for chaos testing. Faults are only
+// injected when Enabled is set, so they cannot be switched on by request
+// data alone; keep Enabled off outside test environments.
+type FaultConfig struct {
+	Enabled bool        `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	Rules   []FaultRule `json:"rules" yaml:"rules" mapstructure:"rules"`
+}
+
+// FaultRule matches attempts and describes the fault injected into them. All
+// set matchers must match; the first matching rule applies.
+type FaultRule struct {
+	// Name identifies the rule in logs and the HeaderFault response header.
+	Name string `json:"name" yaml:"name" mapstructure:"name"`
+	// Service matches Attempt.Service exactly.
+	Service string `json:"service" yaml:"service" mapstructure:"service"`
+	// Path matches the URL path with path.Match, e.g. "/orders/*".
+	Path string `json:"path" yaml:"path" mapstructure:"path"`
+	// TenantID matches the tenant from pass.
+	TenantID string `json:"tenant_id" yaml:"tenant_id" mapstructure:"tenant_id"`
+	// Header matches a request header, e.g. HeaderChaos. HeaderValue empty
+	// matches any non-empty value.
+	Header      string `json:"header" yaml:"header" mapstructure:"header"`
+	HeaderValue string `json:"header_value" yaml:"header_value" mapstructure:"header_value"`
+	// Probability is the chance in [0, 1] that a matching attempt is faulted.
+	Probability float64 `json:"probability" yaml:"probability" mapstructure:"probability"`
+
+	// Latency delays the attempt before it is sent or failed.
+	Latency time.Duration `json:"latency" yaml:"latency" mapstructure:"latency"`
+	// Reset fails the attempt with a connection reset error.
+	Reset bool `json:"reset" yaml:"reset" mapstructure:"reset"`
+	// StatusCode answers the attempt with this status code without sending it.
+	StatusCode int `json:"status_code" yaml:"status_code" mapstructure:"status_code"`
+	// MalformedBody sends the attempt and truncates the response body.
+	MalformedBody bool `json:"malformed_body" yaml:"malformed_body" mapstructure:"malformed_body"`
+}
+
+// FaultInjector is an Interceptor injecting the faults of a FaultConfig.
+// Register it last so that retries, breakers and metrics observe the faults
+// as if they came from the network.
+type FaultInjector struct {
+	enabled bool
+	rules   []FaultRule
+	roll    func() float64
+}
+
+// NewFaultInjector validates cfg and returns an injector. The injector passes
+// every attempt through unchanged when cfg.Enabled is false.
+func NewFaultInjector(cfg FaultConfig) (*FaultInjector, error) {
+	for i, rule := range cfg.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, datax.NewValidationError(fmt.Sprintf("fault rule %d %q: %v", i, rule.Name, err), nil, nil)
+		}
+	}
+	return &FaultInjector{
+		enabled: cfg.Enabled,
+		rules:   append([]FaultRule(nil), cfg.Rules...),
+		roll:    rand.Float64, // #nosec G404: fault sampling does not require crypto randomness.
+	}, nil
+}
+
+// FaultInjection injects faults into the attempts of the agent through injector.
+func FaultInjection(injector *FaultInjector) AgentOpFunc {
+	return Interceptors(injector)
+}
+
+func (r FaultRule) validate() error {
+	if r.Probability < 0 || r.Probability > 1 {
+		return fmt.Errorf("probability %v is out of [0, 1]", r.Probability)
+	}
+	if r.Path != "" {
+		if _, err := path.Match(r.Path, "/"); err != nil {
+			return fmt.Errorf("invalid path pattern %q", r.Path)
+		}
+	}
+	if r.StatusCode != 0 && (r.StatusCode < 100 || r.StatusCode > 599) {
+		return fmt.Errorf("invalid status code %d", r.StatusCode)
+	}
+	terminal := 0
+	for _, set := range []bool{r.Reset, r.StatusCode != 0, r.MalformedBody} {
+		if set {
+			terminal++
+		}
+	}
+	if terminal > 1 {
+		return fmt.Errorf("reset, status_code and malformed_body are exclusive")
+	}
+	if terminal == 0 && r.Latency <= 0 {
+		return fmt.Errorf("no fault configured")
+	}
+	return nil
+}
+
+func (r FaultRule) match(attempt *Attempt) bool {
+	req := attempt.Request
+	if r.Service != "" && r.Service != attempt.Service {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	if r.TenantID != "" {
+		tenantID, _ := pass.CtxGetTenantID(req.Context())
+		if tenantID == "" {
+			tenantID = req.Header.Get(HeaderTenantID)
+		}
+		if tenantID != r.TenantID {
+			return false
+		}
+	}
+	if r.Header != "" {
+		value := strings.Join(headerValues(req.Header, r.Header), ",")
+		if value == "" || (r.HeaderValue != "" && value != r.HeaderValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// Intercept implements Interceptor.
+func (f *FaultInjector) Intercept(attempt *Attempt, next Invoker) (*http.Response, error) {
+	if !f.enabled {
+		return next(attempt)
+	}
+	rule, ok := f.pick(attempt)
+	if !ok {
+		return next(attempt)
+	}
+	req := attempt.Request
+	ctx := req.Context()
+	logging.CtxWarnf(ctx, "httpx fault injected rule=%s service=%s path=%s", rule.Name, attempt.Service, req.URL.Path)
+	if rule.Latency > 0 {
+		timer := time.NewTimer(rule.Latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			closeRequestBody(req)
+			return nil, ctx.Err()
+		}
+	}
+	switch {
+	case rule.Reset:
+		closeRequestBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	case rule.StatusCode != 0:
+		closeRequestBody(req)
+		return faultResponse(req, rule), nil
+	case rule.MalformedBody:
+		resp, err := next(attempt)
+		if err != nil {
+			return nil, err
+		}
+		return malformResponse(resp, rule)
+	}
+	return next(attempt)
+}
+
+// closeRequestBody releases the body of an attempt that never reaches the
+// transport, which would otherwise close it.
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+func (f *FaultInjector) pick(attempt *Attempt) (FaultRule, bool) {
+	for _, rule := range f.rules {
+		if rule.match(attempt) {
+			return rule, rule.Probability > 0 && f.roll() < rule.Probability
+		}
+	}
+	return FaultRule{}, false
+}
+
+func faultResponse(req *http.Request, rule FaultRule) *http.Response {
+	body := "httpx: injected fault " + rule.Name
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rule.StatusCode, http.StatusText(rule.StatusCode)),
+		StatusCode:    rule.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{HeaderFault: []string{rule.Name}, "Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// malformResponse keeps the first half of the body, which breaks any
+// structured payload while leaving the status and headers intact.
+func malformResponse(resp *http.Response, rule FaultRule) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response before fault injection failed: %w", err)
+	}
+	body = body[:len(body)/2]
+	resp.Header = resp.Header.Clone()
+	resp.Header.Set(HeaderFault, rule.Name)
+	resp.Header.Del("Content-Length")
+	resp.Body = io.NopCloser(strings.NewReader(string(body)))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
@@ -0,0 +1,224 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/stretchr/testify/require"
+)
+
+func newFaultServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"ok":true,"items":[1,2,3]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestFaultInjectorIsDisabledByDefault(t *testing.T) {
+	server, calls := newFaultServer(t)
+	injector, err := NewFaultInjector(FaultConfig{Rules: []FaultRule{{Name: "all", StatusCode: 503, Probability: 1}}})
+	require.NoError(t, err)
+
+	require.NoError(t, Get(server.URL, FaultInjection(injector)).Do())
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestFaultInjectorStatusByChaosHeader(t *testing.T) {
+	server, calls := newFaultServer(t)
+	injector, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{{
+		Name:        "chaos-503",
+		Header:      HeaderChaos,
+		HeaderValue: "on",
+		StatusCode:  http.StatusServiceUnavailable,
+		Probability: 1,
+	}}})
+	require.NoError(t, err)
+
+	ctx := pass.CtxSetPassVal(context.Background(), "chaos", "on")
+	var resp http.Response
+	err = Get(server.URL, Context(ctx), FaultInjection(injector), ExpectedStatusCodes([]int{200, 503}), RawResp(&resp, nil)).Do()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, "chaos-503", resp.Header.Get(HeaderFault))
+	require.Zero(t, calls.Load())
+
+	require.NoError(t, Get(server.URL, FaultInjection(injector)).Do(), "calls without the header are untouched")
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestFaultInjectorResetIsRetried(t *testing.T) {
+	server, calls := newFaultServer(t)
+	injector, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{{
+		Name:        "reset",
+		Path:        "/flaky/*",
+		Reset:       true,
+		Probability: 0.5,
+	}}})
+	require.NoError(t, err)
+	rolls := []float64{0.1, 0.9}
+	injector.roll = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+
+	var seen []error
+	observer := InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+		resp, err := next(attempt)
+		seen = append(seen, err)
+		return resp, err
+	})
+	err = Get(server.URL+"/flaky/1",
+		Interceptors(observer),
+		FaultInjection(injector),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	).Do()
+	require.NoError(t, err)
+	require.Len(t, seen, 2)
+	require.ErrorIs(t, seen[0], syscall.ECONNRESET)
+	require.NoError(t, seen[1])
+	require.EqualValues(t, 1, calls.Load())
+
+	require.NoError(t, Get(server.URL+"/stable", FaultInjection(injector)).Do())
+}
+
+func TestFaultInjectorLatencyHonoursDeadline(t *testing.T) {
+	server, calls := newFaultServer(t)
+	injector, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{{
+		Name:        "slow-tenant",
+		Service:     "inventory",
+		TenantID:    "t1",
+		Latency:     time.Second,
+		Probability: 1,
+	}}})
+	require.NoError(t, err)
+
+	service := Service(ServiceOptions{
+		ServiceName:     "inventory",
+		Namespace:       "prod",
+		EnableDiscovery: true,
+		Resolver:        newStaticResolverForServer(t, server),
+	})
+	ctx, cancel := context.WithTimeout(pass.CtxSetTenantID(context.Background(), "t1"), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = Get("http://inventory.prod/api", Context(ctx), service, FaultInjection(injector)).Do()
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Zero(t, calls.Load())
+
+	ctx = pass.CtxSetTenantID(context.Background(), "t2")
+	require.NoError(t, Get("http://inventory.prod/api", Context(ctx), service, FaultInjection(injector)).Do())
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func newStaticResolverForServer(t *testing.T, server *httptest.Server) Resolver {
+	t.Helper()
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	resolver, err := NewStaticResolver(StaticConfig{Services: []StaticService{{
+		Name:      "inventory",
+		Namespace: "prod",
+		Instances: []StaticInstance{{Host: addr.IP.String(), Port: addr.Port, Scheme: "http"}},
+	}}})
+	require.NoError(t, err)
+	return resolver
+}
+
+func TestFaultInjectorMalformedBody(t *testing.T) {
+	server, _ := newFaultServer(t)
+	injector, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{{Name: "garbage", MalformedBody: true, Probability: 1}}})
+	require.NoError(t, err)
+
+	var out map[string]any
+	err = Get(server.URL, FaultInjection(injector), JSONResp(&out)).Do()
+	require.Error(t, err)
+
+	var raw []byte
+	var resp http.Response
+	require.NoError(t, Get(server.URL, FaultInjection(injector), RawResp(&resp, &raw)).Do())
+	require.Equal(t, `{"ok":true,"i`, string(raw))
+	require.Equal(t, "garbage", resp.Header.Get(HeaderFault))
+}
+
+func TestFaultConfigValidation(t *testing.T) {
+	for name, rule := range map[string]FaultRule{
+		"probability out of range": {StatusCode: 500, Probability: 2},
+		"exclusive faults":         {StatusCode: 500, Reset: true, Probability: 1},
+		"no fault":                 {Probability: 1},
+		"bad status":               {StatusCode: 42, Probability: 1},
+		"bad path":                 {Path: "[", Reset: true, Probability: 1},
+	} {
+		_, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{rule}})
+		require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err), name)
+	}
+
+	server, calls := newFaultServer(t)
+	profile, err := NewProfileFromConfig(ProfileConfig{
+		Name:    "inventory",
+		BaseURL: server.URL,
+		Faults:  &FaultConfig{Enabled: true, Rules: []FaultRule{{Name: "teapot", StatusCode: http.StatusTeapot, Probability: 1}}},
+	})
+	require.NoError(t, err)
+	err = profile.Get("/api").Do()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "418")
+	require.Zero(t, calls.Load())
+}
+
+type closeTrackingBody struct {
+	opened, closed atomic.Int32
+}
+
+func (b *closeTrackingBody) Open() (io.ReadCloser, error) {
+	b.opened.Add(1)
+	return closeTracker{Reader: strings.NewReader("payload"), closed: &b.closed}, nil
+}
+
+func (b *closeTrackingBody) Size() int64 {
+	return int64(len("payload"))
+}
+
+type closeTracker struct {
+	io.Reader
+	closed *atomic.Int32
+}
+
+func (c closeTracker) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestFaultInjectorClosesRequestBody(t *testing.T) {
+	server, calls := newFaultServer(t)
+	for _, rule := range []FaultRule{
+		{Name: "reset", Reset: true, Probability: 1},
+		{Name: "status", StatusCode: http.StatusServiceUnavailable, Probability: 1},
+	} {
+		injector, err := NewFaultInjector(FaultConfig{Enabled: true, Rules: []FaultRule{rule}})
+		require.NoError(t, err)
+		body := &closeTrackingBody{}
+		err = Post(server.URL,
+			BodyReq("text/plain", body),
+			FaultInjection(injector),
+			Retry(&RetryOpt{Attempts: 2, Idempotent: true, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		).Do()
+		require.Error(t, err, rule.Name)
+		require.Positive(t, body.opened.Load(), rule.Name)
+		require.Equal(t, body.opened.Load(), body.closed.Load(), "%s: every injected attempt closes its body", rule.Name)
+	}
+	require.Zero(t, calls.Load())
+}
@@ -0,0 +1,310 @@
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode selects how a Recorder serves requests.
+type Mode int
+
+const (
+	// ModeReplay serves requests from the cassette and never touches the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests through the real transport and writes them to the cassette on Stop.
+	ModeRecord
+)
+
+// Redacted replaces redacted header, query and JSON body values.
+const Redacted = "REDACTED"
+
+// DefaultRedactHeaders are always redacted from cassettes.
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+
+// Cassette is the file format of recorded interactions.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// CassetteRequest is a recorded request.
+type CassetteRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   CassetteBody `json:"body,omitempty"`
+}
+
+// CassetteResponse is a recorded response.
+type CassetteResponse struct {
+	Status int          `json:"status"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   CassetteBody `json:"body,omitempty"`
+}
+
+// CassetteBody is stored as text when it is valid UTF-8 and as base64 otherwise.
+type CassetteBody []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b CassetteBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *CassetteBody) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = []byte(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	*b = decoded
+	return err
+}
+
+// RecorderOptions configures a Recorder.
+type RecorderOptions struct {
+	// Path is the cassette file.
+	Path string
+	Mode Mode
+	// Transport sends requests in ModeRecord. The default is http.DefaultTransport.
+	Transport http.RoundTripper
+	// RedactHeaders are redacted in addition to DefaultRedactHeaders.
+	RedactHeaders []string
+	// RedactQuery lists query parameters to redact.
+	RedactQuery []string
+	// RedactBodyFields lists JSON object keys, redacted at any depth, and form fields
+	// redacted in request and response bodies.
+	RedactBodyFields []string
+	// Match reports whether a live request, already redacted, matches a recorded one.
+	// The default compares method, URL and body.
+	Match func(live CassetteRequest, recorded CassetteRequest) bool
+}
+
+// Recorder is an http.RoundTripper that records interactions to a cassette or
+// replays them. Replayed interactions are served in order: each live request
+// takes the first unused matching interaction.
+type Recorder struct {
+	opt RecorderOptions
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder returns a recorder. ModeReplay loads the cassette immediately.
+func NewRecorder(opt RecorderOptions) (*Recorder, error) {
+	if opt.Path == "" {
+		return nil, fmt.Errorf("httpxtest: recorder requires a cassette path")
+	}
+	if opt.Transport == nil {
+		opt.Transport = http.DefaultTransport
+	}
+	if opt.Match == nil {
+		opt.Match = defaultMatch
+	}
+	r := &Recorder{opt: opt}
+	if opt.Mode == ModeReplay {
+		content, err := os.ReadFile(opt.Path)
+		if err != nil {
+			return nil, fmt.Errorf("httpxtest: read cassette failed: %w", err)
+		}
+		if err := json.Unmarshal(content, &r.cassette); err != nil {
+			return nil, fmt.Errorf("httpxtest: decode cassette %s failed: %w", opt.Path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Client returns an HTTP client using the recorder as transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop writes the cassette in ModeRecord. It is a no-op in ModeReplay.
+func (r *Recorder) Stop() error {
+	if r.opt.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	content, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("httpxtest: encode cassette failed: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.opt.Path), 0o755); err != nil {
+		return fmt.Errorf("httpxtest: create cassette dir failed: %w", err)
+	}
+	if err := os.WriteFile(r.opt.Path, append(content, '\n'), 0o644); err != nil {
+		return fmt.Errorf("httpxtest: write cassette failed: %w", err)
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	live := r.redactRequest(req, body)
+	if r.opt.Mode == ModeReplay {
+		return r.replay(req, live)
+	}
+
+	outgoing := req.Clone(req.Context())
+	outgoing.Body = io.NopCloser(bytes.NewReader(body))
+	outgoing.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	resp, err := r.opt.Transport.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("httpxtest: read response body failed: %w", err)
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: live,
+		Response: CassetteResponse{
+			Status: resp.StatusCode,
+			Header: r.redactHeader(resp.Header),
+			Body:   r.redactBody(respBody),
+		},
+	})
+	r.mu.Unlock()
+	return newResponse(req, resp.StatusCode, resp.Header, respBody), nil
+}
+
+func (r *Recorder) replay(req *http.Request, live CassetteRequest) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !r.opt.Match(live, interaction.Request) {
+			continue
+		}
+		r.used[i] = true
+		resp := interaction.Response
+		return newResponse(req, resp.Status, resp.Header, resp.Body), nil
+	}
+	return nil, fmt.Errorf("httpxtest: cassette %s has no unused interaction for %s %s", r.opt.Path, live.Method, live.URL)
+}
+
+func defaultMatch(live CassetteRequest, recorded CassetteRequest) bool {
+	return live.Method == recorded.Method && live.URL == recorded.URL && bytes.Equal(live.Body, recorded.Body)
+}
+
+func (r *Recorder) redactRequest(req *http.Request, body []byte) CassetteRequest {
+	u := *req.URL
+	if len(r.opt.RedactQuery) > 0 {
+		query := u.Query()
+		for _, key := range r.opt.RedactQuery {
+			if _, ok := query[key]; ok {
+				query.Set(key, Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return CassetteRequest{
+		Method: req.Method,
+		URL:    u.String(),
+		Header: r.redactHeader(req.Header),
+		Body:   r.redactBody(body),
+	}
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, names := range [][]string{DefaultRedactHeaders, r.opt.RedactHeaders} {
+		for _, name := range names {
+			for key := range redacted {
+				if strings.EqualFold(key, name) {
+					redacted[key] = []string{Redacted}
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) redactBody(body []byte) CassetteBody {
+	if len(r.opt.RedactBodyFields) == 0 || len(body) == 0 {
+		return body
+	}
+	var doc any
+	if json.Unmarshal(body, &doc) != nil {
+		return r.redactForm(body)
+	}
+	fields := map[string]bool{}
+	for _, f := range r.opt.RedactBodyFields {
+		fields[f] = true
+	}
+	redacted, err := json.Marshal(redactJSON(doc, fields))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+// redactForm redacts url-encoded form bodies, e.g. OAuth2 token requests.
+func (r *Recorder) redactForm(body []byte) CassetteBody {
+	values, err := url.ParseQuery(string(body))
+	if err != nil || len(values) == 0 {
+		return body
+	}
+	changed := false
+	for _, f := range r.opt.RedactBodyFields {
+		if _, ok := values[f]; ok {
+			values.Set(f, Redacted)
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	return []byte(values.Encode())
+}
+
+func redactJSON(v any, fields map[string]bool) any {
+	switch typed := v.(type) {
+	case map[string]any:
+		for k, child := range typed {
+			if fields[k] {
+				typed[k] = Redacted
+				continue
+			}
+			typed[k] = redactJSON(child, fields)
+		}
+	case []any:
+		for i, child := range typed {
+			typed[i] = redactJSON(child, fields)
+		}
+	}
+	return v
+}
@@ -0,0 +1,74 @@
+package httpxtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dev-ofa/core-go/httpx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRecordsRedactedAndReplays(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		require.Contains(t, string(body), "hunter2", "the real upstream sees unredacted values")
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"user":{"name":"ann","token":"tok-123"}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "login.json")
+	profile := &httpx.Profile{BaseURL: server.URL}
+	login := func(client *http.Client) (string, error) {
+		var out struct {
+			User struct {
+				Name  string `json:"name"`
+				Token string `json:"token"`
+			} `json:"user"`
+		}
+		err := profile.Post("/login?api_key=k1",
+			httpx.Client(client),
+			httpx.SetHeader(http.Header{"Authorization": {"Basic secret"}}),
+			httpx.JSONReq(map[string]string{"user": "ann", "password": "hunter2"}),
+			httpx.JSONResp(&out),
+		).Do()
+		return out.User.Name + "/" + out.User.Token, err
+	}
+	opt := RecorderOptions{
+		Path:             path,
+		Mode:             ModeRecord,
+		RedactQuery:      []string{"api_key"},
+		RedactBodyFields: []string{"password", "token"},
+	}
+
+	recorder, err := NewRecorder(opt)
+	require.NoError(t, err)
+	got, err := login(recorder.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ann/tok-123", got, "record mode returns the real response")
+	require.NoError(t, recorder.Stop())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for _, secret := range []string{"hunter2", "tok-123", "Basic secret", "session=abc", "k1"} {
+		require.False(t, strings.Contains(string(content), secret), secret)
+	}
+
+	opt.Mode = ModeReplay
+	replayer, err := NewRecorder(opt)
+	require.NoError(t, err)
+	got, err = login(replayer.Client())
+	require.NoError(t, err)
+	require.Equal(t, "ann/REDACTED", got)
+	require.Equal(t, 1, calls, "replay never reaches the upstream")
+
+	_, err = login(replayer.Client())
+	require.ErrorContains(t, err, "no unused interaction")
+}
@@ -0,0 +1,293 @@
+// Package httpxtest provides test doubles for code calling through httpx: a
+// scripted mock transport and a record/replay transport backed by cassette
+// files. Both plug in with httpx.Client(x.Client()) or Profile.Client.
+package httpxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Matcher reports whether a request matches. body is the full request body.
+type Matcher func(req *http.Request, body []byte) bool
+
+// Method matches the request method.
+func Method(method string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		return strings.EqualFold(req.Method, method)
+	}
+}
+
+// Path matches the URL path with path.Match syntax, e.g. "/v1/items/*".
+func Path(pattern string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		ok, _ := path.Match(pattern, req.URL.Path)
+		return ok
+	}
+}
+
+// Query matches one query parameter value.
+func Query(key string, value string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		return req.URL.Query().Get(key) == value
+	}
+}
+
+// Header matches one header value. An empty value matches any present header.
+func Header(key string, value string) Matcher {
+	return func(req *http.Request, _ []byte) bool {
+		got := headerValue(req.Header, key)
+		if value == "" {
+			return got != ""
+		}
+		return got == value
+	}
+}
+
+// JSONBody matches a JSON body semantically equal to want.
+func JSONBody(want any) Matcher {
+	encoded, err := json.Marshal(want)
+	var expected any
+	if err == nil {
+		err = json.Unmarshal(encoded, &expected)
+	}
+	return func(_ *http.Request, body []byte) bool {
+		if err != nil {
+			return false
+		}
+		var got any
+		if json.Unmarshal(body, &got) != nil {
+			return false
+		}
+		return reflect.DeepEqual(expected, got)
+	}
+}
+
+// BodyContains matches a body containing s.
+func BodyContains(s string) Matcher {
+	return func(_ *http.Request, body []byte) bool {
+		return bytes.Contains(body, []byte(s))
+	}
+}
+
+// Reply is one scripted answer. Err is returned as a transport error instead of a response.
+type Reply struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Err    error
+	// Delay is waited before answering, or until the request context is done.
+	Delay time.Duration
+}
+
+// JSON returns a reply with a JSON encoded body.
+func JSON(status int, v any) Reply {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return Reply{Err: fmt.Errorf("httpxtest: marshal reply failed: %w", err)}
+	}
+	return Reply{Status: status, Header: http.Header{"Content-Type": {"application/json"}}, Body: body}
+}
+
+// Text returns a reply with a plain text body.
+func Text(status int, body string) Reply {
+	return Reply{Status: status, Header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}, Body: []byte(body)}
+}
+
+// Status returns a reply with an empty body.
+func Status(status int) Reply {
+	return Reply{Status: status}
+}
+
+// Error returns a reply failing with a transport error.
+func Error(err error) Reply {
+	return Reply{Err: err}
+}
+
+// After returns a copy of r delayed by d.
+func (r Reply) After(d time.Duration) Reply {
+	r.Delay = d
+	return r
+}
+
+// Expectation is a set of matchers and the replies scripted for them.
+type Expectation struct {
+	matchers []Matcher
+	replies  []Reply
+	times    int
+	calls    int
+}
+
+// Reply scripts answers in order. The last reply repeats once the script is exhausted.
+func (e *Expectation) Reply(replies ...Reply) *Expectation {
+	e.replies = append(e.replies, replies...)
+	return e
+}
+
+// Times limits how many requests the expectation answers. The default is unlimited.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times > 0 && e.calls >= e.times
+}
+
+func (e *Expectation) match(req *http.Request, body []byte) bool {
+	for _, m := range e.matchers {
+		if !m(req, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// RecordedRequest is a request received by Mock.
+type RecordedRequest struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Mock is a scripted http.RoundTripper. Expectations are checked in the order
+// they were added; the first one matching and not exhausted answers.
+// Unmatched requests fail with an error describing the request.
+type Mock struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	requests     []RecordedRequest
+}
+
+// NewMock returns an empty mock transport.
+func NewMock() *Mock {
+	return &Mock{}
+}
+
+// On adds an expectation answering requests that match all matchers.
+func (m *Mock) On(matchers ...Matcher) *Expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &Expectation{matchers: matchers}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// Client returns an HTTP client using the mock as transport.
+func (m *Mock) Client() *http.Client {
+	return &http.Client{Transport: m}
+}
+
+// Requests returns every request received so far.
+func (m *Mock) Requests() []RecordedRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RecordedRequest(nil), m.requests...)
+}
+
+// Pending returns how many expectations limited by Times still expect calls.
+func (m *Mock) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := 0
+	for _, e := range m.expectations {
+		if e.times > 0 && e.calls < e.times {
+			pending++
+		}
+	}
+	return pending
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.requests = append(m.requests, RecordedRequest{Method: req.Method, URL: req.URL.String(), Header: req.Header.Clone(), Body: body})
+	var reply *Reply
+	for _, e := range m.expectations {
+		if e.exhausted() || !e.match(req, body) {
+			continue
+		}
+		e.calls++
+		r := Reply{Status: http.StatusOK}
+		if len(e.replies) > 0 {
+			r = e.replies[min(e.calls, len(e.replies))-1]
+		}
+		reply = &r
+		break
+	}
+	m.mu.Unlock()
+	if reply == nil {
+		return nil, fmt.Errorf("httpxtest: no expectation matches %s %s", req.Method, req.URL)
+	}
+	if reply.Delay > 0 {
+		timer := time.NewTimer(reply.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	return newResponse(req, reply.Status, reply.Header, reply.Body), nil
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("httpxtest: read request body failed: %w", err)
+	}
+	return body, nil
+}
+
+func newResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// headerValue looks up key case-insensitively, including keys that were not canonicalized.
+func headerValue(header http.Header, key string) string {
+	if v := header.Get(key); v != "" {
+		return v
+	}
+	for k, v := range header {
+		if strings.EqualFold(k, key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package httpxtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/httpx"
+	"github.com/stretchr/testify/require"
+)
+
+type resetError struct{}
+
+func (resetError) Error() string   { return "connection reset by peer" }
+func (resetError) Timeout() bool   { return false }
+func (resetError) Temporary() bool { return true }
+
+func TestMockScriptsRepliesByMatcher(t *testing.T) {
+	mock := NewMock()
+	mock.On(Method(http.MethodPost), Path("/v1/items"), Header("X-Tenant", "t1"), JSONBody(map[string]any{"name": "widget"})).
+		Reply(JSON(http.StatusCreated, map[string]string{"id": "i1"})).
+		Times(1)
+	mock.On(Method(http.MethodGet), Path("/v1/items/*")).
+		Reply(Error(resetError{}), Status(http.StatusServiceUnavailable), Text(http.StatusOK, `{"id":"i1"}`))
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	err := httpx.Post("http://inventory/v1/items",
+		httpx.Client(mock.Client()),
+		httpx.SetHeader(http.Header{"X-Tenant": {"t1"}}),
+		httpx.JSONReq(map[string]string{"name": "widget"}),
+		httpx.ExpectedStatusCodes([]int{http.StatusCreated}),
+		httpx.JSONResp(&created),
+	).Do()
+	require.NoError(t, err)
+	require.Equal(t, "i1", created.ID)
+	require.Zero(t, mock.Pending())
+
+	err = httpx.Post("http://inventory/v1/items", httpx.Client(mock.Client()), httpx.JSONReq(map[string]string{"name": "widget"})).Do()
+	require.ErrorContains(t, err, "no expectation matches POST http://inventory/v1/items", "Times(1) is exhausted")
+
+	err = httpx.Get("http://inventory/v1/items/i1",
+		httpx.Client(mock.Client()),
+		httpx.Retry(&httpx.RetryOpt{Attempts: 3, BaseDelay: time.Millisecond}),
+		httpx.RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+		httpx.JSONResp(&created),
+	).Do()
+	require.NoError(t, err, "scripted reset, 503, then success")
+	require.Len(t, mock.Requests(), 5)
+}
+
+func TestMockDelayRespectsContext(t *testing.T) {
+	mock := NewMock()
+	mock.On(Path("/slow")).Reply(Status(http.StatusOK).After(time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := httpx.Get("http://svc/slow", httpx.Client(mock.Client()), httpx.Context(ctx)).Do()
+	require.Error(t, err)
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
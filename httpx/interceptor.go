@@ -0,0 +1,77 @@
+package httpx
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Attempt describes one HTTP attempt passed through the interceptor chain.
+type Attempt struct {
+	// Request is the fully prepared request, after pre-handlers, trace injection
+	// and discovery rewrite. Interceptors may replace it before calling next.
+	Request *http.Request
+	// Number is the 1-based attempt number within the call.
+	Number int
+	// RequestID is the single-hop request id injected for this attempt.
+	RequestID string
+	// Service is the discovery service name, or the URL host without discovery.
+	Service string
+	// Instance is the discovery instance chosen for this attempt, or nil without discovery.
+	Instance *Instance
+}
+
+// Invoker sends one attempt and returns the raw response.
+type Invoker func(attempt *Attempt) (*http.Response, error)
+
+// Interceptor wraps every attempt of an Agent. It may inspect or replace the
+// request, short-circuit with its own response or error, and observe the raw
+// response and transport error returned by next. Status code validation,
+// retries and response handling run outside the chain.
+type Interceptor interface {
+	Intercept(attempt *Attempt, next Invoker) (*http.Response, error)
+}
+
+// InterceptorFunc adapts a function into an Interceptor.
+type InterceptorFunc func(attempt *Attempt, next Invoker) (*http.Response, error)
+
+// Intercept implements Interceptor.
+func (f InterceptorFunc) Intercept(attempt *Attempt, next Invoker) (*http.Response, error) {
+	return f(attempt, next)
+}
+
+// Interceptors appends interceptors to the agent chain. Interceptors registered
+// first run outermost.
+func Interceptors(interceptors ...Interceptor) AgentOpFunc {
+	return func(agent *Agent) error {
+		for _, interceptor := range interceptors {
+			if interceptor != nil {
+				agent.interceptors = append(agent.interceptors, interceptor)
+			}
+		}
+		return nil
+	}
+}
+
+func (a *Agent) invoke(attempt *Attempt) (*http.Response, error) {
+	resp, err := chainInterceptors(a.interceptors, func(attempt *Attempt) (*http.Response, error) {
+		if err := a.sign(attempt.Request); err != nil {
+			return nil, err
+		}
+		return a.client.Do(attempt.Request)
+	})(attempt)
+	if err == nil && resp == nil {
+		return nil, errors.New("interceptor returned neither response nor error")
+	}
+	return resp, err
+}
+
+func chainInterceptors(interceptors []Interceptor, last Invoker) Invoker {
+	next := last
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := interceptors[i], next
+		next = func(attempt *Attempt) (*http.Response, error) {
+			return interceptor.Intercept(attempt, inner)
+		}
+	}
+	return next
+}
@@ -0,0 +1,98 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptorsWrapEveryAttempt(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		require.Equal(t, "signed", r.Header.Get("X-Signature"))
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	resolver := ResolverFunc(func(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+		return &ResolveResponse{Instances: []Instance{{InstanceID: "inv-1", Host: addr.IP.String(), Port: addr.Port, Scheme: "http"}}}, nil
+	})
+
+	var trail []string
+	var seen []Attempt
+	outer := InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+		trail = append(trail, "outer-before")
+		resp, err := next(attempt)
+		trail = append(trail, "outer-after")
+		seen = append(seen, *attempt)
+		if err == nil {
+			trail = append(trail, resp.Status)
+		}
+		return resp, err
+	})
+	inner := InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+		trail = append(trail, "inner")
+		attempt.Request.Header.Set("X-Signature", "signed")
+		return next(attempt)
+	})
+
+	var resp map[string]bool
+	err := Get("http://inventory.prod/api",
+		Service(ServiceOptions{EnableDiscovery: true, Resolver: resolver}),
+		Interceptors(outer, nil, inner),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+		JSONResp(&resp),
+	).Do()
+	require.NoError(t, err)
+	require.True(t, resp["ok"])
+	require.Equal(t, []string{
+		"outer-before", "inner", "outer-after", "503 Service Unavailable",
+		"outer-before", "inner", "outer-after", "200 OK",
+	}, trail)
+	require.Len(t, seen, 2)
+	require.Equal(t, 1, seen[0].Number)
+	require.Equal(t, 2, seen[1].Number)
+	require.NotEqual(t, seen[0].RequestID, seen[1].RequestID)
+	require.Equal(t, "inv-1", seen[1].Instance.InstanceID)
+}
+
+func TestInterceptorCanShortCircuit(t *testing.T) {
+	stub := InterceptorFunc(func(attempt *Attempt, _ Invoker) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"ok":true}`)),
+			Request:    attempt.Request,
+		}, nil
+	})
+	var resp map[string]bool
+	require.NoError(t, Get("http://unreachable.invalid", Interceptors(stub), JSONResp(&resp)).Do())
+	require.True(t, resp["ok"])
+
+	denied := errors.New("denied by policy")
+	err := Get("http://unreachable.invalid", Interceptors(InterceptorFunc(func(*Attempt, Invoker) (*http.Response, error) {
+		return nil, denied
+	}))).Do()
+	require.ErrorIs(t, err, denied)
+
+	err = Get("http://unreachable.invalid", Interceptors(InterceptorFunc(func(*Attempt, Invoker) (*http.Response, error) {
+		return nil, nil
+	}))).Do()
+	require.ErrorContains(t, err, "interceptor returned neither response nor error")
+}
@@ -0,0 +1,189 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// EncodingGzip is the gzip content coding.
+	EncodingGzip = "gzip"
+	// EncodingZstd is the zstd content coding.
+	EncodingZstd = "zstd"
+	// EncodingBrotli is the brotli content coding.
+	EncodingBrotli = "br"
+
+	// defaultMaxDecompressedSize bounds decompressed bodies of agents without
+	// MaxResponseSize, so that a small compressed payload cannot expand without limit.
+	defaultMaxDecompressedSize = 64 << 20
+)
+
+// MaxResponseSize bounds the response body to n bytes after decompression.
+// Larger successful bodies fail with ErrResponseTooLarge; bodies of unexpected
+// status codes are truncated to n bytes and reported with
+// HTTPStatusError.BodyTruncated. Zero means no limit.
+func MaxResponseSize(n int64) AgentOpFunc {
+	return func(agent *Agent) error {
+		if n < 0 {
+			return datax.NewValidationError("max response size must not be negative", nil, nil)
+		}
+		agent.maxResponseSize = n
+		return nil
+	}
+}
+
+// Decompress advertises encodings in Accept-Encoding and decodes responses
+// using one of them. Supported encodings are EncodingGzip, EncodingZstd and
+// EncodingBrotli. Decoded bodies are bounded by MaxResponseSize, or by 64 MiB
+// without it, to protect against decompression bombs.
+func Decompress(encodings ...string) AgentOpFunc {
+	return func(agent *Agent) error {
+		normalized := make([]string, 0, len(encodings))
+		for _, encoding := range encodings {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			switch encoding {
+			case EncodingGzip, EncodingZstd, EncodingBrotli:
+				normalized = append(normalized, encoding)
+			default:
+				return datax.NewValidationError(fmt.Sprintf("unsupported content encoding %q", encoding), nil, nil)
+			}
+		}
+		agent.decompress = normalized
+		return nil
+	}
+}
+
+func (a *Agent) setAcceptEncoding(req *http.Request) {
+	if len(a.decompress) > 0 && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", strings.Join(a.decompress, ", "))
+	}
+}
+
+// limitResponse decodes the configured content codings of resp and bounds its body.
+func (a *Agent) limitResponse(resp *http.Response) error {
+	limit := a.maxResponseSize
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if encoding != "" && a.acceptsEncoding(encoding) {
+		body, err := decodeBody(encoding, resp.Body, limit)
+		if err != nil {
+			_ = resp.Body.Close()
+			return err
+		}
+		resp.Body = body
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		if limit == 0 {
+			limit = defaultMaxDecompressedSize
+		}
+	}
+	if limit > 0 {
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: limit, limit: limit}
+	}
+	return nil
+}
+
+func (a *Agent) acceptsEncoding(encoding string) bool {
+	for _, item := range a.decompress {
+		if item == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeBody(encoding string, body io.ReadCloser, limit int64) (io.ReadCloser, error) {
+	switch encoding {
+	case EncodingGzip:
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip response failed: %w", err)
+		}
+		return &decodedBody{Reader: reader, close: func() { _ = reader.Close() }, body: body}, nil
+	case EncodingZstd:
+		maxMemory := uint64(defaultMaxDecompressedSize)
+		if limit > 0 {
+			maxMemory = uint64(limit)
+		}
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxMemory))
+		if err != nil {
+			return nil, fmt.Errorf("decode zstd response failed: %w", err)
+		}
+		return &decodedBody{Reader: decoder, close: decoder.Close, body: body}, nil
+	default:
+		return &decodedBody{Reader: brotli.NewReader(body), body: body}, nil
+	}
+}
+
+// decodedBody reads the decoded stream and closes both the decoder and the raw body.
+type decodedBody struct {
+	io.Reader
+	close func()
+	body  io.ReadCloser
+}
+
+// Close implements io.Closer.
+func (b *decodedBody) Close() error {
+	if b.close != nil {
+		b.close()
+	}
+	return b.body.Close()
+}
+
+// limitedBody fails reads past limit with ErrResponseTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+// Read implements io.Reader.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Probe one byte so that a body of exactly limit bytes still ends with io.EOF.
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: limit %d bytes", ErrResponseTooLarge, b.limit)
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// readErrorBody reads the body of an unexpected status code, truncating it at
+// the response size limit instead of failing.
+func readErrorBody(body io.Reader) ([]byte, bool, error) {
+	data, err := io.ReadAll(body)
+	if errors.Is(err, ErrResponseTooLarge) {
+		return data, true, nil
+	}
+	return data, false, err
+}
+
+// truncatedBodyError marks an HTTP status error whose body was truncated.
+type truncatedBodyError struct {
+	err error
+}
+
+func (e *truncatedBodyError) Error() string {
+	return e.err.Error() + " (body truncated)"
+}
+
+func (e *truncatedBodyError) Unwrap() error {
+	return e.err
+}
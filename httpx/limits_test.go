@@ -0,0 +1,158 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch encoding {
+	case EncodingGzip:
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case EncodingZstd:
+		w, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case EncodingBrotli:
+		w := brotli.NewWriter(&buf)
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	return buf.Bytes()
+}
+
+func TestMaxResponseSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte(`{"items":["a","b","c"]}`))
+	}))
+	defer server.Close()
+
+	t.Run("fails successful bodies over the limit", func(t *testing.T) {
+		var out map[string]any
+		err := Get(server.URL, MaxResponseSize(10), JSONResp(&out)).Do()
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+		require.Equal(t, ErrCodeHTTPResponseTooLarge, datax.CodeOf(err))
+	})
+
+	t.Run("accepts bodies of exactly the limit", func(t *testing.T) {
+		var out map[string]any
+		require.NoError(t, Get(server.URL, MaxResponseSize(23), JSONResp(&out)).Do())
+		require.Len(t, out["items"], 3)
+	})
+
+	t.Run("truncates error bodies", func(t *testing.T) {
+		err := Get(server.URL+"/error", MaxResponseSize(10)).Do()
+		var statusErr *HTTPStatusError
+		require.ErrorAs(t, err, &statusErr)
+		require.Equal(t, http.StatusBadGateway, statusErr.StatusCode)
+		require.Equal(t, `{"items":[`, string(statusErr.Body))
+		require.True(t, statusErr.BodyTruncated)
+		require.False(t, errors.Is(err, ErrResponseTooLarge))
+
+		err = Get(server.URL + "/error").Do()
+		require.ErrorAs(t, err, &statusErr)
+		require.False(t, statusErr.BodyTruncated)
+	})
+
+	t.Run("is not retried as an application error", func(t *testing.T) {
+		var out map[string]any
+		var attempts int
+		counter := InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+			attempts++
+			return next(attempt)
+		})
+		err := Get(server.URL, MaxResponseSize(10), JSONResp(&out), Interceptors(counter),
+			Retry(&RetryOpt{Attempts: 3, RetryAppError: true}),
+		).Do()
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+		require.Equal(t, 1, attempts)
+	})
+
+	_, err := Get(server.URL, MaxResponseSize(-1)).DoStream()
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestDecompress(t *testing.T) {
+	payload := []byte(`{"name":"widget","tags":["a","b"]}`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.URL.Query().Get("encoding")
+		require.Contains(t, r.Header.Get("Accept-Encoding"), encoding)
+		w.Header().Set("Content-Encoding", encoding)
+		_, _ = w.Write(compress(t, encoding, payload))
+	}))
+	defer server.Close()
+
+	for _, encoding := range []string{EncodingGzip, EncodingZstd, EncodingBrotli} {
+		t.Run(encoding, func(t *testing.T) {
+			var out struct {
+				Name string   `json:"name"`
+				Tags []string `json:"tags"`
+			}
+			var resp http.Response
+			require.NoError(t, Get(server.URL+"?encoding="+encoding, Decompress(EncodingGzip, EncodingZstd, EncodingBrotli), RawResp(&resp, nil)).Do())
+			require.Empty(t, resp.Header.Get("Content-Encoding"))
+
+			require.NoError(t, Get(server.URL+"?encoding="+encoding, Decompress(encoding), JSONResp(&out)).Do())
+			require.Equal(t, "widget", out.Name)
+			require.Equal(t, []string{"a", "b"}, out.Tags)
+		})
+	}
+
+	_, err := Get(server.URL, Decompress("deflate")).DoStream()
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestDecompressStopsBombs(t *testing.T) {
+	bomb := compress(t, EncodingGzip, bytes.Repeat([]byte{0}, 8<<20))
+	require.Less(t, len(bomb), 64<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", EncodingGzip)
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write(bomb)
+	}))
+	defer server.Close()
+
+	var body []byte
+	err := Get(server.URL, Decompress(EncodingGzip), MaxResponseSize(1<<20), RawResp(nil, &body)).Do()
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	err = Get(server.URL+"/error", Decompress(EncodingGzip), MaxResponseSize(1<<10)).Do()
+	var statusErr *HTTPStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Len(t, statusErr.Body, 1<<10)
+	require.True(t, statusErr.BodyTruncated)
+
+	profile, err := NewProfileFromConfig(ProfileConfig{
+		Name:            "bomb",
+		BaseURL:         server.URL,
+		MaxResponseSize: 1 << 20,
+		Decompress:      []string{"GZIP"},
+	})
+	require.NoError(t, err)
+	err = profile.Get("/", RawResp(nil, &body)).Do()
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+	require.True(t, strings.Contains(err.Error(), "limit 1048576 bytes"))
+}
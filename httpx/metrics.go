@@ -0,0 +1,109 @@
+package httpx
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+// MetricsEventKind names what a MetricsEvent reports.
+type MetricsEventKind string
+
+const (
+	// MetricsRequest is one attempt that was handed to the interceptor chain.
+	MetricsRequest MetricsEventKind = "request"
+	// MetricsRetry is a retry scheduled after a failed attempt.
+	MetricsRetry MetricsEventKind = "retry"
+	// MetricsBudgetExhausted is an attempt not sent because the timeout budget was spent.
+	MetricsBudgetExhausted MetricsEventKind = "timeout_budget_exhausted"
+	// MetricsDiscoveryFailure is an attempt not sent because discovery failed.
+	MetricsDiscoveryFailure MetricsEventKind = "discovery_failure"
+)
+
+// MetricsEvent is reported to a MetricsHook.
+type MetricsEvent struct {
+	Kind MetricsEventKind
+	// Service is the discovery service name, or the URL host without discovery.
+	Service string
+	Method  string
+	// StatusClass is "2xx" to "5xx", or "none" when no response was received.
+	StatusClass string
+	// Code is datax.CodeOf of the attempt error, 0 on success.
+	Code int
+	// Duration is the attempt latency of MetricsRequest events.
+	Duration time.Duration
+}
+
+// MetricsHook receives call events. Implementations must be safe for
+// concurrent use and fast; they run inline with every call.
+type MetricsHook interface {
+	Observe(event MetricsEvent)
+}
+
+// MetricsHookFunc adapts a function into a MetricsHook.
+type MetricsHookFunc func(event MetricsEvent)
+
+// Observe implements MetricsHook.
+func (f MetricsHookFunc) Observe(event MetricsEvent) {
+	f(event)
+}
+
+// DefaultMetrics receives the events of agents without a Metrics option. It is
+// nil by default; set it once during startup, e.g. to a MetricsRegistry.
+var DefaultMetrics MetricsHook
+
+// Metrics reports the events of the agent to hook instead of DefaultMetrics.
+func Metrics(hook MetricsHook) AgentOpFunc {
+	return func(agent *Agent) error {
+		agent.metrics = hook
+		return nil
+	}
+}
+
+func (a *Agent) metricsHook() MetricsHook {
+	if a.metrics != nil {
+		return a.metrics
+	}
+	return DefaultMetrics
+}
+
+func (a *Agent) observe(kind MetricsEventKind, statusCode int, err error, duration time.Duration) {
+	hook := a.metricsHook()
+	if hook == nil {
+		return
+	}
+	hook.Observe(MetricsEvent{
+		Kind:        kind,
+		Service:     a.serviceName(),
+		Method:      a.method,
+		StatusClass: statusClass(statusCode),
+		Code:        datax.CodeOf(err),
+		Duration:    duration,
+	})
+}
+
+// observeAttempt reports a finished attempt. sent is false when the attempt
+// failed before reaching the interceptor chain.
+func (a *Agent) observeAttempt(result *attemptResult, sent bool, err error, duration time.Duration) {
+	if a.metricsHook() == nil {
+		return
+	}
+	switch {
+	case datax.CodeOf(err) == ErrCodeHTTPTimeoutBudgetExhausted:
+		a.observe(MetricsBudgetExhausted, result.statusCode, err, 0)
+	case result.discoveryFailed:
+		a.observe(MetricsDiscoveryFailure, result.statusCode, err, 0)
+	}
+	if !sent {
+		return
+	}
+	a.observe(MetricsRequest, result.statusCode, err, duration)
+}
+
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "none"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,188 @@
+package httpx
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultLatencyBuckets are the latency histogram upper bounds in seconds.
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsRegistry is an in-process MetricsHook that aggregates events into
+// counters and a latency histogram and renders them in the Prometheus text
+// exposition format. It exposes:
+//
+//	httpx_requests_total{service,method,status_class,code}
+//	httpx_request_duration_seconds{service,method,status_class}
+//	httpx_retries_total{service,method,code}
+//	httpx_rejections_total{service,method,reason}
+//	httpx_discovery_failures_total{service,code}
+type MetricsRegistry struct {
+	buckets []float64
+
+	mu        sync.Mutex
+	requests  *metricFamily
+	latency   *metricFamily
+	retries   *metricFamily
+	rejected  *metricFamily
+	discovery *metricFamily
+}
+
+type metricFamily struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	values  []string
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewMetricsRegistry returns a registry. Without buckets DefaultLatencyBuckets is used.
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MetricsRegistry{
+		buckets:   buckets,
+		requests:  newMetricFamily("httpx_requests_total", "HTTP attempts sent by httpx.", "counter", "service", "method", "status_class", "code"),
+		latency:   newMetricFamily("httpx_request_duration_seconds", "Latency of HTTP attempts sent by httpx.", "histogram", "service", "method", "status_class"),
+		retries:   newMetricFamily("httpx_retries_total", "Retries scheduled by httpx.", "counter", "service", "method", "code"),
+		rejected:  newMetricFamily("httpx_rejections_total", "Attempts rejected by the timeout budget.", "counter", "service", "method", "reason"),
+		discovery: newMetricFamily("httpx_discovery_failures_total", "Attempts failed by service discovery.", "counter", "service", "code"),
+	}
+}
+
+func newMetricFamily(name string, help string, typ string, labels ...string) *metricFamily {
+	return &metricFamily{name: name, help: help, typ: typ, labels: labels, series: map[string]*metricSeries{}}
+}
+
+// Observe implements MetricsHook.
+func (r *MetricsRegistry) Observe(event MetricsEvent) {
+	code := strconv.Itoa(event.Code)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch event.Kind {
+	case MetricsRequest:
+		r.requests.get(event.Service, event.Method, event.StatusClass, code).value++
+		s := r.latency.get(event.Service, event.Method, event.StatusClass)
+		if s.buckets == nil {
+			s.buckets = make([]uint64, len(r.buckets))
+		}
+		seconds := event.Duration.Seconds()
+		for i, bound := range r.buckets {
+			if seconds <= bound {
+				s.buckets[i]++
+			}
+		}
+		s.sum += seconds
+		s.count++
+	case MetricsRetry:
+		r.retries.get(event.Service, event.Method, code).value++
+	case MetricsBudgetExhausted:
+		r.rejected.get(event.Service, event.Method, string(event.Kind)).value++
+	case MetricsDiscoveryFailure:
+		r.discovery.get(event.Service, code).value++
+	}
+}
+
+func (f *metricFamily) get(values ...string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{values: values}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WritePrometheus writes all series in the Prometheus text exposition format.
+func (r *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	r.mu.Lock()
+	for _, f := range []*metricFamily{r.requests, r.latency, r.retries, r.rejected, r.discovery} {
+		r.writeFamily(bw, f)
+	}
+	r.mu.Unlock()
+	return bw.Flush()
+}
+
+// ServeHTTP serves the metrics for a Prometheus scrape.
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.WritePrometheus(w)
+}
+
+func (r *MetricsRegistry) writeFamily(w *bufio.Writer, f *metricFamily) {
+	if len(f.series) == 0 {
+		return
+	}
+	w.WriteString("# HELP " + f.name + " " + f.help + "\n")
+	w.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		labels := formatLabels(f.labels, s.values)
+		if f.typ != "histogram" {
+			writeSample(w, f.name, labels, s.value)
+			continue
+		}
+		for i, bound := range r.buckets {
+			writeSample(w, f.name+"_bucket", appendLabel(labels, "le", formatFloat(bound)), float64(s.buckets[i]))
+		}
+		writeSample(w, f.name+"_bucket", appendLabel(labels, "le", "+Inf"), float64(s.count))
+		writeSample(w, f.name+"_sum", labels, s.sum)
+		writeSample(w, f.name+"_count", labels, float64(s.count))
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatLabels(names []string, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func appendLabel(labels string, name string, value string) string {
+	pair := name + `="` + value + `"`
+	if labels == "" {
+		return pair
+	}
+	return labels + "," + pair
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,180 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	mu     sync.Mutex
+	events []MetricsEvent
+}
+
+func (r *recordingMetrics) Observe(event MetricsEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingMetrics) kinds() []MetricsEventKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kinds := make([]MetricsEventKind, 0, len(r.events))
+	for _, event := range r.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+func TestMetricsReportsAttemptsAndRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	hook := &recordingMetrics{}
+
+	err := Get(server.URL,
+		Metrics(hook),
+		RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	).Do()
+	require.NoError(t, err)
+
+	require.Equal(t, []MetricsEventKind{MetricsRequest, MetricsRetry, MetricsRequest}, hook.kinds())
+	first, retry, last := hook.events[0], hook.events[1], hook.events[2]
+	require.Equal(t, strings.TrimPrefix(server.URL, "http://"), first.Service)
+	require.Equal(t, http.MethodGet, first.Method)
+	require.Equal(t, "5xx", first.StatusClass)
+	require.NotZero(t, first.Code)
+	require.Equal(t, first.Code, retry.Code)
+	require.Equal(t, "2xx", last.StatusClass)
+	require.Zero(t, last.Code)
+	require.Positive(t, last.Duration)
+}
+
+func TestMetricsDoesNotCountRetriesCutByDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	hook := &recordingMetrics{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	err := Get(server.URL,
+		Context(ctx),
+		Metrics(hook),
+		RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+		Retry(&RetryOpt{Attempts: 3, BaseDelay: time.Minute, MaxDelay: time.Minute}),
+	).Do()
+	require.Error(t, err)
+	require.Equal(t, []MetricsEventKind{MetricsRequest}, hook.kinds())
+}
+
+func TestMetricsReportsRejections(t *testing.T) {
+	t.Run("timeout budget", func(t *testing.T) {
+		hook := &recordingMetrics{}
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		err := Get("http://example.invalid", Context(ctx), Metrics(hook)).Do()
+
+		require.ErrorIs(t, err, ErrTimeoutBudgetExhausted)
+		require.Equal(t, []MetricsEventKind{MetricsBudgetExhausted}, hook.kinds())
+		require.Equal(t, ErrCodeHTTPTimeoutBudgetExhausted, hook.events[0].Code)
+		require.Equal(t, "none", hook.events[0].StatusClass)
+	})
+
+	t.Run("discovery failure", func(t *testing.T) {
+		hook := &recordingMetrics{}
+		resolver := ResolverFunc(func(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+			return nil, ErrServiceNotFound
+		})
+
+		err := Get("http://inventory.prod/api",
+			Metrics(hook),
+			Service(ServiceOptions{ServiceName: "inventory", Namespace: "prod", EnableDiscovery: true, Resolver: resolver}),
+		).Do()
+
+		require.ErrorIs(t, err, ErrServiceNotFound)
+		require.Equal(t, []MetricsEventKind{MetricsDiscoveryFailure}, hook.kinds())
+		require.Equal(t, "inventory", hook.events[0].Service)
+		require.Equal(t, ErrCodeHTTPServiceNotFound, hook.events[0].Code)
+	})
+}
+
+func TestMetricsRegistryWritesPrometheusText(t *testing.T) {
+	registry := NewMetricsRegistry(0.1, 1)
+	registry.Observe(MetricsEvent{Kind: MetricsRequest, Service: "inventory", Method: "GET", StatusClass: "2xx", Duration: 50 * time.Millisecond})
+	registry.Observe(MetricsEvent{Kind: MetricsRequest, Service: "inventory", Method: "GET", StatusClass: "2xx", Duration: 500 * time.Millisecond})
+	registry.Observe(MetricsEvent{Kind: MetricsRequest, Service: `in"ventory`, Method: "GET", StatusClass: "5xx", Code: datax.ErrCodeUnexpected, Duration: 2 * time.Second})
+	registry.Observe(MetricsEvent{Kind: MetricsRetry, Service: "inventory", Method: "GET", Code: datax.ErrCodeUnexpected})
+	registry.Observe(MetricsEvent{Kind: MetricsBudgetExhausted, Service: "inventory", Method: "POST", Code: ErrCodeHTTPTimeoutBudgetExhausted})
+	registry.Observe(MetricsEvent{Kind: MetricsDiscoveryFailure, Service: "inventory", Method: "GET", Code: ErrCodeHTTPServiceNotFound})
+
+	var buf bytes.Buffer
+	require.NoError(t, registry.WritePrometheus(&buf))
+
+	require.Equal(t, `# HELP httpx_requests_total HTTP attempts sent by httpx.
+# TYPE httpx_requests_total counter
+httpx_requests_total{service="in\"ventory",method="GET",status_class="5xx",code="10000"} 1
+httpx_requests_total{service="inventory",method="GET",status_class="2xx",code="0"} 2
+# HELP httpx_request_duration_seconds Latency of HTTP attempts sent by httpx.
+# TYPE httpx_request_duration_seconds histogram
+httpx_request_duration_seconds_bucket{service="in\"ventory",method="GET",status_class="5xx",le="0.1"} 0
+httpx_request_duration_seconds_bucket{service="in\"ventory",method="GET",status_class="5xx",le="1"} 0
+httpx_request_duration_seconds_bucket{service="in\"ventory",method="GET",status_class="5xx",le="+Inf"} 1
+httpx_request_duration_seconds_sum{service="in\"ventory",method="GET",status_class="5xx"} 2
+httpx_request_duration_seconds_count{service="in\"ventory",method="GET",status_class="5xx"} 1
+httpx_request_duration_seconds_bucket{service="inventory",method="GET",status_class="2xx",le="0.1"} 1
+httpx_request_duration_seconds_bucket{service="inventory",method="GET",status_class="2xx",le="1"} 2
+httpx_request_duration_seconds_bucket{service="inventory",method="GET",status_class="2xx",le="+Inf"} 2
+httpx_request_duration_seconds_sum{service="inventory",method="GET",status_class="2xx"} 0.55
+httpx_request_duration_seconds_count{service="inventory",method="GET",status_class="2xx"} 2
+# HELP httpx_retries_total Retries scheduled by httpx.
+# TYPE httpx_retries_total counter
+httpx_retries_total{service="inventory",method="GET",code="10000"} 1
+# HELP httpx_rejections_total Attempts rejected by the timeout budget.
+# TYPE httpx_rejections_total counter
+httpx_rejections_total{service="inventory",method="POST",reason="timeout_budget_exhausted"} 1
+# HELP httpx_discovery_failures_total Attempts failed by service discovery.
+# TYPE httpx_discovery_failures_total counter
+httpx_discovery_failures_total{service="inventory",code="10112"} 1
+`, buf.String())
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Type"), "version=0.0.4")
+	require.Equal(t, buf.String(), recorder.Body.String())
+}
+
+func TestDefaultMetricsIsUsedWithoutOption(t *testing.T) {
+	hook := &recordingMetrics{}
+	DefaultMetrics = hook
+	defer func() { DefaultMetrics = nil }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	require.NoError(t, Get(server.URL).Do())
+	require.Equal(t, []MetricsEventKind{MetricsRequest}, hook.kinds())
+
+	err := Get(server.URL, Metrics(MetricsHookFunc(func(MetricsEvent) {}))).Do()
+	require.NoError(t, err)
+	require.Len(t, hook.kinds(), 1)
+}
@@ -0,0 +1,244 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const defaultFileContentType = "application/octet-stream"
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// MultipartPart is one part of a multipart/form-data request body.
+type MultipartPart struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	value       string
+	path        string
+	reader      io.Reader
+	body        ReplayableBody
+}
+
+// MultipartField returns a plain form field part.
+func MultipartField(name string, value string) MultipartPart {
+	return MultipartPart{fieldName: name, value: value}
+}
+
+// MultipartFile returns a file part read from path on every attempt.
+// The file name is the base of path and the content type is derived from its extension.
+func MultipartFile(fieldName string, path string) MultipartPart {
+	return MultipartPart{fieldName: fieldName, fileName: filepath.Base(path), path: path}
+}
+
+// MultipartReader returns a file part read from r. Seekable readers are rewound on
+// retries; other readers are read once and replayed like ReaderReq bodies.
+func MultipartReader(fieldName string, fileName string, r io.Reader) MultipartPart {
+	return MultipartPart{fieldName: fieldName, fileName: fileName, reader: r}
+}
+
+// MultipartBody returns a file part backed by a replayable body.
+func MultipartBody(fieldName string, fileName string, body ReplayableBody) MultipartPart {
+	return MultipartPart{fieldName: fieldName, fileName: fileName, body: body}
+}
+
+// WithContentType overrides the part content type.
+func (p MultipartPart) WithContentType(contentType string) MultipartPart {
+	p.contentType = contentType
+	return p
+}
+
+func (p MultipartPart) header() textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.fieldName))
+	if p.fileName != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.fileName))
+	}
+	h.Set("Content-Disposition", disposition)
+	contentType := p.contentType
+	if contentType == "" && p.fileName != "" {
+		contentType = mime.TypeByExtension(filepath.Ext(p.fileName))
+		if contentType == "" {
+			contentType = defaultFileContentType
+		}
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	return h
+}
+
+// MultipartReq sends a multipart/form-data body. Parts are streamed to the
+// connection without buffering whole files, and every attempt reopens them so
+// retries resend the full body. Content-Length is set when every part size is known.
+func MultipartReq(parts ...MultipartPart) AgentOp {
+	var (
+		once    sync.Once
+		body    *multipartBody
+		bodyErr error
+	)
+	return AgentOpFunc(func(agent *Agent) error {
+		agent.reqPreHandlers = append(agent.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
+			once.Do(func() {
+				body, bodyErr = newMultipartBody(agent, parts)
+			})
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			return setReplayableBody(req, "multipart/form-data; boundary="+body.boundary, body)
+		}))
+		return nil
+	})
+}
+
+type multipartBody struct {
+	boundary string
+	headers  []textproto.MIMEHeader
+	parts    []MultipartPart
+	bodies   []ReplayableBody
+	size     int64
+}
+
+func newMultipartBody(agent *Agent, parts []MultipartPart) (*multipartBody, error) {
+	m := &multipartBody{
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+		parts:    parts,
+		headers:  make([]textproto.MIMEHeader, len(parts)),
+		bodies:   make([]ReplayableBody, len(parts)),
+	}
+	for i, part := range parts {
+		m.headers[i] = part.header()
+		var err error
+		switch {
+		case part.body != nil:
+			m.bodies[i] = part.body
+		case part.path != "":
+			m.bodies[i], err = FileBody(part.path)
+		case part.reader != nil:
+			m.bodies[i], err = newReplayableBody(agent, part.reader)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("multipart part %q failed: %w", part.fieldName, err)
+		}
+	}
+	m.size = m.computeSize()
+	return m, nil
+}
+
+// computeSize adds the framing bytes, measured by writing empty parts, to the part sizes.
+func (m *multipartBody) computeSize() int64 {
+	counter := &countingWriter{}
+	w := m.newWriter(counter)
+	total := int64(0)
+	for i, part := range m.parts {
+		if _, err := w.CreatePart(m.headers[i]); err != nil {
+			return -1
+		}
+		switch {
+		case m.bodies[i] == nil:
+			total += int64(len(part.value))
+		case m.bodies[i].Size() < 0:
+			return -1
+		default:
+			total += m.bodies[i].Size()
+		}
+	}
+	if err := w.Close(); err != nil {
+		return -1
+	}
+	return total + counter.n
+}
+
+func (m *multipartBody) newWriter(dst io.Writer) *multipart.Writer {
+	w := multipart.NewWriter(dst)
+	// The boundary was generated by multipart.Writer and is always valid.
+	_ = w.SetBoundary(m.boundary)
+	return w
+}
+
+// Open implements ReplayableBody by streaming the parts through a pipe. The
+// writer starts on the first Read, so a body that is never sent, e.g. when an
+// interceptor short-circuits the attempt, holds no goroutine or part file.
+func (m *multipartBody) Open() (io.ReadCloser, error) {
+	return &multipartReader{body: m}, nil
+}
+
+// Size implements ReplayableBody.
+func (m *multipartBody) Size() int64 {
+	return m.size
+}
+
+func (m *multipartBody) writeTo(dst io.Writer) error {
+	w := m.newWriter(dst)
+	for i, part := range m.parts {
+		pw, err := w.CreatePart(m.headers[i])
+		if err != nil {
+			return err
+		}
+		if m.bodies[i] == nil {
+			if _, err := io.WriteString(pw, part.value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyReplayable(pw, m.bodies[i]); err != nil {
+			return fmt.Errorf("multipart part %q failed: %w", part.fieldName, err)
+		}
+	}
+	return w.Close()
+}
+
+func copyReplayable(dst io.Writer, body ReplayableBody) error {
+	rc, err := body.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(dst, rc)
+	return err
+}
+
+type multipartReader struct {
+	body *multipartBody
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	r.once.Do(func() {
+		pr, pw := io.Pipe()
+		r.pr = pr
+		go func() {
+			pw.CloseWithError(r.body.writeTo(pw))
+		}()
+	})
+	if r.pr == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return r.pr.Read(p)
+}
+
+func (r *multipartReader) Close() error {
+	// Closing before the first Read keeps the writer from ever starting.
+	r.once.Do(func() {})
+	if r.pr == nil {
+		return nil
+	}
+	return r.pr.Close()
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
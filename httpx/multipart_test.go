@@ -0,0 +1,218 @@
+package httpx
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type onlyReader struct {
+	r io.Reader
+}
+
+func (o onlyReader) Read(p []byte) (int, error) {
+	return o.r.Read(p)
+}
+
+func okResponse(r *http.Request) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true}`)),
+		Request:    r,
+	}
+}
+
+func TestMultipartReqStreamsAndReplaysParts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte("a,b\n1,2\n"), 0o600))
+
+	attempts := 0
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		require.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data; boundary="))
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, r.ContentLength, int64(len(raw)))
+
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		require.Equal(t, "inventory", r.FormValue("service"))
+
+		file, header, err := r.FormFile("report")
+		require.NoError(t, err)
+		content, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "a,b\n1,2\n", string(content))
+		require.Equal(t, "report.csv", header.Filename)
+		require.Equal(t, "text/csv; charset=utf-8", header.Header.Get("Content-Type"))
+
+		file, header, err = r.FormFile("blob")
+		require.NoError(t, err)
+		content, err = io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "blob-data", string(content))
+		require.Equal(t, "application/x-custom", header.Header.Get("Content-Type"))
+
+		if attempts == 1 {
+			return nil, retryableNetError{"dial failed"}
+		}
+		return okResponse(r), nil
+	})}
+
+	var resp map[string]bool
+	err := Post("http://example.test",
+		Client(client),
+		MultipartReq(
+			MultipartField("service", "inventory"),
+			MultipartFile("report", path),
+			MultipartReader("blob", "blob.bin", strings.NewReader("blob-data")).WithContentType("application/x-custom"),
+		),
+		JSONResp(&resp),
+		Retry(&RetryOpt{Attempts: 2, Idempotent: true, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	).Do()
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+	require.True(t, resp["ok"])
+}
+
+func TestMultipartReqFailsOnMissingFile(t *testing.T) {
+	err := Post("http://example.test",
+		MultipartReq(MultipartFile("report", filepath.Join(t.TempDir(), "missing.csv"))),
+	).Do()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `multipart part "report" failed`)
+}
+
+func TestSpillBodyReplaysFromDisk(t *testing.T) {
+	payload := strings.Repeat("0123456789", 10)
+	body := NewSpillBody(onlyReader{strings.NewReader(payload)}, 16)
+	require.Equal(t, int64(-1), body.Size())
+
+	for i := 0; i < 2; i++ {
+		rc, err := body.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		require.Equal(t, payload, string(content))
+	}
+	require.Equal(t, int64(len(payload)), body.Size())
+	require.NotNil(t, body.file)
+	name := body.file.Name()
+	require.NoError(t, body.Close())
+	_, err := os.Stat(name)
+	require.True(t, os.IsNotExist(err))
+	_, err = body.Open()
+	require.ErrorIs(t, err, ErrBodyReleased)
+
+	_, err = NewSpillBody(iotest.ErrReader(io.ErrUnexpectedEOF), 0).Open()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReaderReqReplaysNonSeekableReader(t *testing.T) {
+	attempts := 0
+	var getBody func() (io.ReadCloser, error)
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "streamed-body", string(raw))
+		require.NotNil(t, r.GetBody)
+		getBody = r.GetBody
+		if attempts == 1 {
+			return nil, retryableNetError{"dial failed"}
+		}
+		return okResponse(r), nil
+	})}
+
+	var resp map[string]bool
+	err := Post("http://example.test",
+		Client(client),
+		ReaderReq("application/custom", onlyReader{strings.NewReader("streamed-body")}),
+		JSONResp(&resp),
+		Retry(&RetryOpt{Attempts: 2, Idempotent: true, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+	).Do()
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	rc, err := getBody()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, "streamed-body", string(raw))
+}
+
+func TestReaderReqSpilledBodyIsReleasedAfterDo(t *testing.T) {
+	var sizes []int
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		raw, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		sizes = append(sizes, len(raw))
+		return okResponse(r), nil
+	})}
+
+	payload := strings.Repeat("x", defaultSpillMemoryLimit+1024)
+	agent := Post("http://example.test", Client(client), ReaderReq("text/plain", onlyReader{strings.NewReader(payload)}))
+	require.NoError(t, agent.Do())
+	err := agent.Do()
+	require.ErrorIs(t, err, ErrBodyReleased, "a second call fails instead of reading a removed spill file")
+	require.Equal(t, []int{len(payload)}, sizes)
+
+	small := Post("http://example.test", Client(client), ReaderReq("text/plain", onlyReader{strings.NewReader("small")}))
+	require.NoError(t, small.Do())
+	require.NoError(t, small.Do(), "in-memory bodies stay replayable")
+	require.Equal(t, []int{len(payload), 5, 5}, sizes)
+}
+
+type countingBody struct {
+	ReplayableBody
+	opens atomic.Int32
+}
+
+func (c *countingBody) Open() (io.ReadCloser, error) {
+	c.opens.Add(1)
+	return c.ReplayableBody.Open()
+}
+
+func TestMultipartBodyStartsWriterOnFirstRead(t *testing.T) {
+	seekable, err := SeekableBody(strings.NewReader("part-content"))
+	require.NoError(t, err)
+	part := &countingBody{ReplayableBody: seekable}
+
+	stub := InterceptorFunc(func(attempt *Attempt, _ Invoker) (*http.Response, error) {
+		return okResponse(attempt.Request), nil
+	})
+	require.NoError(t, Post("http://unreachable.invalid",
+		Interceptors(stub),
+		MultipartReq(MultipartBody("file", "a.txt", part)),
+	).Do())
+	require.Zero(t, part.opens.Load(), "a short-circuited attempt never starts the part writer")
+
+	body, err := newMultipartBody(nil, []MultipartPart{MultipartBody("file", "a.txt", part)})
+	require.NoError(t, err)
+	rc, err := body.Open()
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	_, err = rc.Read(make([]byte, 1))
+	require.ErrorIs(t, err, io.ErrClosedPipe)
+	require.Zero(t, part.opens.Load())
+
+	rc, err = body.Open()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.Contains(t, string(raw), "part-content")
+	require.NoError(t, rc.Close())
+	require.Equal(t, int32(1), part.opens.Load())
+}
@@ -0,0 +1,72 @@
+package httpx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// NDJSONIter lazily decodes newline-delimited JSON (JSON lines) items from a
+// response body, typically one returned by DoStream. It is not safe for concurrent use.
+//
+//	resp, err := httpx.Get(url).DoStream()
+//	if err != nil { ... }
+//	it := httpx.NewNDJSONIter[Item](resp)
+//	defer it.Close()
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type NDJSONIter[T any] struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+	item T
+	err  error
+	done bool
+}
+
+// NewNDJSONIter returns an iterator over the items of resp.Body.
+// Closing the iterator closes the body.
+func NewNDJSONIter[T any](resp *http.Response) *NDJSONIter[T] {
+	return NewNDJSONReader[T](resp.Body)
+}
+
+// NewNDJSONReader returns an iterator over the items of body.
+func NewNDJSONReader[T any](body io.ReadCloser) *NDJSONIter[T] {
+	return &NDJSONIter[T]{body: body, dec: json.NewDecoder(body)}
+}
+
+// Next decodes the next item. It returns false at the end of the stream or on error.
+func (it *NDJSONIter[T]) Next() bool {
+	if it.done {
+		return false
+	}
+	var item T
+	if err := it.dec.Decode(&item); err != nil {
+		it.done = true
+		if !errors.Is(err, io.EOF) {
+			it.err = fmt.Errorf("decode ndjson item failed: %w", err)
+		}
+		return false
+	}
+	it.item = item
+	return true
+}
+
+// Item returns the item decoded by the last successful Next.
+func (it *NDJSONIter[T]) Item() T {
+	return it.item
+}
+
+// Err returns the first decode or read error, if any.
+func (it *NDJSONIter[T]) Err() error {
+	return it.err
+}
+
+// Close stops the iteration and closes the body.
+func (it *NDJSONIter[T]) Close() error {
+	it.done = true
+	return it.body.Close()
+}
@@ -0,0 +1,311 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+const (
+	// DefaultTokenExpiryLeeway is how long before expiry a cached token is refreshed.
+	DefaultTokenExpiryLeeway = 30 * time.Second
+
+	maxTokenResponseSize = 1 << 20
+)
+
+// Token is an OAuth2 access token. Its String and GoString methods redact the
+// access token so it is never written to logs by accident.
+type Token struct {
+	// AccessToken is the credential sent in the Authorization header.
+	AccessToken string
+	// TokenType is the authorization scheme. The default is Bearer.
+	TokenType string
+	// Expiry is when the token expires. A zero Expiry never expires.
+	Expiry time.Time
+}
+
+// String implements fmt.Stringer without revealing the access token.
+func (t Token) String() string {
+	return fmt.Sprintf("Token{type=%s expiry=%s access_token=REDACTED}", t.typeOrDefault(), t.Expiry.Format(time.RFC3339))
+}
+
+// GoString implements fmt.GoStringer without revealing the access token.
+func (t Token) GoString() string {
+	return t.String()
+}
+
+// Valid reports whether the token is usable for at least leeway more.
+func (t *Token) Valid(now time.Time, leeway time.Duration) bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || now.Add(leeway).Before(t.Expiry)
+}
+
+// AuthorizationValue returns the Authorization header value of the token.
+func (t *Token) AuthorizationValue() string {
+	return t.typeOrDefault() + " " + t.AccessToken
+}
+
+func (t Token) typeOrDefault() string {
+	if t.TokenType == "" || strings.EqualFold(t.TokenType, "bearer") {
+		return "Bearer"
+	}
+	return t.TokenType
+}
+
+// TokenSource returns access tokens. Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenSourceFunc adapts a function into a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (*Token, error)
+
+// Token implements TokenSource.
+func (f TokenSourceFunc) Token(ctx context.Context) (*Token, error) {
+	return f(ctx)
+}
+
+// TokenInvalidator is implemented by token sources that can drop a token
+// rejected by the upstream, e.g. after a 401 response.
+type TokenInvalidator interface {
+	Invalidate(token *Token)
+}
+
+// CachedTokenSource caches the token of Source until Leeway before it expires.
+// Concurrent callers share one refresh; every caller still waits with its own
+// context. Use NewCachedTokenSource to create it.
+type CachedTokenSource struct {
+	source TokenSource
+	leeway time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	token    *Token
+	inflight *tokenRefresh
+}
+
+type tokenRefresh struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// NewCachedTokenSource wraps source with a cache. A non-positive leeway uses DefaultTokenExpiryLeeway.
+func NewCachedTokenSource(source TokenSource, leeway time.Duration) *CachedTokenSource {
+	if leeway <= 0 {
+		leeway = DefaultTokenExpiryLeeway
+	}
+	return &CachedTokenSource{source: source, leeway: leeway, now: time.Now}
+}
+
+// Token implements TokenSource.
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	if s.token.Valid(s.now(), s.leeway) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	refresh := s.inflight
+	if refresh == nil {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		s.inflight = refresh
+		// The refresh outlives a canceled caller so waiting callers still get
+		// the token; it keeps the caller values, including its deadline budget.
+		go s.refresh(context.WithoutCancel(ctx), refresh)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-refresh.done:
+		return refresh.token, refresh.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *CachedTokenSource) refresh(ctx context.Context, refresh *tokenRefresh) {
+	token, err := s.source.Token(ctx)
+	if err == nil && (token == nil || token.AccessToken == "") {
+		err = datax.NewError(datax.ErrCodeUnexpected, "httpx: token source returned an empty token", nil)
+	}
+	s.mu.Lock()
+	if err == nil {
+		s.token = token
+	}
+	s.inflight = nil
+	s.mu.Unlock()
+	refresh.token, refresh.err = token, err
+	close(refresh.done)
+}
+
+// Invalidate implements TokenInvalidator. The cache is only dropped when it
+// still holds token, so a rejected old token does not discard a newer one.
+func (s *CachedTokenSource) Invalidate(token *Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token != nil && s.token != nil && s.token.AccessToken == token.AccessToken {
+		s.token = nil
+	}
+}
+
+// ClientCredentialsConfig configures the OAuth2 client-credentials grant.
+// ClientSecret is sensitive and should be loaded from the environment.
+type ClientCredentialsConfig struct {
+	TokenURL     string   `json:"token_url" yaml:"token_url" mapstructure:"token_url"`
+	ClientID     string   `json:"client_id" yaml:"client_id" mapstructure:"client_id"`
+	ClientSecret string   `json:"client_secret" yaml:"client_secret" mapstructure:"client_secret"`
+	Scopes       []string `json:"scopes" yaml:"scopes" mapstructure:"scopes"`
+	// Audience is sent as the audience parameter when set.
+	Audience string `json:"audience" yaml:"audience" mapstructure:"audience"`
+	// SecretInBody sends the client credentials as form fields instead of HTTP basic auth.
+	SecretInBody bool `json:"secret_in_body" yaml:"secret_in_body" mapstructure:"secret_in_body"`
+	// Leeway is how long before expiry the token is refreshed. The default is DefaultTokenExpiryLeeway.
+	Leeway time.Duration `json:"leeway" yaml:"leeway" mapstructure:"leeway"`
+}
+
+// NewClientCredentialsSource returns a cached, single-flight token source for
+// the client-credentials grant. ops are applied to every token request, e.g.
+// Client or Retry.
+func NewClientCredentialsSource(cfg ClientCredentialsConfig, ops ...AgentOp) (*CachedTokenSource, error) {
+	if cfg.TokenURL == "" || cfg.ClientID == "" {
+		return nil, datax.NewValidationError("client credentials require token_url and client_id", nil, nil)
+	}
+	source := &clientCredentialsSource{cfg: cfg, ops: ops}
+	return NewCachedTokenSource(source, cfg.Leeway), nil
+}
+
+type clientCredentialsSource struct {
+	cfg ClientCredentialsConfig
+	ops []AgentOp
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Token implements TokenSource.
+func (s *clientCredentialsSource) Token(ctx context.Context) (*Token, error) {
+	values := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		values.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.Audience != "" {
+		values.Set("audience", s.cfg.Audience)
+	}
+	ops := []AgentOp{Context(ctx)}
+	if s.cfg.SecretInBody {
+		values.Set("client_id", s.cfg.ClientID)
+		values.Set("client_secret", s.cfg.ClientSecret)
+	} else {
+		ops = append(ops, AgentOpFunc(func(agent *Agent) error {
+			agent.reqPreHandlers = append(agent.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
+				req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+				return req, nil
+			}))
+			return nil
+		}))
+	}
+	var (
+		body     tokenResponse
+		received = time.Now()
+	)
+	ops = append(ops, FormReq(values), CustomRespHandler(&body))
+	ops = append(ops, s.ops...)
+	if err := Post(s.cfg.TokenURL, ops...).Do(); err != nil {
+		return nil, fmt.Errorf("httpx: fetch client credentials token failed: %w", err)
+	}
+	if body.Error != "" {
+		return nil, datax.NewError(datax.ErrCodeUnexpected, fmt.Sprintf("httpx: token endpoint error %s: %s", body.Error, body.ErrorDescription), nil)
+	}
+	token := &Token{AccessToken: body.AccessToken, TokenType: body.TokenType}
+	if body.ExpiresIn > 0 {
+		token.Expiry = received.Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// HandleResponse implements RespHandler. Errors never include the body because it carries the token.
+func (body *tokenResponse) HandleResponse(resp *http.Response, _ Wrapper) error {
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseSize))
+	if err != nil {
+		return fmt.Errorf("read token response failed: %w", err)
+	}
+	if err := json.Unmarshal(content, body); err != nil {
+		return datax.NewError(datax.ErrCodeUnexpected, "httpx: token response is not valid JSON", nil)
+	}
+	return nil
+}
+
+// TokenAuth authorizes every attempt with a token from source. When the
+// upstream answers 401 the token is invalidated and the attempt is sent once
+// more with a fresh token, provided the request body can be replayed.
+func TokenAuth(source TokenSource) AgentOpFunc {
+	return Interceptors(NewTokenAuthInterceptor(source))
+}
+
+// NewTokenAuthInterceptor returns the interceptor used by TokenAuth, e.g. for Profile.Interceptors.
+func NewTokenAuthInterceptor(source TokenSource) Interceptor {
+	return InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+		ctx := attempt.Request.Context()
+		token, err := source.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("httpx: get auth token failed: %w", err)
+		}
+		attempt.Request.Header.Set("Authorization", token.AuthorizationValue())
+		resp, err := next(attempt)
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+		invalidator, ok := source.(TokenInvalidator)
+		if !ok {
+			return resp, nil
+		}
+		invalidator.Invalidate(token)
+		retry, err := replayRequest(attempt.Request)
+		if err != nil || retry == nil {
+			return resp, nil
+		}
+		fresh, err := source.Token(ctx)
+		if err != nil {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxTokenResponseSize))
+		_ = resp.Body.Close()
+		retry.Header.Set("Authorization", fresh.AuthorizationValue())
+		replayed := *attempt
+		replayed.Request = retry
+		return next(&replayed)
+	})
+}
+
+// replayRequest clones req with a fresh body. It returns nil when the body cannot be replayed.
+func replayRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
@@ -0,0 +1,143 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCredentialsTokenAuth(t *testing.T) {
+	var issued atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "billing", id)
+		require.Equal(t, "s3cret", secret)
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		require.Equal(t, "read write", r.PostForm.Get("scope"))
+		n := issued.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		_, _ = fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	var rejectFirst atomic.Bool
+	rejectFirst.Store(true)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") == "Bearer token-1" && r.URL.Path == "/rotate" && rejectFirst.CompareAndSwap(true, false) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, "%s|%s", r.Header.Get("Authorization"), body)
+	}))
+	defer api.Close()
+
+	source, err := NewClientCredentialsSource(ClientCredentialsConfig{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "billing",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var body []byte
+			require.NoError(t, Get(api.URL+"/cached", TokenAuth(source), RawResp(nil, &body)).Do())
+			require.Equal(t, "Bearer token-1|", string(body))
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), issued.Load(), "concurrent callers share one refresh")
+
+	var body []byte
+	require.NoError(t, Post(api.URL+"/rotate", TokenAuth(source), TextReq("payload"), RawResp(nil, &body)).Do())
+	require.Equal(t, "Bearer token-2|payload", string(body), "401 retries once with a fresh token and replays the body")
+	require.Equal(t, int32(2), issued.Load())
+}
+
+func TestTokenAuthRetriesOnlyOnce(t *testing.T) {
+	var calls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer api.Close()
+
+	var fetched atomic.Int32
+	source := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		return &Token{AccessToken: fmt.Sprintf("t%d", fetched.Add(1)), Expiry: time.Now().Add(time.Hour)}, nil
+	}), 0)
+	err := Get(api.URL, TokenAuth(source)).Do()
+	require.Error(t, err)
+	require.Equal(t, int32(2), calls.Load())
+	require.Equal(t, int32(2), fetched.Load())
+}
+
+func TestCachedTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var fetched int
+	source := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		fetched++
+		return &Token{AccessToken: fmt.Sprintf("t%d", fetched), Expiry: now.Add(time.Minute)}, nil
+	}), 10*time.Second)
+	source.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	token, err := source.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "t1", token.AccessToken)
+
+	now = now.Add(45 * time.Second)
+	token, err = source.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "t1", token.AccessToken)
+
+	now = now.Add(10 * time.Second)
+	token, err = source.Token(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "t2", token.AccessToken, "refreshed within the leeway")
+
+	source.Invalidate(&Token{AccessToken: "t1"})
+	token, _ = source.Token(ctx)
+	require.Equal(t, "t2", token.AccessToken, "invalidating an old token keeps the newer one")
+}
+
+func TestCachedTokenSourceRespectsCallerContext(t *testing.T) {
+	release := make(chan struct{})
+	source := NewCachedTokenSource(TokenSourceFunc(func(ctx context.Context) (*Token, error) {
+		<-release
+		return &Token{AccessToken: "late"}, nil
+	}), 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := source.Token(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "late", token.AccessToken)
+}
+
+func TestTokenIsRedacted(t *testing.T) {
+	token := &Token{AccessToken: "very-secret", Expiry: time.Unix(0, 0)}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		require.False(t, strings.Contains(fmt.Sprintf(format, token), "very-secret"), format)
+		require.False(t, strings.Contains(fmt.Sprintf(format, *token), "very-secret"), format)
+	}
+}
@@ -0,0 +1,745 @@
+package openapi
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+// Options configures Generate.
+type Options struct {
+	// Package is the Go package name of the generated file.
+	Package string
+	// ClientName is the generated client type name. The default is "Client".
+	ClientName string
+	// CommonWrapper decodes every JSON response from the data field of httpx.CommonWrapper.
+	CommonWrapper bool
+	// Source is mentioned in the generated file header, e.g. the document file name.
+	Source string
+}
+
+// Generate renders a typed client for doc. Every operation becomes a client
+// method that sends the call through an httpx.Profile, so base URL, discovery,
+// retries and interceptors are configured on the profile.
+func Generate(doc *Document, opt Options) ([]byte, error) {
+	if opt.Package == "" {
+		return nil, datax.NewValidationError("openapi generate requires a package name", nil, nil)
+	}
+	if opt.ClientName == "" {
+		opt.ClientName = "Client"
+	}
+	g := &generator{doc: doc, opt: opt, defined: map[string]bool{}, imports: map[string]bool{}}
+	for _, name := range sortedKeys(doc.Components.Schemas) {
+		if err := g.defineNamed(goName(name), doc.Components.Schemas[name]); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	var methods strings.Builder
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		for _, op := range []struct {
+			method string
+			op     *Operation
+		}{
+			{"Get", item.Get}, {"Post", item.Post}, {"Put", item.Put}, {"Patch", item.Patch}, {"Delete", item.Delete},
+		} {
+			if op.op == nil {
+				continue
+			}
+			code, err := g.operation(path, op.method, item, op.op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(op.method), path, err)
+			}
+			methods.WriteString(code)
+		}
+	}
+
+	var out strings.Builder
+	source := ""
+	if opt.Source != "" {
+		source = " from " + opt.Source
+	}
+	fmt.Fprintf(&out, "// Code generated by httpx-openapi-gen%s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&out, "package %s\n\n", opt.Package)
+	out.WriteString("import (\n\t\"context\"\n")
+	for _, imp := range []string{"fmt", "net/http", "net/url", "time"} {
+		if g.imports[imp] {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+	}
+	out.WriteString("\n")
+	if g.imports["datax"] {
+		out.WriteString("\t\"github.com/dev-ofa/core-go/model/datax\"\n")
+	}
+	out.WriteString("\t\"github.com/dev-ofa/core-go/httpx\"\n)\n\n")
+	g.writeClient(&out)
+	out.WriteString(methods.String())
+	for _, name := range g.order {
+		out.WriteString(g.types[name])
+	}
+	formatted, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("format generated client failed: %w", err)
+	}
+	return formatted, nil
+}
+
+type generator struct {
+	doc     *Document
+	opt     Options
+	defined map[string]bool
+	types   map[string]string
+	order   []string
+	imports map[string]bool
+}
+
+func (g *generator) writeClient(out *strings.Builder) {
+	title := g.doc.Info.Title
+	if title == "" {
+		title = "the API"
+	}
+	name := g.opt.ClientName
+	fmt.Fprintf(out, "// %s calls %s", name, title)
+	if g.doc.Info.Version != "" {
+		fmt.Fprintf(out, " %s", g.doc.Info.Version)
+	}
+	out.WriteString(".\n")
+	fmt.Fprintf(out, "type %s struct {\n\tprofile *httpx.Profile\n}\n\n", name)
+	fmt.Fprintf(out, "// New%s returns a client sending every call through profile.\n", name)
+	fmt.Fprintf(out, "func New%s(profile *httpx.Profile) *%s {\n\treturn &%s{profile: profile}\n}\n\n", name, name, name)
+	fmt.Fprintf(out, "// New%sWithDiscovery returns a client resolving serviceName.namespace through httpx discovery.\n", name)
+	fmt.Fprintf(out, "func New%sWithDiscovery(serviceName string, namespace string, resolver httpx.Resolver) *%s {\n", name, name)
+	fmt.Fprintf(out, "\treturn New%s(&httpx.Profile{\n", name)
+	out.WriteString("\t\tBaseURL: \"http://\" + serviceName + \".\" + namespace,\n")
+	out.WriteString("\t\tService: &httpx.ServiceOptions{EnableDiscovery: true, ServiceName: serviceName, Namespace: namespace, Resolver: resolver},\n")
+	out.WriteString("\t})\n}\n\n")
+}
+
+type param struct {
+	*Parameter
+	goName  string
+	varName string
+	goType  string
+}
+
+func (g *generator) operation(path string, method string, item *PathItem, op *Operation) (string, error) {
+	name := goName(op.OperationID)
+	if op.OperationID == "" {
+		name = goName(method + " " + path)
+	}
+	params, err := g.parameters(item, op, name)
+	if err != nil {
+		return "", err
+	}
+	var pathParams, otherParams []param
+	for _, p := range params {
+		if p.In == "path" {
+			pathParams = append(pathParams, p)
+		} else {
+			otherParams = append(otherParams, p)
+		}
+	}
+	pathParams, err = orderPathParams(path, pathParams)
+	if err != nil {
+		return "", err
+	}
+
+	bodyType := ""
+	body, err := g.doc.requestBody(op.RequestBody)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		mt := jsonMediaType(body.Content)
+		if mt == nil {
+			return "", datax.NewValidationError("only JSON request bodies are supported", nil, nil)
+		}
+		if bodyType, err = g.goType(mt.Schema, name+"Request"); err != nil {
+			return "", err
+		}
+	}
+	resultType, codes, err := g.result(op, name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	g.writeDoc(&b, name, op)
+	fmt.Fprintf(&b, "func (c *%s) %s(ctx context.Context", g.opt.ClientName, name)
+	for _, p := range pathParams {
+		fmt.Fprintf(&b, ", %s %s", p.varName, p.goType)
+	}
+	paramsType := ""
+	if len(otherParams) > 0 {
+		paramsType = name + "Params"
+		g.defineParams(paramsType, name, otherParams)
+		fmt.Fprintf(&b, ", params %s", paramsType)
+	}
+	if bodyType != "" {
+		fmt.Fprintf(&b, ", body %s", bodyType)
+	}
+	b.WriteString(", ops ...httpx.AgentOp) ")
+	zeroReturn := "return "
+	if resultType != "" {
+		fmt.Fprintf(&b, "(%s, error) {\n", resultType)
+		zeroReturn = "var zero " + resultType + "\n\t\treturn zero, "
+	} else {
+		b.WriteString("error {\n")
+	}
+
+	for _, p := range pathParams {
+		if p.goType == "string" {
+			g.imports["datax"] = true
+			fmt.Fprintf(&b, "\tif %s == \"\" {\n\t\t%sdatax.NewValidationError(%q, nil, nil)\n\t}\n", p.varName, zeroReturn, p.Name+" is required")
+		}
+	}
+	var queryParams, headerParams []param
+	for _, p := range otherParams {
+		switch p.In {
+		case "query":
+			queryParams = append(queryParams, p)
+		case "header":
+			headerParams = append(headerParams, p)
+		}
+	}
+	for _, p := range headerParams {
+		// Empty required headers are rejected like empty path parameters.
+		if p.Required && p.goType == "string" {
+			g.imports["datax"] = true
+			fmt.Fprintf(&b, "\tif params.%s == \"\" {\n\t\t%sdatax.NewValidationError(%q, nil, nil)\n\t}\n", p.goName, zeroReturn, p.Name+" is required")
+		}
+	}
+	fmt.Fprintf(&b, "\tpath := %s\n", g.pathExpr(path, pathParams))
+	if len(queryParams) > 0 {
+		g.imports["net/url"] = true
+		b.WriteString("\tquery := url.Values{}\n")
+		for _, p := range queryParams {
+			g.writeParamSet(&b, "query", p)
+		}
+		b.WriteString("\tif len(query) > 0 {\n\t\tpath += \"?\" + query.Encode()\n\t}\n")
+	}
+	b.WriteString("\tcallOps := []httpx.AgentOp{httpx.Context(ctx)")
+	if len(codes) > 0 && !(len(codes) == 1 && codes[0] == 200) {
+		fmt.Fprintf(&b, ", httpx.ExpectedStatusCodes([]int{%s})", joinInts(codes))
+	}
+	if resultType != "" && g.opt.CommonWrapper {
+		b.WriteString(", httpx.RespWrapper(httpx.NewCommonWrapper())")
+	}
+	if bodyType != "" {
+		b.WriteString(", httpx.JSONReq(body)")
+	}
+	b.WriteString("}\n")
+	if len(headerParams) > 0 {
+		g.imports["net/http"] = true
+		b.WriteString("\theader := http.Header{}\n")
+		for _, p := range headerParams {
+			g.writeParamSet(&b, "header", p)
+		}
+		b.WriteString("\tcallOps = append(callOps, httpx.SetHeader(header))\n")
+	}
+	b.WriteString("\tcallOps = append(callOps, ops...)\n")
+	call := fmt.Sprintf("c.profile.%s(path, callOps...)", method)
+	if resultType != "" {
+		fmt.Fprintf(&b, "\treturn httpx.DoJSON[%s](%s)\n", resultType, call)
+	} else {
+		fmt.Fprintf(&b, "\treturn %s.Do()\n", call)
+	}
+	b.WriteString("}\n\n")
+	return b.String(), nil
+}
+
+func (g *generator) writeDoc(b *strings.Builder, name string, op *Operation) {
+	text := op.Summary
+	if text == "" {
+		text = op.Description
+	}
+	if text == "" {
+		fmt.Fprintf(b, "// %s calls the %s operation.\n", name, name)
+	} else {
+		writeComment(b, "", name+" "+lowerFirst(text))
+	}
+	if op.Deprecated {
+		b.WriteString("//\n// Deprecated: the operation is deprecated by the API.\n")
+	}
+}
+
+func (g *generator) parameters(item *PathItem, op *Operation, opName string) ([]param, error) {
+	merged := map[string]*Parameter{}
+	var keys []string
+	for _, list := range [][]*Parameter{item.Parameters, op.Parameters} {
+		for _, raw := range list {
+			p, err := g.doc.parameter(raw)
+			if err != nil {
+				return nil, err
+			}
+			key := p.In + ":" + p.Name
+			if _, ok := merged[key]; !ok {
+				keys = append(keys, key)
+			}
+			merged[key] = p
+		}
+	}
+	params := make([]param, 0, len(keys))
+	for _, key := range keys {
+		p := merged[key]
+		if p.In == "cookie" {
+			continue
+		}
+		typ, err := g.goType(p.Schema, opName+goName(p.Name))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param{Parameter: p, goName: goName(p.Name), varName: varName(p.Name), goType: typ})
+	}
+	return params, nil
+}
+
+func orderPathParams(path string, params []param) ([]param, error) {
+	byName := map[string]param{}
+	for _, p := range params {
+		byName[p.Name] = p
+	}
+	var ordered []param
+	for _, segment := range pathSegments(path) {
+		if !segment.param {
+			continue
+		}
+		p, ok := byName[segment.text]
+		if !ok {
+			return nil, datax.NewValidationError(fmt.Sprintf("path parameter %s is not declared", segment.text), nil, nil)
+		}
+		ordered = append(ordered, p)
+	}
+	return ordered, nil
+}
+
+type pathSegment struct {
+	text  string
+	param bool
+}
+
+func pathSegments(path string) []pathSegment {
+	var segments []pathSegment
+	for path != "" {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			segments = append(segments, pathSegment{text: path})
+			break
+		}
+		end := strings.IndexByte(path[start:], '}')
+		if end < 0 {
+			segments = append(segments, pathSegment{text: path})
+			break
+		}
+		if start > 0 {
+			segments = append(segments, pathSegment{text: path[:start]})
+		}
+		segments = append(segments, pathSegment{text: path[start+1 : start+end], param: true})
+		path = path[start+end+1:]
+	}
+	return segments
+}
+
+func (g *generator) pathExpr(path string, params []param) string {
+	byName := map[string]param{}
+	for _, p := range params {
+		byName[p.Name] = p
+	}
+	var parts []string
+	for _, segment := range pathSegments(path) {
+		if !segment.param {
+			parts = append(parts, strconv.Quote(segment.text))
+			continue
+		}
+		g.imports["net/url"] = true
+		p := byName[segment.text]
+		if p.goType == "string" {
+			parts = append(parts, fmt.Sprintf("url.PathEscape(%s)", p.varName))
+		} else {
+			g.imports["fmt"] = true
+			parts = append(parts, fmt.Sprintf("url.PathEscape(fmt.Sprint(%s))", p.varName))
+		}
+	}
+	if len(parts) == 0 {
+		return `""`
+	}
+	return strings.Join(parts, " + ")
+}
+
+func (g *generator) writeParamSet(b *strings.Builder, target string, p param) {
+	field := "params." + p.goName
+	value := func(expr string, typ string) string {
+		if typ == "string" {
+			return expr
+		}
+		g.imports["fmt"] = true
+		return "fmt.Sprint(" + expr + ")"
+	}
+	switch {
+	case strings.HasPrefix(p.goType, "[]"):
+		fmt.Fprintf(b, "\tfor _, v := range %s {\n\t\t%s.Add(%q, %s)\n\t}\n", field, target, p.Name, value("v", strings.TrimPrefix(p.goType, "[]")))
+	case strings.HasPrefix(p.goType, "*"):
+		fmt.Fprintf(b, "\tif %s != nil {\n\t\t%s.Set(%q, %s)\n\t}\n", field, target, p.Name, value("*"+field, strings.TrimPrefix(p.goType, "*")))
+	default:
+		fmt.Fprintf(b, "\t%s.Set(%q, %s)\n", target, p.Name, value(field, p.goType))
+	}
+}
+
+func (g *generator) defineParams(typeName string, opName string, params []param) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s holds the query and header parameters of %s.\n", typeName, opName)
+	fmt.Fprintf(&b, "type %s struct {\n", typeName)
+	for i := range params {
+		p := &params[i]
+		if !p.Required && !strings.HasPrefix(p.goType, "[]") {
+			p.goType = "*" + p.goType
+		}
+		if p.Description != "" {
+			writeComment(&b, "\t", p.goName+" is "+lowerFirst(p.Description))
+		}
+		fmt.Fprintf(&b, "\t%s %s\n", p.goName, p.goType)
+	}
+	b.WriteString("}\n\n")
+	g.addType(typeName, b.String())
+}
+
+// result returns the Go type of the first JSON 2xx response and every declared 2xx code.
+func (g *generator) result(op *Operation, name string) (string, []int, error) {
+	var (
+		resultType string
+		codes      []int
+	)
+	for _, code := range sortedKeys(op.Responses) {
+		status, err := strconv.Atoi(code)
+		if err != nil || status < 200 || status > 299 {
+			continue
+		}
+		codes = append(codes, status)
+		if resultType != "" {
+			continue
+		}
+		resp, err := g.doc.response(op.Responses[code])
+		if err != nil {
+			return "", nil, err
+		}
+		if mt := jsonMediaType(resp.Content); mt != nil && mt.Schema != nil {
+			if resultType, err = g.goType(mt.Schema, name+"Response"); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return resultType, codes, nil
+}
+
+func (g *generator) defineNamed(name string, schema *Schema) error {
+	if g.defined[name] {
+		return nil
+	}
+	switch {
+	case isStruct(schema):
+		return g.defineStruct(name, schema)
+	case isStringEnum(schema):
+		g.defineEnum(name, schema)
+		return nil
+	}
+	g.defined[name] = true
+	typ, err := g.goType(schema, name+"Item")
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	g.writeTypeDoc(&b, name, schema)
+	fmt.Fprintf(&b, "type %s %s\n\n", name, typ)
+	g.addType(name, b.String())
+	return nil
+}
+
+func (g *generator) goType(schema *Schema, hint string) (string, error) {
+	if schema == nil {
+		return "any", nil
+	}
+	if schema.Ref != "" {
+		raw, err := refName(schema.Ref, "#/components/schemas/")
+		if err != nil {
+			return "", err
+		}
+		if _, ok := g.doc.Components.Schemas[raw]; !ok {
+			return "", datax.NewValidationError(fmt.Sprintf("unresolved schema %s", schema.Ref), nil, nil)
+		}
+		return goName(raw), nil
+	}
+	if isStruct(schema) {
+		return hint, g.defineStruct(hint, schema)
+	}
+	if isStringEnum(schema) {
+		g.defineEnum(hint, schema)
+		return hint, nil
+	}
+	switch schema.Type {
+	case "string":
+		switch schema.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time", nil
+		case "byte":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if schema.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if schema.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(schema.Items, hint+"Item")
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object":
+		if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+			value, err := g.goType(schema.AdditionalProperties.Schema, hint+"Value")
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		return "map[string]any", nil
+	}
+	return "any", nil
+}
+
+func (g *generator) defineStruct(name string, schema *Schema) error {
+	if g.defined[name] {
+		return nil
+	}
+	g.defined[name] = true
+	var (
+		embedded   []string
+		properties = map[string]*Schema{}
+		required   = map[string]bool{}
+	)
+	for _, part := range append([]*Schema{schema}, schema.AllOf...) {
+		if part != schema && part.Ref != "" {
+			typ, err := g.goType(part, name)
+			if err != nil {
+				return err
+			}
+			embedded = append(embedded, typ)
+			continue
+		}
+		for k, v := range part.Properties {
+			properties[k] = v
+		}
+		for _, k := range part.Required {
+			required[k] = true
+		}
+	}
+
+	var b strings.Builder
+	g.writeTypeDoc(&b, name, schema)
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	for _, typ := range embedded {
+		fmt.Fprintf(&b, "\t%s\n", typ)
+	}
+	for _, prop := range sortedKeys(properties) {
+		propSchema := properties[prop]
+		field := goName(prop)
+		typ, err := g.goType(propSchema, name+field)
+		if err != nil {
+			return err
+		}
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		if (!required[prop] || propSchema.Nullable) && !strings.HasPrefix(typ, "[]") && !strings.HasPrefix(typ, "map[") && typ != "any" {
+			typ = "*" + typ
+		}
+		if propSchema.Description != "" {
+			writeComment(&b, "\t", field+" is "+lowerFirst(propSchema.Description))
+		}
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", field, typ, tag)
+	}
+	b.WriteString("}\n\n")
+	g.addType(name, b.String())
+	return nil
+}
+
+func (g *generator) defineEnum(name string, schema *Schema) {
+	if g.defined[name] {
+		return
+	}
+	g.defined[name] = true
+	var b strings.Builder
+	g.writeTypeDoc(&b, name, schema)
+	fmt.Fprintf(&b, "type %s string\n\n", name)
+	fmt.Fprintf(&b, "// %s values.\nconst (\n", name)
+	for _, v := range schema.Enum {
+		value := fmt.Sprint(v)
+		fmt.Fprintf(&b, "\t%s%s %s = %q\n", name, goName(value), name, value)
+	}
+	b.WriteString(")\n\n")
+	g.addType(name, b.String())
+}
+
+func (g *generator) writeTypeDoc(b *strings.Builder, name string, schema *Schema) {
+	if schema.Description != "" {
+		writeComment(b, "", name+" is "+lowerFirst(schema.Description))
+		return
+	}
+	fmt.Fprintf(b, "// %s is generated from the OpenAPI document.\n", name)
+}
+
+func (g *generator) addType(name string, code string) {
+	if g.types == nil {
+		g.types = map[string]string{}
+	}
+	g.types[name] = code
+	g.order = append(g.order, name)
+	sort.Strings(g.order)
+}
+
+func isStruct(schema *Schema) bool {
+	return schema != nil && schema.Ref == "" && (len(schema.Properties) > 0 || len(schema.AllOf) > 0)
+}
+
+func isStringEnum(schema *Schema) bool {
+	return schema != nil && schema.Ref == "" && len(schema.Enum) > 0 && (schema.Type == "" || schema.Type == "string")
+}
+
+func writeComment(b *strings.Builder, indent string, text string) {
+	text = strings.TrimSpace(text)
+	if !strings.HasSuffix(text, ".") {
+		text += "."
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(b, "%s// %s\n", indent, strings.TrimSpace(line))
+	}
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+var initialisms = map[string]bool{
+	"API": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"SQL": true, "TLS": true, "TTL": true, "UI": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true, "default": true,
+	"defer": true, "else": true, "fallthrough": true, "for": true, "func": true, "go": true, "goto": true,
+	"if": true, "import": true, "interface": true, "map": true, "package": true, "range": true,
+	"return": true, "select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// reservedVars are local names used by generated methods.
+var reservedVars = map[string]bool{
+	"c": true, "ctx": true, "ops": true, "params": true, "body": true, "path": true,
+	"query": true, "header": true, "callOps": true, "zero": true, "err": true,
+}
+
+func splitWords(s string) []string {
+	var (
+		words   []string
+		current []rune
+	)
+	runes := []rune(s)
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = nil
+		}
+	}
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return words
+}
+
+func goName(s string) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		upper := strings.ToUpper(word)
+		if initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		b.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "X"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+func varName(s string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return "x"
+	}
+	first := strings.ToLower(words[0])
+	name := first + strings.TrimPrefix(goName(strings.Join(words, " ")), goName(words[0]))
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "n" + name
+	}
+	if goKeywords[name] || reservedVars[name] {
+		name += "Param"
+	}
+	return name
+}
+
+func lowerFirst(s string) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) == 0 {
+		return ""
+	}
+	if len(runes) > 1 && unicode.IsUpper(runes[1]) {
+		return string(runes)
+	}
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
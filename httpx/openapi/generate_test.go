@@ -0,0 +1,86 @@
+package openapi
+
+import (
+	"os"
+	"testing"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMatchesGoldenClient(t *testing.T) {
+	doc, err := Load("testdata/petstore.yaml")
+	require.NoError(t, err)
+	code, err := Generate(doc, Options{Package: "petstore", Source: "petstore.yaml"})
+	require.NoError(t, err)
+	golden, err := os.ReadFile("internal/petstore/client_gen.go")
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(code), "run go generate ./httpx/openapi/... to refresh the golden client")
+}
+
+func TestGenerateCommonWrapper(t *testing.T) {
+	doc, err := Load("testdata/petstore.yaml")
+	require.NoError(t, err)
+	code, err := Generate(doc, Options{Package: "petstore", ClientName: "PetClient", CommonWrapper: true})
+	require.NoError(t, err)
+	require.Contains(t, string(code), "type PetClient struct")
+	require.Contains(t, string(code), "httpx.RespWrapper(httpx.NewCommonWrapper())")
+}
+
+func TestGenerateValidatesRequiredHeaders(t *testing.T) {
+	doc, err := Parse([]byte(`
+openapi: 3.0.0
+paths:
+  /x:
+    get:
+      operationId: getX
+      parameters:
+        - name: X-Tenant
+          in: header
+          required: true
+          schema:
+            type: string
+        - name: X-Trace
+          in: header
+          schema:
+            type: string
+      responses:
+        "204":
+          description: ok
+`))
+	require.NoError(t, err)
+	code, err := Generate(doc, Options{Package: "x"})
+	require.NoError(t, err)
+	require.Contains(t, string(code), "if params.XTenant == \"\" {\n\t\treturn datax.NewValidationError(\"X-Tenant is required\", nil, nil)\n\t}")
+	require.NotContains(t, string(code), "params.XTrace == \"\"", "optional headers are not checked")
+}
+
+func TestParseRejectsUnsupportedDocuments(t *testing.T) {
+	_, err := Parse([]byte(`swagger: "2.0"`))
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	doc, err := Parse([]byte(`
+openapi: 3.0.0
+paths:
+  /x:
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Missing"
+`))
+	require.NoError(t, err)
+	_, err = Generate(doc, Options{Package: "x"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestGoName(t *testing.T) {
+	require.Equal(t, "GetPetByID", goName("getPetById"))
+	require.Equal(t, "XRequestSource", goName("X-Request-Source"))
+	require.Equal(t, "HTTPURL", goName("httpURL"))
+	require.Equal(t, "petID", varName("petId"))
+	require.Equal(t, "typeParam", varName("type"))
+}
@@ -0,0 +1,137 @@
+// Code generated by httpx-openapi-gen from petstore.yaml. DO NOT EDIT.
+
+package petstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/dev-ofa/core-go/httpx"
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+// Client calls Petstore 1.0.0.
+type Client struct {
+	profile *httpx.Profile
+}
+
+// NewClient returns a client sending every call through profile.
+func NewClient(profile *httpx.Profile) *Client {
+	return &Client{profile: profile}
+}
+
+// NewClientWithDiscovery returns a client resolving serviceName.namespace through httpx discovery.
+func NewClientWithDiscovery(serviceName string, namespace string, resolver httpx.Resolver) *Client {
+	return NewClient(&httpx.Profile{
+		BaseURL: "http://" + serviceName + "." + namespace,
+		Service: &httpx.ServiceOptions{EnableDiscovery: true, ServiceName: serviceName, Namespace: namespace, Resolver: resolver},
+	})
+}
+
+// ListPets lists pets of the store.
+func (c *Client) ListPets(ctx context.Context, params ListPetsParams, ops ...httpx.AgentOp) (PetPage, error) {
+	if params.XRequestSource == "" {
+		var zero PetPage
+		return zero, datax.NewValidationError("X-Request-Source is required", nil, nil)
+	}
+	path := "/pets"
+	query := url.Values{}
+	if params.Limit != nil {
+		query.Set("limit", fmt.Sprint(*params.Limit))
+	}
+	for _, v := range params.Tag {
+		query.Add("tag", v)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	callOps := []httpx.AgentOp{httpx.Context(ctx)}
+	header := http.Header{}
+	header.Set("X-Request-Source", params.XRequestSource)
+	callOps = append(callOps, httpx.SetHeader(header))
+	callOps = append(callOps, ops...)
+	return httpx.DoJSON[PetPage](c.profile.Get(path, callOps...))
+}
+
+// CreatePet creates a pet.
+func (c *Client) CreatePet(ctx context.Context, body NewPet, ops ...httpx.AgentOp) (Pet, error) {
+	path := "/pets"
+	callOps := []httpx.AgentOp{httpx.Context(ctx), httpx.ExpectedStatusCodes([]int{201}), httpx.JSONReq(body)}
+	callOps = append(callOps, ops...)
+	return httpx.DoJSON[Pet](c.profile.Post(path, callOps...))
+}
+
+// GetPetByID calls the GetPetByID operation.
+func (c *Client) GetPetByID(ctx context.Context, petID string, ops ...httpx.AgentOp) (Pet, error) {
+	if petID == "" {
+		var zero Pet
+		return zero, datax.NewValidationError("petId is required", nil, nil)
+	}
+	path := "/pets/" + url.PathEscape(petID)
+	callOps := []httpx.AgentOp{httpx.Context(ctx)}
+	callOps = append(callOps, ops...)
+	return httpx.DoJSON[Pet](c.profile.Get(path, callOps...))
+}
+
+// DeletePet calls the DeletePet operation.
+//
+// Deprecated: the operation is deprecated by the API.
+func (c *Client) DeletePet(ctx context.Context, petID string, ops ...httpx.AgentOp) error {
+	if petID == "" {
+		return datax.NewValidationError("petId is required", nil, nil)
+	}
+	path := "/pets/" + url.PathEscape(petID)
+	callOps := []httpx.AgentOp{httpx.Context(ctx), httpx.ExpectedStatusCodes([]int{204})}
+	callOps = append(callOps, ops...)
+	return c.profile.Delete(path, callOps...).Do()
+}
+
+// GetStoresStoreIDInventory calls the GetStoresStoreIDInventory operation.
+func (c *Client) GetStoresStoreIDInventory(ctx context.Context, storeID int64, ops ...httpx.AgentOp) (map[string]int64, error) {
+	path := "/stores/" + url.PathEscape(fmt.Sprint(storeID)) + "/inventory"
+	callOps := []httpx.AgentOp{httpx.Context(ctx)}
+	callOps = append(callOps, ops...)
+	return httpx.DoJSON[map[string]int64](c.profile.Get(path, callOps...))
+}
+
+// ListPetsParams holds the query and header parameters of ListPets.
+type ListPetsParams struct {
+	// Limit is maximum number of pets to return.
+	Limit          *int32
+	Tag            []string
+	XRequestSource string
+}
+
+// NewPet is generated from the OpenAPI document.
+type NewPet struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Name   string            `json:"name"`
+	Status *PetStatus        `json:"status,omitempty"`
+	Tag    *string           `json:"tag,omitempty"`
+}
+
+// Pet is a pet in the store.
+type Pet struct {
+	NewPet
+	CreatedAt time.Time `json:"createdAt"`
+	ID        string    `json:"id"`
+}
+
+// PetPage is generated from the OpenAPI document.
+type PetPage struct {
+	Items []Pet `json:"items"`
+	Total int64 `json:"total"`
+}
+
+// PetStatus is generated from the OpenAPI document.
+type PetStatus string
+
+// PetStatus values.
+const (
+	PetStatusAvailable PetStatus = "available"
+	PetStatusPending   PetStatus = "pending"
+	PetStatusSold      PetStatus = "sold"
+)
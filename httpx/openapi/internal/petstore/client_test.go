@@ -0,0 +1,73 @@
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dev-ofa/core-go/httpx"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/pets":
+			require.Equal(t, "5", r.URL.Query().Get("limit"))
+			require.Equal(t, []string{"a", "b"}, r.URL.Query()["tag"])
+			require.Equal(t, "test", r.Header.Get("X-Request-Source"))
+			_, _ = w.Write([]byte(`{"items":[{"id":"p1","name":"rex","createdAt":"2024-01-02T03:04:05Z"}],"total":1}`))
+		case "POST /api/pets":
+			var pet NewPet
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&pet))
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(Pet{NewPet: pet, ID: "p2"})
+		case "GET /api/pets/a b":
+			_, _ = w.Write([]byte(`{"id":"a b","name":"spaced"}`))
+		case "DELETE /api/pets/p1":
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /api/stores/7/inventory":
+			_, _ = w.Write([]byte(`{"available":3}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(&httpx.Profile{BaseURL: server.URL + "/api"})
+
+	limit := int32(5)
+	page, err := client.ListPets(ctx, ListPetsParams{Limit: &limit, Tag: []string{"a", "b"}, XRequestSource: "test"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), page.Total)
+	require.Equal(t, "rex", page.Items[0].Name)
+	require.Equal(t, 2024, page.Items[0].CreatedAt.Year())
+
+	status := PetStatusAvailable
+	created, err := client.CreatePet(ctx, NewPet{Name: "tom", Status: &status})
+	require.NoError(t, err)
+	require.Equal(t, "p2", created.ID)
+	require.Equal(t, PetStatusAvailable, *created.Status)
+
+	pet, err := client.GetPetByID(ctx, "a b")
+	require.NoError(t, err)
+	require.Equal(t, "spaced", pet.Name)
+
+	_, err = client.GetPetByID(ctx, "")
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+	_, err = client.ListPets(ctx, ListPetsParams{})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	require.NoError(t, client.DeletePet(ctx, "p1"))
+
+	inventory, err := client.GetStoresStoreIDInventory(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"available": 3}, inventory)
+
+	_, err = client.GetPetByID(ctx, "missing")
+	require.Error(t, err)
+}
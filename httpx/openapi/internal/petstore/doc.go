@@ -0,0 +1,5 @@
+// Package petstore is a client generated from testdata/petstore.yaml. It is the
+// golden output of the generator tests and an example of go generate usage.
+package petstore
+
+//go:generate go run github.com/dev-ofa/core-go/httpx/cmd/httpx-openapi-gen -spec ../../testdata/petstore.yaml -package petstore -out client_gen.go
@@ -0,0 +1,205 @@
+// Package openapi generates typed httpx clients from OpenAPI 3 documents.
+//
+// Only the subset of OpenAPI used by JSON inter-service APIs is supported:
+// path and query parameters, JSON request and response bodies, component
+// schemas with $ref, arrays, maps, enums and allOf composition.
+package openapi
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"gopkg.in/yaml.v3"
+)
+
+// Document is the subset of an OpenAPI 3 document used for generation.
+type Document struct {
+	OpenAPI    string               `yaml:"openapi"`
+	Info       Info                 `yaml:"info"`
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components Components           `yaml:"components"`
+}
+
+// Info is the document metadata.
+type Info struct {
+	Title       string `yaml:"title"`
+	Description string `yaml:"description"`
+	Version     string `yaml:"version"`
+}
+
+// Components holds reusable definitions.
+type Components struct {
+	Schemas       map[string]*Schema      `yaml:"schemas"`
+	Parameters    map[string]*Parameter   `yaml:"parameters"`
+	RequestBodies map[string]*RequestBody `yaml:"requestBodies"`
+	Responses     map[string]*Response    `yaml:"responses"`
+}
+
+// PathItem holds the operations of one path.
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters"`
+	Get        *Operation   `yaml:"get"`
+	Put        *Operation   `yaml:"put"`
+	Post       *Operation   `yaml:"post"`
+	Delete     *Operation   `yaml:"delete"`
+	Patch      *Operation   `yaml:"patch"`
+}
+
+// Operation is one HTTP operation.
+type Operation struct {
+	OperationID string               `yaml:"operationId"`
+	Summary     string               `yaml:"summary"`
+	Description string               `yaml:"description"`
+	Deprecated  bool                 `yaml:"deprecated"`
+	Parameters  []*Parameter         `yaml:"parameters"`
+	RequestBody *RequestBody         `yaml:"requestBody"`
+	Responses   map[string]*Response `yaml:"responses"`
+}
+
+// Parameter is a path, query, header or cookie parameter.
+type Parameter struct {
+	Ref         string  `yaml:"$ref"`
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// RequestBody is an operation request body.
+type RequestBody struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Required    bool                  `yaml:"required"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// Response is one operation response.
+type Response struct {
+	Ref         string                `yaml:"$ref"`
+	Description string                `yaml:"description"`
+	Content     map[string]*MediaType `yaml:"content"`
+}
+
+// MediaType is the schema of one content type.
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Schema is the subset of a JSON schema used for generation.
+type Schema struct {
+	Ref                  string                `yaml:"$ref"`
+	Type                 string                `yaml:"type"`
+	Format               string                `yaml:"format"`
+	Description          string                `yaml:"description"`
+	Properties           map[string]*Schema    `yaml:"properties"`
+	Required             []string              `yaml:"required"`
+	Items                *Schema               `yaml:"items"`
+	Enum                 []any                 `yaml:"enum"`
+	AllOf                []*Schema             `yaml:"allOf"`
+	AdditionalProperties *AdditionalProperties `yaml:"additionalProperties"`
+	Nullable             bool                  `yaml:"nullable"`
+}
+
+// AdditionalProperties is either a boolean or a schema.
+type AdditionalProperties struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (a *AdditionalProperties) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&a.Allowed)
+	}
+	a.Allowed = true
+	a.Schema = &Schema{}
+	return node.Decode(a.Schema)
+}
+
+// Load reads an OpenAPI 3 document in YAML or JSON from path.
+func Load(path string) (*Document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read openapi document failed: %w", err)
+	}
+	return Parse(content)
+}
+
+// Parse decodes an OpenAPI 3 document in YAML or JSON.
+func Parse(content []byte) (*Document, error) {
+	var doc Document
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("decode openapi document failed: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, datax.NewValidationError(fmt.Sprintf("unsupported openapi version %q", doc.OpenAPI), nil, nil)
+	}
+	return &doc, nil
+}
+
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, err := refName(p.Ref, "#/components/parameters/")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.Parameters[name]
+	if !ok {
+		return nil, datax.NewValidationError(fmt.Sprintf("unresolved parameter %s", p.Ref), nil, nil)
+	}
+	return resolved, nil
+}
+
+func (d *Document) requestBody(b *RequestBody) (*RequestBody, error) {
+	if b == nil || b.Ref == "" {
+		return b, nil
+	}
+	name, err := refName(b.Ref, "#/components/requestBodies/")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.RequestBodies[name]
+	if !ok {
+		return nil, datax.NewValidationError(fmt.Sprintf("unresolved request body %s", b.Ref), nil, nil)
+	}
+	return resolved, nil
+}
+
+func (d *Document) response(r *Response) (*Response, error) {
+	if r == nil || r.Ref == "" {
+		return r, nil
+	}
+	name, err := refName(r.Ref, "#/components/responses/")
+	if err != nil {
+		return nil, err
+	}
+	resolved, ok := d.Components.Responses[name]
+	if !ok {
+		return nil, datax.NewValidationError(fmt.Sprintf("unresolved response %s", r.Ref), nil, nil)
+	}
+	return resolved, nil
+}
+
+func refName(ref string, prefix string) (string, error) {
+	if !strings.HasPrefix(ref, prefix) {
+		return "", datax.NewValidationError(fmt.Sprintf("unsupported $ref %s, expected %s...", ref, prefix), nil, nil)
+	}
+	return strings.TrimPrefix(ref, prefix), nil
+}
+
+func jsonMediaType(content map[string]*MediaType) *MediaType {
+	if mt, ok := content["application/json"]; ok {
+		return mt
+	}
+	for _, contentType := range sortedKeys(content) {
+		if strings.HasSuffix(strings.SplitN(contentType, ";", 2)[0], "+json") {
+			return content[contentType]
+		}
+	}
+	return nil
+}
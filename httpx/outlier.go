@@ -0,0 +1,184 @@
+package httpx
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutlierConsecutiveFailures = 5
+	defaultOutlierFailureRate         = 0.5
+	defaultOutlierMinRequests         = 10
+	defaultOutlierInterval            = 10 * time.Second
+	defaultOutlierBaseEjectionTime    = 30 * time.Second
+	defaultOutlierMaxEjectionTime     = 5 * time.Minute
+	defaultOutlierMaxEjectionPercent  = 50
+)
+
+// OutlierOptions configures passive outlier detection.
+// Zero values fall back to the documented defaults.
+type OutlierOptions struct {
+	// ConsecutiveFailures ejects an instance after this many failed attempts in a row. The default is 5.
+	ConsecutiveFailures int
+	// FailureRate ejects an instance whose failure ratio within Interval reaches this value. The default is 0.5.
+	FailureRate float64
+	// MinRequests is the attempt count within Interval required before FailureRate applies. The default is 10.
+	MinRequests int
+	// Interval is the window of the failure rate counters. The default is ten seconds.
+	Interval time.Duration
+	// BaseEjectionTime is the first ejection duration. Every further ejection doubles it. The default is 30 seconds.
+	BaseEjectionTime time.Duration
+	// MaxEjectionTime caps the ejection duration. The default is five minutes.
+	MaxEjectionTime time.Duration
+	// MaxEjectionPercent bounds the share of candidates that can be ejected at once. The default is 50.
+	MaxEjectionPercent int
+}
+
+// OutlierDetector passively tracks attempt failures per Instance.InstanceID and
+// temporarily ejects instances that fail repeatedly. A failure is a transport
+// error, including timeouts, or a 5xx response. It is safe for concurrent use.
+type OutlierDetector struct {
+	opt OutlierOptions
+	now func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*outlierState
+}
+
+type outlierState struct {
+	consecutive  int
+	windowStart  time.Time
+	requests     int
+	failures     int
+	ejections    int
+	ejectedAt    time.Time
+	ejectedUntil time.Time
+}
+
+// NewOutlierDetector returns a detector with opt applied over the defaults.
+func NewOutlierDetector(opt OutlierOptions) *OutlierDetector {
+	if opt.ConsecutiveFailures <= 0 {
+		opt.ConsecutiveFailures = defaultOutlierConsecutiveFailures
+	}
+	if opt.FailureRate <= 0 {
+		opt.FailureRate = defaultOutlierFailureRate
+	}
+	if opt.MinRequests <= 0 {
+		opt.MinRequests = defaultOutlierMinRequests
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = defaultOutlierInterval
+	}
+	if opt.BaseEjectionTime <= 0 {
+		opt.BaseEjectionTime = defaultOutlierBaseEjectionTime
+	}
+	if opt.MaxEjectionTime < opt.BaseEjectionTime {
+		opt.MaxEjectionTime = defaultOutlierMaxEjectionTime
+		if opt.MaxEjectionTime < opt.BaseEjectionTime {
+			opt.MaxEjectionTime = opt.BaseEjectionTime
+		}
+	}
+	if opt.MaxEjectionPercent <= 0 || opt.MaxEjectionPercent > 100 {
+		opt.MaxEjectionPercent = defaultOutlierMaxEjectionPercent
+	}
+	return &OutlierDetector{opt: opt, now: time.Now, items: map[string]*outlierState{}}
+}
+
+// Record reports the outcome of one attempt against inst.
+func (d *OutlierDetector) Record(inst Instance, failed bool) {
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := instanceKey(inst)
+	state, ok := d.items[key]
+	if !ok {
+		state = &outlierState{windowStart: now}
+		d.items[key] = state
+	}
+	if now.Before(state.ejectedUntil) {
+		// Attempts already in flight when the instance was ejected do not extend the ejection.
+		return
+	}
+	if now.Sub(state.windowStart) >= d.opt.Interval {
+		state.windowStart, state.requests, state.failures = now, 0, 0
+	}
+	// An instance that stayed healthy for a full max ejection time after its last
+	// ejection starts over from the base ejection time.
+	if state.ejections > 0 && now.Sub(state.ejectedUntil) >= d.opt.MaxEjectionTime {
+		state.ejections = 0
+	}
+	state.requests++
+	if !failed {
+		state.consecutive = 0
+		return
+	}
+	state.failures++
+	state.consecutive++
+	if state.consecutive >= d.opt.ConsecutiveFailures ||
+		(state.requests >= d.opt.MinRequests && float64(state.failures)/float64(state.requests) >= d.opt.FailureRate) {
+		d.ejectLocked(state, now)
+	}
+}
+
+// IsEjected reports whether inst is currently ejected.
+func (d *OutlierDetector) IsEjected(inst Instance) bool {
+	now := d.now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state, ok := d.items[instanceKey(inst)]
+	return ok && now.Before(state.ejectedUntil)
+}
+
+// Filter removes ejected instances from candidates. At most MaxEjectionPercent
+// of candidates are removed; when more are ejected, the most recently ejected
+// instances are removed first and the rest stay eligible.
+func (d *OutlierDetector) Filter(candidates []Instance) []Instance {
+	now := d.now()
+	d.mu.Lock()
+	type ejected struct {
+		index int
+		at    time.Time
+	}
+	var list []ejected
+	for i, inst := range candidates {
+		if state, ok := d.items[instanceKey(inst)]; ok && now.Before(state.ejectedUntil) {
+			list = append(list, ejected{index: i, at: state.ejectedAt})
+		}
+	}
+	d.mu.Unlock()
+	if len(list) == 0 {
+		return candidates
+	}
+	limit := len(candidates) * d.opt.MaxEjectionPercent / 100
+	if len(list) > limit {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].at.After(list[j].at) })
+		list = list[:limit]
+	}
+	skip := make(map[int]struct{}, len(list))
+	for _, item := range list {
+		skip[item.index] = struct{}{}
+	}
+	ret := make([]Instance, 0, len(candidates)-len(skip))
+	for i, inst := range candidates {
+		if _, ok := skip[i]; !ok {
+			ret = append(ret, inst)
+		}
+	}
+	return ret
+}
+
+func (d *OutlierDetector) ejectLocked(state *outlierState, now time.Time) {
+	duration := d.opt.BaseEjectionTime
+	for i := 0; i < state.ejections && duration < d.opt.MaxEjectionTime; i++ {
+		duration *= 2
+	}
+	if duration > d.opt.MaxEjectionTime {
+		duration = d.opt.MaxEjectionTime
+	}
+	state.ejections++
+	state.ejectedAt = now
+	state.ejectedUntil = now.Add(duration)
+	state.consecutive = 0
+	state.windowStart, state.requests, state.failures = now.Add(duration), 0, 0
+}
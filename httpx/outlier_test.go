@@ -0,0 +1,117 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutlierDetectorEjectsWithExponentialBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	detector := NewOutlierDetector(OutlierOptions{ConsecutiveFailures: 2, BaseEjectionTime: time.Second, MaxEjectionTime: 3 * time.Second, MaxEjectionPercent: 100})
+	detector.now = func() time.Time { return now }
+	bad := Instance{InstanceID: "bad", Host: "10.0.0.1"}
+
+	detector.Record(bad, true)
+	detector.Record(bad, false)
+	detector.Record(bad, true)
+	require.False(t, detector.IsEjected(bad))
+	detector.Record(bad, true)
+	require.True(t, detector.IsEjected(bad))
+
+	now = now.Add(time.Second)
+	require.False(t, detector.IsEjected(bad))
+	detector.Record(bad, true)
+	detector.Record(bad, true)
+	now = now.Add(1999 * time.Millisecond)
+	require.True(t, detector.IsEjected(bad), "second ejection doubles the duration")
+
+	now = now.Add(time.Millisecond)
+	detector.Record(bad, true)
+	detector.Record(bad, true)
+	now = now.Add(2999 * time.Millisecond)
+	require.True(t, detector.IsEjected(bad), "third ejection is capped by MaxEjectionTime")
+	now = now.Add(time.Millisecond)
+	require.False(t, detector.IsEjected(bad))
+}
+
+func TestOutlierDetectorFailureRateAndMaxEjectionPercent(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	detector := NewOutlierDetector(OutlierOptions{ConsecutiveFailures: 100, FailureRate: 0.5, MinRequests: 4})
+	detector.now = func() time.Time { return now }
+	flaky := Instance{InstanceID: "flaky", Host: "10.0.0.1"}
+	for i := 0; i < 2; i++ {
+		detector.Record(flaky, false)
+		detector.Record(flaky, true)
+	}
+	require.True(t, detector.IsEjected(flaky))
+
+	now = now.Add(time.Second)
+
+	other := Instance{InstanceID: "other", Host: "10.0.0.2"}
+	for i := 0; i < 4; i++ {
+		detector.Record(other, true)
+	}
+	require.True(t, detector.IsEjected(other))
+
+	healthy := Instance{InstanceID: "healthy", Host: "10.0.0.3"}
+	healthy2 := Instance{InstanceID: "healthy2", Host: "10.0.0.4"}
+	require.Equal(t, []Instance{healthy, healthy2}, detector.Filter([]Instance{flaky, healthy, other, healthy2}))
+
+	// Only half of the candidates can be ejected; the earliest ejected instance stays.
+	require.Equal(t, []Instance{flaky, healthy}, detector.Filter([]Instance{flaky, healthy, other}))
+	require.Equal(t, []Instance{flaky}, detector.Filter([]Instance{flaky}))
+}
+
+func TestAgentRetriesOnDifferentInstanceAndEjects(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer good.Close()
+
+	toInstance := func(id string, server *httptest.Server) Instance {
+		addr := server.Listener.Addr().(*net.TCPAddr)
+		return Instance{InstanceID: id, Host: addr.IP.String(), Port: addr.Port, Scheme: "http", HealthStatus: HealthStatusHealthy}
+	}
+	badInst, goodInst := toInstance("bad", bad), toInstance("good", good)
+	resolver := ResolverFunc(func(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+		return &ResolveResponse{Instances: []Instance{badInst, goodInst}}, nil
+	})
+	stats := NewInstanceStats(0)
+	detector := NewOutlierDetector(OutlierOptions{ConsecutiveFailures: 1, BaseEjectionTime: time.Minute})
+	// Always take the first candidate so the first attempt reaches "bad".
+	picker := InstancePickerFunc(func(ctx context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
+		selected := resp.Instances[0]
+		return &selected, nil
+	})
+	service := ServiceOptions{EnableDiscovery: true, Resolver: resolver, Picker: picker, Stats: stats, OutlierDetector: detector}
+
+	var resp map[string]bool
+	err := Get("http://inventory.prod/api",
+		Service(service),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		RetryStatusCodes([]int{http.StatusBadGateway}),
+		JSONResp(&resp),
+	).Do()
+	require.NoError(t, err)
+	require.True(t, resp["ok"])
+	require.Equal(t, uint64(1), stats.Get(badInst).Requests)
+	require.Equal(t, uint64(1), stats.Get(goodInst).Requests)
+	require.True(t, detector.IsEjected(badInst))
+
+	// The ejected instance is skipped on a fresh call.
+	resp = nil
+	err = Get("http://inventory.prod/api", Service(service), JSONResp(&resp)).Do()
+	require.NoError(t, err)
+	require.True(t, resp["ok"])
+	require.Equal(t, uint64(1), stats.Get(badInst).Requests)
+}
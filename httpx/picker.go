@@ -0,0 +1,370 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/pass"
+)
+
+const (
+	defaultLatencyDecay     = 10 * time.Second
+	defaultHashVirtualNodes = 160
+)
+
+var (
+	// DefaultInstanceStats is the tracker used when ServiceOptions.Stats and picker stats are not set.
+	DefaultInstanceStats = NewInstanceStats(0)
+)
+
+// InstanceStat is a point-in-time view of one instance's observed attempts.
+type InstanceStat struct {
+	// Outstanding is the number of in-flight attempts.
+	Outstanding int
+	// Latency is the exponentially weighted moving average of attempt latency.
+	// It is zero until the first attempt completes.
+	Latency time.Duration
+	// Requests is the number of completed attempts.
+	Requests uint64
+	// Failures is the number of completed attempts that failed at transport level or with 5xx.
+	Failures uint64
+}
+
+// InstanceStats tracks per-instance attempt results fed by Agent.
+// It is shared by stats-aware pickers and is safe for concurrent use.
+type InstanceStats struct {
+	decay time.Duration
+
+	mu    sync.Mutex
+	items map[string]*instanceStat
+}
+
+type instanceStat struct {
+	InstanceStat
+	updatedAt time.Time
+}
+
+// NewInstanceStats returns a tracker whose latency average forgets old samples over decay.
+// Zero means ten seconds.
+func NewInstanceStats(decay time.Duration) *InstanceStats {
+	if decay <= 0 {
+		decay = defaultLatencyDecay
+	}
+	return &InstanceStats{decay: decay, items: map[string]*instanceStat{}}
+}
+
+// Start records the beginning of an attempt against inst. The returned function
+// must be called exactly once with the attempt latency and whether it failed.
+func (s *InstanceStats) Start(inst Instance) func(latency time.Duration, failed bool) {
+	key := instanceKey(inst)
+	s.mu.Lock()
+	s.itemLocked(key).Outstanding++
+	s.mu.Unlock()
+
+	var once sync.Once
+	return func(latency time.Duration, failed bool) {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			item := s.itemLocked(key)
+			item.Outstanding--
+			item.Requests++
+			if failed {
+				item.Failures++
+			}
+			now := time.Now()
+			if item.Latency == 0 {
+				item.Latency = latency
+			} else {
+				// Time-decayed EWMA: the older the previous sample, the less it weighs.
+				weight := float64(now.Sub(item.updatedAt)) / float64(s.decay)
+				if weight > 1 {
+					weight = 1
+				}
+				if weight < 0.1 {
+					weight = 0.1
+				}
+				item.Latency = time.Duration(float64(item.Latency)*(1-weight) + float64(latency)*weight)
+			}
+			item.updatedAt = now
+		})
+	}
+}
+
+// Get returns the current stats of inst.
+func (s *InstanceStats) Get(inst Instance) InstanceStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item, ok := s.items[instanceKey(inst)]; ok {
+		return item.InstanceStat
+	}
+	return InstanceStat{}
+}
+
+func (s *InstanceStats) itemLocked(key string) *instanceStat {
+	item, ok := s.items[key]
+	if !ok {
+		item = &instanceStat{}
+		s.items[key] = item
+	}
+	return item
+}
+
+func instanceKey(inst Instance) string {
+	if inst.InstanceID != "" {
+		return inst.InstanceID
+	}
+	return instanceAddr(inst)
+}
+
+// isInstanceFailure reports whether an attempt outcome is attributable to the instance:
+// a 5xx response or a transport error other than caller cancellation.
+func isInstanceFailure(statusCode int, err error) bool {
+	if statusCode >= 500 {
+		return true
+	}
+	return err != nil && statusCode == 0 && !errors.Is(err, context.Canceled)
+}
+
+func statsOrDefault(stats *InstanceStats) *InstanceStats {
+	if stats != nil {
+		return stats
+	}
+	return DefaultInstanceStats
+}
+
+// RoundRobinPicker selects instances with smooth weighted round-robin.
+// Instances without a positive weight count as weight 1.
+type RoundRobinPicker struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+// NewRoundRobinPicker returns a smooth weighted round-robin picker.
+func NewRoundRobinPicker() *RoundRobinPicker {
+	return &RoundRobinPicker{current: map[string]map[string]int{}}
+}
+
+// Pick implements InstancePicker.
+func (p *RoundRobinPicker) Pick(_ context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
+	candidates := filterCandidates(req, resp)
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyInstance
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		p.current = map[string]map[string]int{}
+	}
+	svc := serviceKey(req.Namespace, req.ServiceName)
+	prev := p.current[svc]
+	current := make(map[string]int, len(candidates))
+	total, best := 0, -1
+	for i, inst := range candidates {
+		key := instanceKey(inst)
+		weight := positiveWeight(inst)
+		total += weight
+		current[key] = prev[key] + weight
+		if best < 0 || current[key] > current[instanceKey(candidates[best])] {
+			best = i
+		}
+	}
+	current[instanceKey(candidates[best])] -= total
+	p.current[svc] = current
+	selected := candidates[best]
+	return &selected, nil
+}
+
+// LeastRequestPicker selects the instance with the fewest outstanding attempts
+// relative to its weight. Ties are broken randomly.
+type LeastRequestPicker struct {
+	// Stats is the tracker fed by Agent. The default is DefaultInstanceStats.
+	Stats *InstanceStats
+}
+
+// Pick implements InstancePicker.
+func (p LeastRequestPicker) Pick(_ context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
+	candidates := filterCandidates(req, resp)
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyInstance
+	}
+	stats := statsOrDefault(p.Stats)
+	var (
+		best      []int
+		bestScore float64
+	)
+	for i, inst := range candidates {
+		score := float64(stats.Get(inst).Outstanding+1) / float64(positiveWeight(inst))
+		switch {
+		case len(best) == 0 || score < bestScore:
+			best, bestScore = []int{i}, score
+		case score == bestScore:
+			best = append(best, i)
+		}
+	}
+	selected := candidates[best[rand.Intn(len(best))]] // #nosec G404: load balancing does not require crypto randomness.
+	return &selected, nil
+}
+
+// P2CPicker samples two random candidates and selects the one with the lower
+// observed latency multiplied by its outstanding attempts. Instances without
+// latency samples are preferred so new instances get traffic.
+type P2CPicker struct {
+	// Stats is the tracker fed by Agent. The default is DefaultInstanceStats.
+	Stats *InstanceStats
+}
+
+// Pick implements InstancePicker.
+func (p P2CPicker) Pick(_ context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
+	candidates := filterCandidates(req, resp)
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyInstance
+	}
+	if len(candidates) == 1 {
+		selected := candidates[0]
+		return &selected, nil
+	}
+	stats := statsOrDefault(p.Stats)
+	i := rand.Intn(len(candidates))     // #nosec G404: load balancing does not require crypto randomness.
+	j := rand.Intn(len(candidates) - 1) // #nosec G404: load balancing does not require crypto randomness.
+	if j >= i {
+		j++
+	}
+	if p2cCost(stats.Get(candidates[j]), candidates[j]) < p2cCost(stats.Get(candidates[i]), candidates[i]) {
+		i = j
+	}
+	selected := candidates[i]
+	return &selected, nil
+}
+
+func p2cCost(stat InstanceStat, inst Instance) float64 {
+	return float64(stat.Latency) * float64(stat.Outstanding+1) / float64(positiveWeight(inst))
+}
+
+// ConsistentHashPicker maps a request key onto a hash ring so the same key keeps
+// reaching the same instance while the instance set is stable.
+type ConsistentHashPicker struct {
+	// KeyFunc extracts the affinity key. The default uses the tenant id from pass.
+	// Requests without a key fall back to weighted random choice.
+	KeyFunc func(ctx context.Context, req ResolveRequest) string
+	// VirtualNodes is the number of ring points per unit of weight. Zero means 160.
+	VirtualNodes int
+
+	mu    sync.Mutex
+	rings map[string]*hashRing
+}
+
+// Pick implements InstancePicker.
+func (p *ConsistentHashPicker) Pick(ctx context.Context, req ResolveRequest, resp *ResolveResponse) (*Instance, error) {
+	candidates := filterCandidates(req, resp)
+	if len(candidates) == 0 {
+		return nil, ErrNoHealthyInstance
+	}
+	keyFunc := p.KeyFunc
+	if keyFunc == nil {
+		keyFunc = TenantHashKey
+	}
+	key := keyFunc(ctx, req)
+	if key == "" {
+		selected := candidates[weightedRandomIndex(candidates)]
+		return &selected, nil
+	}
+	owner := p.ring(req, candidates).lookup(key)
+	for _, inst := range candidates {
+		if instanceKey(inst) == owner {
+			selected := inst
+			return &selected, nil
+		}
+	}
+	return nil, ErrNoHealthyInstance
+}
+
+// TenantHashKey returns the tenant id from pass as the consistent hash key.
+func TenantHashKey(ctx context.Context, _ ResolveRequest) string {
+	tenantID, _ := pass.CtxGetTenantID(ctx)
+	return tenantID
+}
+
+func (p *ConsistentHashPicker) ring(req ResolveRequest, candidates []Instance) *hashRing {
+	signature := ringSignature(candidates)
+	svc := serviceKey(req.Namespace, req.ServiceName)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rings == nil {
+		p.rings = map[string]*hashRing{}
+	}
+	if ring, ok := p.rings[svc]; ok && ring.signature == signature {
+		return ring
+	}
+	vnodes := p.VirtualNodes
+	if vnodes <= 0 {
+		vnodes = defaultHashVirtualNodes
+	}
+	ring := newHashRing(candidates, vnodes, signature)
+	p.rings[svc] = ring
+	return ring
+}
+
+// hashRing maps hashes to instance keys. It does not depend on the order of
+// the candidates it was built from, so resolvers may reorder them freely.
+type hashRing struct {
+	signature string
+	points    []uint32
+	owners    map[uint32]string
+}
+
+func newHashRing(candidates []Instance, vnodes int, signature string) *hashRing {
+	ring := &hashRing{
+		signature: signature,
+		owners:    map[uint32]string{},
+	}
+	// Colliding points go to the first owner, so build in key order.
+	sorted := append([]Instance(nil), candidates...)
+	sort.Slice(sorted, func(i, j int) bool { return instanceKey(sorted[i]) < instanceKey(sorted[j]) })
+	for _, inst := range sorted {
+		key := instanceKey(inst)
+		for v := 0; v < vnodes*positiveWeight(inst); v++ {
+			point := crc32.ChecksumIEEE([]byte(key + "#" + strconv.Itoa(v)))
+			if _, ok := ring.owners[point]; ok {
+				continue
+			}
+			ring.owners[point] = key
+			ring.points = append(ring.points, point)
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool { return ring.points[i] < ring.points[j] })
+	return ring
+}
+
+// lookup returns the instance key owning key.
+func (r *hashRing) lookup(key string) string {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func ringSignature(candidates []Instance) string {
+	keys := make([]string, 0, len(candidates))
+	for _, inst := range candidates {
+		keys = append(keys, instanceKey(inst)+"="+strconv.Itoa(positiveWeight(inst)))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func positiveWeight(inst Instance) int {
+	if inst.Weight > 0 {
+		return inst.Weight
+	}
+	return 1
+}
@@ -0,0 +1,199 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobinPickerIsSmoothAndWeighted(t *testing.T) {
+	picker := NewRoundRobinPicker()
+	req := ResolveRequest{ServiceName: "inventory", Namespace: "prod"}
+	resp := &ResolveResponse{Instances: []Instance{
+		{InstanceID: "a", Host: "10.0.0.1", Weight: 5, HealthStatus: HealthStatusHealthy},
+		{InstanceID: "b", Host: "10.0.0.2", Weight: 1, HealthStatus: HealthStatusHealthy},
+		{InstanceID: "c", Host: "10.0.0.3", Weight: 1, HealthStatus: HealthStatusHealthy},
+		{InstanceID: "d", Host: "10.0.0.4", Weight: 9, HealthStatus: HealthStatusUnhealthy},
+	}}
+
+	var got []string
+	for i := 0; i < 7; i++ {
+		inst, err := picker.Pick(context.Background(), req, resp)
+		require.NoError(t, err)
+		got = append(got, inst.InstanceID)
+	}
+	require.Equal(t, []string{"a", "a", "b", "a", "c", "a", "a"}, got)
+
+	var zero RoundRobinPicker
+	inst, err := zero.Pick(context.Background(), req, resp)
+	require.NoError(t, err)
+	require.Equal(t, "a", inst.InstanceID)
+
+	_, err = picker.Pick(context.Background(), req, &ResolveResponse{})
+	require.ErrorIs(t, err, ErrNoHealthyInstance)
+}
+
+func TestLeastRequestPickerUsesOutstandingAttempts(t *testing.T) {
+	stats := NewInstanceStats(0)
+	a := Instance{InstanceID: "a", Host: "10.0.0.1", HealthStatus: HealthStatusHealthy}
+	b := Instance{InstanceID: "b", Host: "10.0.0.2", HealthStatus: HealthStatusHealthy}
+	doneA1 := stats.Start(a)
+	doneA2 := stats.Start(a)
+	doneB := stats.Start(b)
+
+	picker := LeastRequestPicker{Stats: stats}
+	resp := &ResolveResponse{Instances: []Instance{a, b}}
+	for i := 0; i < 10; i++ {
+		inst, err := picker.Pick(context.Background(), ResolveRequest{}, resp)
+		require.NoError(t, err)
+		require.Equal(t, "b", inst.InstanceID)
+	}
+
+	doneA1(time.Millisecond, false)
+	doneA2(time.Millisecond, true)
+	doneA2(time.Millisecond, true)
+	doneB(time.Millisecond, false)
+	statA := stats.Get(a)
+	require.Equal(t, 0, statA.Outstanding)
+	require.Equal(t, uint64(2), statA.Requests)
+	require.Equal(t, uint64(1), statA.Failures)
+	require.Equal(t, time.Millisecond, statA.Latency)
+}
+
+func TestP2CPickerPrefersLowerLatency(t *testing.T) {
+	stats := NewInstanceStats(0)
+	fast := Instance{InstanceID: "fast", Host: "10.0.0.1", HealthStatus: HealthStatusHealthy}
+	slow := Instance{InstanceID: "slow", Host: "10.0.0.2", HealthStatus: HealthStatusHealthy}
+	stats.Start(fast)(time.Millisecond, false)
+	stats.Start(slow)(time.Second, false)
+
+	picker := P2CPicker{Stats: stats}
+	resp := &ResolveResponse{Instances: []Instance{fast, slow}}
+	for i := 0; i < 10; i++ {
+		inst, err := picker.Pick(context.Background(), ResolveRequest{}, resp)
+		require.NoError(t, err)
+		require.Equal(t, "fast", inst.InstanceID)
+	}
+}
+
+func TestConsistentHashPickerKeepsTenantAffinity(t *testing.T) {
+	picker := &ConsistentHashPicker{}
+	req := ResolveRequest{ServiceName: "inventory", Namespace: "prod"}
+	instances := []Instance{
+		{InstanceID: "a", Host: "10.0.0.1", HealthStatus: HealthStatusHealthy},
+		{InstanceID: "b", Host: "10.0.0.2", HealthStatus: HealthStatusHealthy},
+		{InstanceID: "c", Host: "10.0.0.3", HealthStatus: HealthStatusHealthy},
+	}
+	resp := &ResolveResponse{Instances: instances}
+
+	owners := map[string]string{}
+	for _, tenant := range []string{"t1", "t2", "t3", "t4", "t5", "t6"} {
+		ctx := pass.CtxSetTenantID(context.Background(), tenant)
+		first, err := picker.Pick(ctx, req, resp)
+		require.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			inst, err := picker.Pick(ctx, req, resp)
+			require.NoError(t, err)
+			require.Equal(t, first.InstanceID, inst.InstanceID)
+		}
+		owners[tenant] = first.InstanceID
+	}
+
+	// Removing one instance only moves the tenants it owned.
+	shrunk := &ResolveResponse{Instances: instances[:2]}
+	for tenant, owner := range owners {
+		inst, err := picker.Pick(pass.CtxSetTenantID(context.Background(), tenant), req, shrunk)
+		require.NoError(t, err)
+		if owner != "c" {
+			require.Equal(t, owner, inst.InstanceID)
+		}
+	}
+
+	inst, err := picker.Pick(context.Background(), req, resp)
+	require.NoError(t, err)
+	require.NotEmpty(t, inst.InstanceID)
+}
+
+func TestConsistentHashPickerIgnoresCandidateOrder(t *testing.T) {
+	picker := &ConsistentHashPicker{}
+	req := ResolveRequest{ServiceName: "inventory", Namespace: "prod"}
+	instances := []Instance{
+		{InstanceID: "a", Host: "10.0.0.1", HealthStatus: HealthStatusHealthy},
+		{InstanceID: "b", Host: "10.0.0.2", HealthStatus: HealthStatusHealthy},
+		{InstanceID: "c", Host: "10.0.0.3", HealthStatus: HealthStatusHealthy},
+	}
+	reversed := []Instance{instances[2], instances[1], instances[0]}
+
+	for _, tenant := range []string{"t1", "t2", "t3", "t4", "t5", "t6"} {
+		ctx := pass.CtxSetTenantID(context.Background(), tenant)
+		first, err := picker.Pick(ctx, req, &ResolveResponse{Instances: instances})
+		require.NoError(t, err)
+		// The ring is cached by instance set, so the reversed order reuses it.
+		again, err := picker.Pick(ctx, req, &ResolveResponse{Instances: reversed})
+		require.NoError(t, err)
+		require.Equal(t, first.InstanceID, again.InstanceID, tenant)
+
+		fresh, err := (&ConsistentHashPicker{}).Pick(ctx, req, &ResolveResponse{Instances: reversed})
+		require.NoError(t, err)
+		require.Equal(t, first.InstanceID, fresh.InstanceID, tenant)
+	}
+}
+
+func TestPickersHonorPreferredLabelSelector(t *testing.T) {
+	req := ResolveRequest{PreferredLabelSelector: map[string]string{"version": "canary"}}
+	resp := &ResolveResponse{Instances: []Instance{
+		{InstanceID: "stable", Host: "10.0.0.1", HealthStatus: HealthStatusHealthy, Labels: map[string]string{"version": "stable"}},
+		{InstanceID: "canary", Host: "10.0.0.2", HealthStatus: HealthStatusHealthy, Labels: map[string]string{"version": "canary"}},
+	}}
+	pickers := map[string]InstancePicker{
+		"random":      RandomPicker{},
+		"round-robin": NewRoundRobinPicker(),
+		"least":       LeastRequestPicker{Stats: NewInstanceStats(0)},
+		"p2c":         P2CPicker{Stats: NewInstanceStats(0)},
+		"hash":        &ConsistentHashPicker{},
+	}
+	for name, picker := range pickers {
+		for i := 0; i < 5; i++ {
+			inst, err := picker.Pick(pass.CtxSetTenantID(context.Background(), "t1"), req, resp)
+			require.NoError(t, err, name)
+			require.Equal(t, "canary", inst.InstanceID, name)
+		}
+	}
+
+	// The preference is soft: without a match every candidate stays eligible.
+	req.PreferredLabelSelector = map[string]string{"version": "missing"}
+	inst, err := RandomPicker{}.Pick(context.Background(), req, resp)
+	require.NoError(t, err)
+	require.NotEmpty(t, inst.InstanceID)
+}
+
+func TestAgentFeedsInstanceStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	inst := Instance{InstanceID: "inv-1", Host: addr.IP.String(), Port: addr.Port, Scheme: "http", HealthStatus: HealthStatusHealthy}
+	resolver := ResolverFunc(func(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+		return &ResolveResponse{Instances: []Instance{inst}}, nil
+	})
+	stats := NewInstanceStats(0)
+	err := Get("http://inventory.prod/api",
+		Service(ServiceOptions{EnableDiscovery: true, Resolver: resolver, Picker: LeastRequestPicker{Stats: stats}, Stats: stats}),
+		Retry(&RetryOpt{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}),
+		RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+	).Do()
+	require.Error(t, err)
+
+	stat := stats.Get(inst)
+	require.Equal(t, 0, stat.Outstanding)
+	require.Equal(t, uint64(2), stat.Requests)
+	require.Equal(t, uint64(2), stat.Failures)
+}
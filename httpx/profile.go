@@ -0,0 +1,253 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+// Profile holds shared defaults for calls to one upstream. Agents created by a
+// profile apply the profile options first and the per-call options after them,
+// so per-call options override profile defaults. Interceptors and request
+// pre-handlers accumulate: profile interceptors run outside per-call ones.
+//
+// A Profile must not be modified while it is used concurrently.
+type Profile struct {
+	// Name identifies the profile in logs and errors.
+	Name string
+	// BaseURL is joined with relative call paths. Absolute call URLs are used as is.
+	BaseURL string
+	// Header is set on every request.
+	Header http.Header
+	// Client is the HTTP client. The default is DefaultClient.
+	Client *http.Client
+	// TimeoutQuota is used when the call context has no deadline.
+	TimeoutQuota time.Duration
+	// Retry enables limited retry when set.
+	Retry *RetryOpt
+	// RetryStatusCodes lists HTTP status codes that are safe to retry.
+	RetryStatusCodes []int
+	// ExpectedStatusCodes lists accepted HTTP status codes. The agent default is 200.
+	ExpectedStatusCodes []int
+	// WrapperFactory returns a fresh response wrapper for every call, e.g.
+	// func() Wrapper { return NewCommonWrapper() }.
+	WrapperFactory func() Wrapper
+	// Service configures discovery when set.
+	Service *ServiceOptions
+	// Interceptors wrap every attempt of every call.
+	Interceptors []Interceptor
+	// AdaptiveTimeout enables per-attempt timeouts derived from observed latency when set.
+	AdaptiveTimeout *AdaptiveTimeoutOptions
+	// MaxResponseSize bounds response bodies in bytes. Zero means no limit.
+	MaxResponseSize int64
+	// Decompress lists the content encodings decoded by the agent, see Decompress.
+	Decompress []string
+}
+
+// ProfileConfig is the config file shape of a Profile, e.g. loaded with config.Load.
+type ProfileConfig struct {
+	Name                string                 `json:"name" yaml:"name" mapstructure:"name"`
+	BaseURL             string                 `json:"base_url" yaml:"base_url" mapstructure:"base_url"`
+	Headers             map[string]string      `json:"headers" yaml:"headers" mapstructure:"headers"`
+	Timeout             time.Duration          `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+	Retry               *RetryConfig           `json:"retry" yaml:"retry" mapstructure:"retry"`
+	ExpectedStatusCodes []int                  `json:"expected_status_codes" yaml:"expected_status_codes" mapstructure:"expected_status_codes"`
+	CommonWrapper       *CommonWrapperConfig   `json:"common_wrapper" yaml:"common_wrapper" mapstructure:"common_wrapper"`
+	Discovery           *DiscoveryConfig       `json:"discovery" yaml:"discovery" mapstructure:"discovery"`
+	Client              *ClientConfig          `json:"client" yaml:"client" mapstructure:"client"`
+	Faults              *FaultConfig           `json:"faults" yaml:"faults" mapstructure:"faults"`
+	AdaptiveTimeout     *AdaptiveTimeoutConfig `json:"adaptive_timeout" yaml:"adaptive_timeout" mapstructure:"adaptive_timeout"`
+	MaxResponseSize     int64                  `json:"max_response_size" yaml:"max_response_size" mapstructure:"max_response_size"`
+	Decompress          []string               `json:"decompress" yaml:"decompress" mapstructure:"decompress"`
+}
+
+// RetryConfig is the config file shape of RetryOpt and retryable status codes.
+type RetryConfig struct {
+	Attempts      int           `json:"attempts" yaml:"attempts" mapstructure:"attempts"`
+	BaseDelay     time.Duration `json:"base_delay" yaml:"base_delay" mapstructure:"base_delay"`
+	MaxDelay      time.Duration `json:"max_delay" yaml:"max_delay" mapstructure:"max_delay"`
+	RetryAppError bool          `json:"retry_app_error" yaml:"retry_app_error" mapstructure:"retry_app_error"`
+	Idempotent    bool          `json:"idempotent" yaml:"idempotent" mapstructure:"idempotent"`
+	StatusCodes   []int         `json:"status_codes" yaml:"status_codes" mapstructure:"status_codes"`
+}
+
+// CommonWrapperConfig enables CommonWrapper decoding for every call.
+type CommonWrapperConfig struct {
+	// AllowCodes lists non-zero application codes treated as success.
+	AllowCodes []int `json:"allow_codes" yaml:"allow_codes" mapstructure:"allow_codes"`
+}
+
+// DiscoveryConfig is the config file shape of ServiceOptions. Resolver and
+// picker are code-level dependencies and are set on Profile.Service afterwards.
+type DiscoveryConfig struct {
+	ServiceName            string            `json:"service_name" yaml:"service_name" mapstructure:"service_name"`
+	Namespace              string            `json:"namespace" yaml:"namespace" mapstructure:"namespace"`
+	PreferredZone          string            `json:"preferred_zone" yaml:"preferred_zone" mapstructure:"preferred_zone"`
+	LabelSelector          map[string]string `json:"label_selector" yaml:"label_selector" mapstructure:"label_selector"`
+	PreferredLabelSelector map[string]string `json:"preferred_label_selector" yaml:"preferred_label_selector" mapstructure:"preferred_label_selector"`
+	ResolveMode            ResolveMode       `json:"resolve_mode" yaml:"resolve_mode" mapstructure:"resolve_mode"`
+}
+
+// NewProfileFromConfig builds a profile from cfg.
+func NewProfileFromConfig(cfg ProfileConfig) (*Profile, error) {
+	if cfg.BaseURL != "" {
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, datax.NewValidationError(fmt.Sprintf("profile %s has invalid base_url %q", cfg.Name, cfg.BaseURL), nil, nil)
+		}
+	}
+	p := &Profile{
+		Name:                cfg.Name,
+		BaseURL:             cfg.BaseURL,
+		TimeoutQuota:        cfg.Timeout,
+		ExpectedStatusCodes: cfg.ExpectedStatusCodes,
+		MaxResponseSize:     cfg.MaxResponseSize,
+		Decompress:          cfg.Decompress,
+	}
+	if len(cfg.Headers) > 0 {
+		p.Header = http.Header{}
+		for k, v := range cfg.Headers {
+			p.Header.Set(k, v)
+		}
+	}
+	if cfg.Retry != nil {
+		p.Retry = &RetryOpt{
+			Attempts:      cfg.Retry.Attempts,
+			BaseDelay:     cfg.Retry.BaseDelay,
+			MaxDelay:      cfg.Retry.MaxDelay,
+			RetryAppError: cfg.Retry.RetryAppError,
+			Idempotent:    cfg.Retry.Idempotent,
+		}
+		p.RetryStatusCodes = cfg.Retry.StatusCodes
+	}
+	if cfg.CommonWrapper != nil {
+		allowCodes := append([]int(nil), cfg.CommonWrapper.AllowCodes...)
+		p.WrapperFactory = func() Wrapper { return NewCommonWrapper(allowCodes...) }
+	}
+	if cfg.Discovery != nil {
+		p.Service = &ServiceOptions{
+			EnableDiscovery:        true,
+			ServiceName:            cfg.Discovery.ServiceName,
+			Namespace:              cfg.Discovery.Namespace,
+			PreferredZone:          cfg.Discovery.PreferredZone,
+			LabelSelector:          cfg.Discovery.LabelSelector,
+			PreferredLabelSelector: cfg.Discovery.PreferredLabelSelector,
+			ResolveMode:            cfg.Discovery.ResolveMode,
+		}
+	}
+	if cfg.Client != nil {
+		client, err := NewClientFromConfig(*cfg.Client)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s client: %w", cfg.Name, err)
+		}
+		p.Client = client
+	}
+	if cfg.AdaptiveTimeout != nil {
+		p.AdaptiveTimeout = &AdaptiveTimeoutOptions{
+			Multiplier: cfg.AdaptiveTimeout.Multiplier,
+			Percentile: cfg.AdaptiveTimeout.Percentile,
+			MinSamples: cfg.AdaptiveTimeout.MinSamples,
+			MinTimeout: cfg.AdaptiveTimeout.MinTimeout,
+			MaxTimeout: cfg.AdaptiveTimeout.MaxTimeout,
+		}
+	}
+	if cfg.Faults != nil && cfg.Faults.Enabled {
+		injector, err := NewFaultInjector(*cfg.Faults)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s faults: %w", cfg.Name, err)
+		}
+		p.Interceptors = append(p.Interceptors, injector)
+	}
+	return p, nil
+}
+
+// Get starts a GET request relative to the profile base URL.
+func (p *Profile) Get(path string, ops ...AgentOp) *Agent {
+	return p.newAgent(http.MethodGet, path, ops)
+}
+
+// Post starts a POST request relative to the profile base URL.
+func (p *Profile) Post(path string, ops ...AgentOp) *Agent {
+	return p.newAgent(http.MethodPost, path, ops)
+}
+
+// Put starts a PUT request relative to the profile base URL.
+func (p *Profile) Put(path string, ops ...AgentOp) *Agent {
+	return p.newAgent(http.MethodPut, path, ops)
+}
+
+// Patch starts a PATCH request relative to the profile base URL.
+func (p *Profile) Patch(path string, ops ...AgentOp) *Agent {
+	return p.newAgent(http.MethodPatch, path, ops)
+}
+
+// Delete starts a DELETE request relative to the profile base URL.
+func (p *Profile) Delete(path string, ops ...AgentOp) *Agent {
+	return p.newAgent(http.MethodDelete, path, ops)
+}
+
+// Ops returns the profile defaults as agent options, e.g. to apply them to an
+// agent created elsewhere.
+func (p *Profile) Ops() []AgentOp {
+	var ops []AgentOp
+	if p.Client != nil {
+		ops = append(ops, Client(p.Client))
+	}
+	if p.TimeoutQuota > 0 {
+		ops = append(ops, TimeoutQuota(p.TimeoutQuota))
+	}
+	if p.Retry != nil {
+		retry := *p.Retry
+		ops = append(ops, Retry(&retry))
+	}
+	if len(p.RetryStatusCodes) > 0 {
+		ops = append(ops, RetryStatusCodes(p.RetryStatusCodes))
+	}
+	if len(p.ExpectedStatusCodes) > 0 {
+		ops = append(ops, ExpectedStatusCodes(p.ExpectedStatusCodes))
+	}
+	if p.WrapperFactory != nil {
+		factory := p.WrapperFactory
+		ops = append(ops, AgentOpFunc(func(agent *Agent) error {
+			return RespWrapper(factory())(agent)
+		}))
+	}
+	if p.Service != nil {
+		ops = append(ops, Service(*p.Service))
+	}
+	if len(p.Header) > 0 {
+		ops = append(ops, SetHeader(p.Header.Clone()))
+	}
+	if len(p.Interceptors) > 0 {
+		ops = append(ops, Interceptors(p.Interceptors...))
+	}
+	if p.AdaptiveTimeout != nil {
+		ops = append(ops, AdaptiveTimeout(*p.AdaptiveTimeout))
+	}
+	if p.MaxResponseSize > 0 {
+		ops = append(ops, MaxResponseSize(p.MaxResponseSize))
+	}
+	if len(p.Decompress) > 0 {
+		ops = append(ops, Decompress(p.Decompress...))
+	}
+	return ops
+}
+
+func (p *Profile) newAgent(method string, path string, ops []AgentOp) *Agent {
+	all := append(p.Ops(), ops...)
+	return newAgent(p.resolvePath(path), method, all...)
+}
+
+func (p *Profile) resolvePath(path string) string {
+	if p.BaseURL == "" || strings.Contains(path, "://") {
+		return path
+	}
+	if path == "" {
+		return p.BaseURL
+	}
+	return strings.TrimSuffix(p.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,123 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/config"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileAppliesDefaultsAndCallOverrides(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/v1/items":
+			require.Equal(t, "profile", r.Header.Get("X-Source"))
+			require.Equal(t, "yes", r.Header.Get("X-Intercepted"))
+			_, _ = w.Write([]byte(`{"code":0,"data":{"name":"widget"}}`))
+		case "/v1/override":
+			require.Equal(t, "call", r.Header.Get("X-Source"))
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"code":0,"data":{"name":"accepted"}}`))
+		case "/v1/flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	profile := &Profile{
+		Name:             "inventory",
+		BaseURL:          server.URL + "/v1/",
+		Header:           http.Header{"X-Source": []string{"profile"}},
+		Retry:            &RetryOpt{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		RetryStatusCodes: []int{http.StatusServiceUnavailable},
+		WrapperFactory:   func() Wrapper { return NewCommonWrapper() },
+		Interceptors: []Interceptor{InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+			attempt.Request.Header.Set("X-Intercepted", "yes")
+			return next(attempt)
+		})},
+	}
+
+	var item struct {
+		Name string `json:"name"`
+	}
+	require.NoError(t, profile.Get("/items", JSONResp(&item)).Do())
+	require.Equal(t, "widget", item.Name)
+
+	require.NoError(t, profile.Post("override",
+		SetHeader(http.Header{"X-Source": []string{"call"}}),
+		ExpectedStatusCodes([]int{http.StatusAccepted}),
+		JSONResp(&item),
+	).Do())
+	require.Equal(t, "accepted", item.Name)
+
+	calls = 0
+	err := profile.Get("flaky").Do()
+	require.Error(t, err)
+	require.Equal(t, 3, calls)
+
+	calls = 0
+	err = profile.Get("flaky", Retry(&RetryOpt{Attempts: 1})).Do()
+	require.Error(t, err)
+	require.Equal(t, 1, calls, "per-call retry overrides the profile policy")
+
+	require.Equal(t, "http://other.test/x", profile.resolvePath("http://other.test/x"))
+}
+
+func TestNewProfileFromConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+inventory:
+  name: inventory
+  base_url: http://inventory.prod
+  headers:
+    x-caller: billing
+  timeout: 2s
+  retry:
+    attempts: 2
+    base_delay: 10ms
+    status_codes: [503]
+  expected_status_codes: [200, 201]
+  common_wrapper:
+    allow_codes: [1001]
+  discovery:
+    namespace: prod
+    preferred_zone: zone-a
+`), 0o600))
+
+	opts := config.NewOptions()
+	opts.DefaultConfigPath = path
+	opts.Args = []string{}
+	cfg, _, err := config.Load[struct {
+		Inventory ProfileConfig `mapstructure:"inventory"`
+	}](opts)
+	require.NoError(t, err)
+
+	profile, err := NewProfileFromConfig(cfg.Inventory)
+	require.NoError(t, err)
+	require.Equal(t, "http://inventory.prod", profile.BaseURL)
+	require.Equal(t, "billing", profile.Header.Get("X-Caller"))
+	require.Equal(t, 2*time.Second, profile.TimeoutQuota)
+	require.Equal(t, &RetryOpt{Attempts: 2, BaseDelay: 10 * time.Millisecond}, profile.Retry)
+	require.Equal(t, []int{http.StatusServiceUnavailable}, profile.RetryStatusCodes)
+	require.Equal(t, []int{200, 201}, profile.ExpectedStatusCodes)
+	require.True(t, profile.Service.EnableDiscovery)
+	require.Equal(t, "prod", profile.Service.Namespace)
+	require.Equal(t, "zone-a", profile.Service.PreferredZone)
+
+	wrapper, ok := profile.WrapperFactory().(*CommonWrapper)
+	require.True(t, ok)
+	wrapper.Code = 1001
+	require.NoError(t, wrapper.Validate())
+	require.NotSame(t, wrapper, profile.WrapperFactory())
+
+	_, err = NewProfileFromConfig(ProfileConfig{Name: "bad", BaseURL: "not a url"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"strings"
 	"sync"
 )
 
@@ -29,9 +28,7 @@ func TextReq(reqBody string) AgentOp {
 	return AgentOpFunc(func(agent *Agent) error {
 		agent.reqPreHandlers = append(agent.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
 			req.Header.Set("Content-Type", "text/plain; charset=utf-8")
-			r := strings.NewReader(reqBody)
-			req.ContentLength = int64(r.Len())
-			req.Body = io.NopCloser(r)
+			setBytesBody(req, []byte(reqBody))
 			return req, nil
 		}))
 		return nil
@@ -47,8 +44,7 @@ func JSONReq(reqBody any) AgentOp {
 			if err := json.NewEncoder(&buffer).Encode(reqBody); err != nil {
 				return nil, fmt.Errorf("json marshal failed: %w", err)
 			}
-			req.ContentLength = int64(buffer.Len())
-			req.Body = io.NopCloser(bytes.NewReader(buffer.Bytes()))
+			setBytesBody(req, buffer.Bytes())
 			return req, nil
 		}))
 		return nil
@@ -67,8 +63,7 @@ func RawReq(contentType string, body []byte) AgentOp {
 			if contentType != "" {
 				req.Header.Set("Content-Type", contentType)
 			}
-			req.ContentLength = int64(len(body))
-			req.Body = io.NopCloser(bytes.NewReader(body))
+			setBytesBody(req, body)
 			return req, nil
 		}))
 		return nil
@@ -76,34 +71,40 @@ func RawReq(contentType string, body []byte) AgentOp {
 }
 
 // ReaderReq sends the provided reader as the request body.
+// Seekable readers are rewound on retries; other readers are read once and
+// replayed from memory, spilling to a temporary file when they are large.
+// The spill file is removed when Do returns, so a later Do with the same op
+// fails with ErrBodyReleased instead of sending a truncated body.
 func ReaderReq(contentType string, body io.Reader) AgentOp {
 	var (
-		once    sync.Once
-		bodyBuf []byte
-		bodyErr error
+		once       sync.Once
+		replayable ReplayableBody
+		bodyErr    error
 	)
 	return AgentOpFunc(func(agent *Agent) error {
 		agent.reqPreHandlers = append(agent.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
 			once.Do(func() {
-				if body == nil {
-					return
-				}
-				bodyBuf, bodyErr = io.ReadAll(body)
+				replayable, bodyErr = newReplayableBody(agent, body)
 			})
 			if bodyErr != nil {
-				return nil, fmt.Errorf("read request body failed: %w", bodyErr)
+				return nil, bodyErr
 			}
-			if contentType != "" {
-				req.Header.Set("Content-Type", contentType)
-			}
-			req.ContentLength = int64(len(bodyBuf))
-			req.Body = io.NopCloser(bytes.NewReader(bodyBuf))
-			return req, nil
+			return setReplayableBody(req, contentType, replayable)
 		}))
 		return nil
 	})
 }
 
+// newReplayableBody adapts body for replay. Spill files are removed when agent finishes.
+func newReplayableBody(agent *Agent, body io.Reader) (ReplayableBody, error) {
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		return SeekableBody(seeker)
+	}
+	spill := NewSpillBody(body, 0)
+	agent.cleanups = append(agent.cleanups, func() { _ = spill.Close() })
+	return spill, nil
+}
+
 // FormReq sends URL values as query parameters for GET and as form body otherwise.
 func FormReq(values url.Values) AgentOp {
 	return AgentOpFunc(func(agent *Agent) error {
@@ -119,13 +120,19 @@ func FormReq(values url.Values) AgentOp {
 				}
 				req.URL.RawQuery = query.Encode()
 			default:
-				encoded := values.Encode()
-				r := strings.NewReader(encoded)
-				req.ContentLength = int64(r.Len())
-				req.Body = io.NopCloser(r)
+				setBytesBody(req, []byte(values.Encode()))
 			}
 			return req, nil
 		}))
 		return nil
 	})
 }
+
+// setBytesBody sets an in-memory body that interceptors can replay through GetBody.
+func setBytesBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+}
@@ -0,0 +1,260 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultFileCheckInterval = time.Second
+
+// StaticConfig is the file format consumed by StaticResolver.
+type StaticConfig struct {
+	// Version is reported as ResolveResponse.Version. The file modification time is used when empty.
+	Version string `json:"version" yaml:"version"`
+	// Services lists the statically registered services.
+	Services []StaticService `json:"services" yaml:"services"`
+}
+
+// StaticService describes one statically registered service.
+type StaticService struct {
+	Name      string           `json:"name" yaml:"name"`
+	Namespace string           `json:"namespace" yaml:"namespace"`
+	Instances []StaticInstance `json:"instances" yaml:"instances"`
+}
+
+// StaticInstance describes one statically registered instance.
+type StaticInstance struct {
+	ID     string            `json:"id" yaml:"id"`
+	Host   string            `json:"host" yaml:"host"`
+	Port   int               `json:"port" yaml:"port"`
+	Scheme string            `json:"scheme" yaml:"scheme"`
+	Weight int               `json:"weight" yaml:"weight"`
+	Zone   string            `json:"zone" yaml:"zone"`
+	Labels map[string]string `json:"labels" yaml:"labels"`
+	// Health is one of healthy, unhealthy or unknown. Empty means healthy.
+	Health HealthStatus `json:"health" yaml:"health"`
+	// Ready is a readiness shortcut. When set, it overrides Health.
+	Ready *bool `json:"ready" yaml:"ready"`
+}
+
+// StaticResolver resolves services from an in-memory table or a YAML/JSON file.
+// File-backed resolvers reload the table when the file changes.
+type StaticResolver struct {
+	mu       sync.RWMutex
+	version  string
+	services map[string][]Instance
+	file     *watchedFile
+}
+
+// NewStaticResolver returns a resolver backed by an in-memory service table.
+func NewStaticResolver(cfg StaticConfig) (*StaticResolver, error) {
+	r := &StaticResolver{}
+	if err := r.apply(cfg, ""); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewStaticFileResolver returns a resolver backed by a YAML or JSON file.
+// The file is checked for changes at most once per checkInterval; zero means one second.
+// A file that fails to reload keeps the last good table and reports a warning.
+func NewStaticFileResolver(path string, checkInterval time.Duration) (*StaticResolver, error) {
+	r := &StaticResolver{file: newWatchedFile(path, checkInterval)}
+	if _, err := r.file.reloadIfChanged(r.load); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Resolve implements Resolver.
+func (r *StaticResolver) Resolve(_ context.Context, req ResolveRequest) (*ResolveResponse, error) {
+	var warnings []string
+	if r.file != nil {
+		if _, err := r.file.reloadIfChanged(r.load); err != nil {
+			warnings = append(warnings, fmt.Sprintf("static resolver reload failed, serving last good table: %v", err))
+		}
+	}
+	r.mu.RLock()
+	instances, ok := r.services[serviceKey(req.Namespace, req.ServiceName)]
+	version := r.version
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s.%s", ErrServiceNotFound, req.ServiceName, req.Namespace)
+	}
+	resp := buildResolveResponse(req, instances, version, 0)
+	resp.Warnings = append(resp.Warnings, warnings...)
+	return resp, nil
+}
+
+func (r *StaticResolver) load(content []byte, modTime time.Time) error {
+	var cfg StaticConfig
+	if err := unmarshalByExt(r.file.path, content, &cfg); err != nil {
+		return err
+	}
+	return r.apply(cfg, modTime.UTC().Format(time.RFC3339Nano))
+}
+
+func (r *StaticResolver) apply(cfg StaticConfig, defaultVersion string) error {
+	services := make(map[string][]Instance, len(cfg.Services))
+	for _, svc := range cfg.Services {
+		if svc.Name == "" || svc.Namespace == "" {
+			return datax.NewValidationError("static service requires name and namespace", nil, nil)
+		}
+		instances := make([]Instance, 0, len(svc.Instances))
+		for _, item := range svc.Instances {
+			if item.Host == "" {
+				return datax.NewValidationError(fmt.Sprintf("static service %s.%s has an instance without host", svc.Name, svc.Namespace), nil, nil)
+			}
+			inst := Instance{
+				InstanceID:   item.ID,
+				Host:         item.Host,
+				Port:         item.Port,
+				Scheme:       item.Scheme,
+				HealthStatus: item.Health,
+				Weight:       item.Weight,
+				Zone:         item.Zone,
+				Labels:       item.Labels,
+			}
+			if inst.HealthStatus == "" {
+				inst.HealthStatus = HealthStatusHealthy
+			}
+			if item.Ready != nil {
+				inst.HealthStatus = readinessToHealth(*item.Ready)
+			}
+			if inst.InstanceID == "" {
+				inst.InstanceID = instanceAddr(inst)
+			}
+			instances = append(instances, inst)
+		}
+		key := serviceKey(svc.Namespace, svc.Name)
+		services[key] = append(services[key], instances...)
+	}
+	version := cfg.Version
+	if version == "" {
+		version = defaultVersion
+	}
+	r.mu.Lock()
+	r.services = services
+	r.version = version
+	r.mu.Unlock()
+	return nil
+}
+
+// watchedFile reloads a file when its size or modification time changes.
+type watchedFile struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	modTime   time.Time
+	size      int64
+	loaded    bool
+}
+
+func newWatchedFile(path string, interval time.Duration) *watchedFile {
+	if interval <= 0 {
+		interval = defaultFileCheckInterval
+	}
+	return &watchedFile{path: path, interval: interval}
+}
+
+func (w *watchedFile) reloadIfChanged(load func(content []byte, modTime time.Time) error) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	if w.loaded && now.Sub(w.lastCheck) < w.interval {
+		return false, nil
+	}
+	w.lastCheck = now
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, fmt.Errorf("stat %s failed: %w", w.path, err)
+	}
+	if w.loaded && info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return false, nil
+	}
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, fmt.Errorf("read %s failed: %w", w.path, err)
+	}
+	if err := load(content, info.ModTime()); err != nil {
+		return false, fmt.Errorf("load %s failed: %w", w.path, err)
+	}
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	w.loaded = true
+	return true, nil
+}
+
+func unmarshalByExt(path string, content []byte, out any) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(content, out)
+	default:
+		return json.Unmarshal(content, out)
+	}
+}
+
+// buildResolveResponse applies the hard label selector and health mode of req to instances.
+func buildResolveResponse(req ResolveRequest, instances []Instance, version string, ttl time.Duration) *ResolveResponse {
+	resp := &ResolveResponse{
+		ServiceName: req.ServiceName,
+		Namespace:   req.Namespace,
+		ResolveTime: time.Now(),
+		Version:     version,
+		CacheTTL:    ttl,
+	}
+	for _, inst := range instances {
+		if !matchLabels(inst.Labels, req.LabelSelector) {
+			continue
+		}
+		if req.ResolveMode != ResolveModeAll && !isCallable(inst) {
+			continue
+		}
+		resp.Instances = append(resp.Instances, inst)
+	}
+	return resp
+}
+
+func matchLabels(labels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func isCallable(inst Instance) bool {
+	return inst.HealthStatus == "" || inst.HealthStatus == HealthStatusHealthy
+}
+
+func readinessToHealth(ready bool) HealthStatus {
+	if ready {
+		return HealthStatusHealthy
+	}
+	return HealthStatusUnhealthy
+}
+
+func serviceKey(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+func instanceAddr(inst Instance) string {
+	if inst.Port > 0 {
+		return net.JoinHostPort(inst.Host, strconv.Itoa(inst.Port))
+	}
+	return inst.Host
+}
@@ -0,0 +1,140 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNSLookuper is the subset of *net.Resolver used by DNSResolver.
+type DNSLookuper interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSResolverOptions configures DNSResolver.
+type DNSResolverOptions struct {
+	// Lookuper performs DNS queries. The default is net.DefaultResolver.
+	Lookuper DNSLookuper
+	// Domain is appended to "{service}.{namespace}" to build the queried name, e.g. "svc.cluster.local".
+	Domain string
+	// NameFunc overrides the queried name. It takes precedence over Domain.
+	NameFunc func(req ResolveRequest) string
+	// UseSRV queries SRV records instead of A/AAAA records.
+	UseSRV bool
+	// SRVService and SRVProto are passed to LookupSRV. Empty values query the name directly.
+	SRVService string
+	SRVProto   string
+	// Port is used for A/AAAA records, which do not carry a port.
+	Port int
+	// Scheme is set on every resolved instance.
+	Scheme string
+	// Zone is set on every resolved instance, e.g. when the DNS view is zone-local.
+	Zone string
+	// CacheTTL is reported as ResolveResponse.CacheTTL.
+	CacheTTL time.Duration
+}
+
+// DNSResolver resolves services from DNS SRV or A/AAAA records.
+// Every returned record is treated as healthy because DNS only publishes ready endpoints.
+type DNSResolver struct {
+	opt DNSResolverOptions
+}
+
+// NewDNSResolver returns a DNS-backed resolver.
+func NewDNSResolver(opt DNSResolverOptions) *DNSResolver {
+	if opt.Lookuper == nil {
+		opt.Lookuper = net.DefaultResolver
+	}
+	return &DNSResolver{opt: opt}
+}
+
+// Resolve implements Resolver.
+func (r *DNSResolver) Resolve(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+	name := r.queryName(req)
+	var (
+		instances []Instance
+		err       error
+	)
+	if r.opt.UseSRV {
+		instances, err = r.lookupSRV(ctx, name)
+	} else {
+		instances, err = r.lookupHost(ctx, name)
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, name)
+		}
+		return nil, fmt.Errorf("dns lookup %s failed: %w", name, err)
+	}
+	return buildResolveResponse(req, instances, "", r.opt.CacheTTL), nil
+}
+
+func (r *DNSResolver) queryName(req ResolveRequest) string {
+	if r.opt.NameFunc != nil {
+		return r.opt.NameFunc(req)
+	}
+	name := req.ServiceName + "." + req.Namespace
+	if domain := strings.Trim(r.opt.Domain, "."); domain != "" {
+		name += "." + domain
+	}
+	return name
+}
+
+// lookupSRV keeps only the records of the lowest priority, as RFC 2782 requires
+// higher priorities to be used only when lower ones are unreachable.
+func (r *DNSResolver) lookupSRV(ctx context.Context, name string) ([]Instance, error) {
+	_, records, err := r.opt.Lookuper.LookupSRV(ctx, r.opt.SRVService, r.opt.SRVProto, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Priority < records[j].Priority
+	})
+	lowest := records[0].Priority
+	instances := make([]Instance, 0, len(records))
+	for _, rec := range records {
+		if rec.Priority != lowest {
+			break
+		}
+		inst := Instance{
+			Host:         strings.TrimSuffix(rec.Target, "."),
+			Port:         int(rec.Port),
+			Scheme:       r.opt.Scheme,
+			HealthStatus: HealthStatusHealthy,
+			Weight:       int(rec.Weight),
+			Zone:         r.opt.Zone,
+		}
+		inst.InstanceID = instanceAddr(inst)
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
+
+func (r *DNSResolver) lookupHost(ctx context.Context, name string) ([]Instance, error) {
+	hosts, err := r.opt.Lookuper.LookupHost(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, 0, len(hosts))
+	for _, host := range hosts {
+		inst := Instance{
+			Host:         host,
+			Port:         r.opt.Port,
+			Scheme:       r.opt.Scheme,
+			HealthStatus: HealthStatusHealthy,
+			Zone:         r.opt.Zone,
+		}
+		inst.InstanceID = instanceAddr(inst)
+		instances = append(instances, inst)
+	}
+	return instances, nil
+}
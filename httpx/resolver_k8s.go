@@ -0,0 +1,257 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+const (
+	// LabelK8sServiceName is the EndpointSlice label that links a slice to its Service.
+	LabelK8sServiceName = "kubernetes.io/service-name"
+	// LabelK8sNodeName is set on instances resolved from EndpointSlices that report a node.
+	LabelK8sNodeName = "kubernetes.io/hostname"
+)
+
+// EndpointSliceList is the subset of a discovery.k8s.io/v1 EndpointSliceList used for discovery.
+type EndpointSliceList struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []EndpointSlice `json:"items"`
+}
+
+// EndpointSlice is the subset of a discovery.k8s.io/v1 EndpointSlice used for discovery.
+type EndpointSlice struct {
+	Metadata struct {
+		Name            string            `json:"name"`
+		Namespace       string            `json:"namespace"`
+		Labels          map[string]string `json:"labels"`
+		ResourceVersion string            `json:"resourceVersion"`
+	} `json:"metadata"`
+	AddressType string                  `json:"addressType"`
+	Endpoints   []EndpointSliceEndpoint `json:"endpoints"`
+	Ports       []EndpointSlicePort     `json:"ports"`
+}
+
+// EndpointSliceEndpoint is one endpoint of an EndpointSlice.
+type EndpointSliceEndpoint struct {
+	Addresses  []string `json:"addresses"`
+	Conditions struct {
+		Ready       *bool `json:"ready"`
+		Serving     *bool `json:"serving"`
+		Terminating *bool `json:"terminating"`
+	} `json:"conditions"`
+	Hostname  string `json:"hostname"`
+	NodeName  string `json:"nodeName"`
+	Zone      string `json:"zone"`
+	TargetRef *struct {
+		Kind string `json:"kind"`
+		Name string `json:"name"`
+	} `json:"targetRef"`
+}
+
+// EndpointSlicePort is one named port of an EndpointSlice.
+type EndpointSlicePort struct {
+	Name     string `json:"name"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+// EndpointSliceOptions configures EndpointSliceResolver.
+// Exactly one of FilePath and APIServer must be set.
+type EndpointSliceOptions struct {
+	// FilePath is a local JSON file holding an EndpointSliceList or a single EndpointSlice.
+	FilePath string
+	// FileCheckInterval bounds how often FilePath is checked for changes. Zero means one second.
+	FileCheckInterval time.Duration
+	// APIServer is the base URL of a Kubernetes-compatible API server, e.g. a fake server in tests.
+	APIServer string
+	// BearerToken is sent to APIServer when set.
+	BearerToken string
+	// Client is used for APIServer requests. The default is DefaultClient.
+	Client *http.Client
+	// PortName selects the EndpointSlice port by name. The first port is used when empty.
+	PortName string
+	// Scheme is set on every resolved instance.
+	Scheme string
+	// CacheTTL is reported as ResolveResponse.CacheTTL.
+	CacheTTL time.Duration
+}
+
+// EndpointSliceResolver resolves services from Kubernetes EndpointSlice-shaped JSON.
+// Endpoint readiness maps to HealthStatus and the topology zone maps to Instance.Zone.
+type EndpointSliceResolver struct {
+	opt EndpointSliceOptions
+
+	mu     sync.RWMutex
+	file   *watchedFile
+	slices EndpointSliceList
+}
+
+// NewEndpointSliceResolver returns a resolver reading EndpointSlices from a file or an API server.
+func NewEndpointSliceResolver(opt EndpointSliceOptions) (*EndpointSliceResolver, error) {
+	if (opt.FilePath == "") == (opt.APIServer == "") {
+		return nil, datax.NewValidationError("endpoint slice resolver requires exactly one of FilePath and APIServer", nil, nil)
+	}
+	r := &EndpointSliceResolver{opt: opt}
+	if opt.FilePath != "" {
+		r.file = newWatchedFile(opt.FilePath, opt.FileCheckInterval)
+		if _, err := r.file.reloadIfChanged(r.load); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Resolve implements Resolver.
+func (r *EndpointSliceResolver) Resolve(ctx context.Context, req ResolveRequest) (*ResolveResponse, error) {
+	var (
+		list     EndpointSliceList
+		warnings []string
+	)
+	if r.file != nil {
+		if _, err := r.file.reloadIfChanged(r.load); err != nil {
+			warnings = append(warnings, fmt.Sprintf("endpoint slice reload failed, serving last good slices: %v", err))
+		}
+		r.mu.RLock()
+		list = r.slices
+		r.mu.RUnlock()
+	} else {
+		fetched, err := r.fetch(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		list = *fetched
+	}
+
+	var (
+		instances []Instance
+		found     bool
+	)
+	for _, slice := range list.Items {
+		if slice.Metadata.Namespace != req.Namespace || slice.Metadata.Labels[LabelK8sServiceName] != req.ServiceName {
+			continue
+		}
+		found = true
+		instances = append(instances, r.sliceInstances(slice)...)
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %s.%s", ErrServiceNotFound, req.ServiceName, req.Namespace)
+	}
+	resp := buildResolveResponse(req, instances, list.Metadata.ResourceVersion, r.opt.CacheTTL)
+	resp.Warnings = append(resp.Warnings, warnings...)
+	return resp, nil
+}
+
+func (r *EndpointSliceResolver) load(content []byte, _ time.Time) error {
+	list, err := decodeEndpointSlices(content)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.slices = *list
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *EndpointSliceResolver) fetch(ctx context.Context, req ResolveRequest) (*EndpointSliceList, error) {
+	target := fmt.Sprintf("%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?labelSelector=%s",
+		strings.TrimSuffix(r.opt.APIServer, "/"),
+		url.PathEscape(req.Namespace),
+		url.QueryEscape(LabelK8sServiceName+"="+req.ServiceName),
+	)
+	var raw json.RawMessage
+	ops := []AgentOp{Context(ctx), JSONResp(&raw)}
+	if r.opt.Client != nil {
+		ops = append(ops, Client(r.opt.Client))
+	}
+	if r.opt.BearerToken != "" {
+		ops = append(ops, SetHeader(http.Header{"Authorization": []string{"Bearer " + r.opt.BearerToken}}))
+	}
+	if err := Get(target, ops...).Do(); err != nil {
+		return nil, fmt.Errorf("list endpoint slices failed: %w", err)
+	}
+	return decodeEndpointSlices(raw)
+}
+
+func (r *EndpointSliceResolver) sliceInstances(slice EndpointSlice) []Instance {
+	port, ok := r.selectPort(slice.Ports)
+	if !ok {
+		return nil
+	}
+	instances := make([]Instance, 0, len(slice.Endpoints))
+	for _, ep := range slice.Endpoints {
+		var labels map[string]string
+		if ep.NodeName != "" {
+			labels = map[string]string{LabelK8sNodeName: ep.NodeName}
+		}
+		for _, addr := range ep.Addresses {
+			inst := Instance{
+				Host:         addr,
+				Port:         port,
+				Scheme:       r.opt.Scheme,
+				HealthStatus: endpointHealth(ep),
+				Zone:         ep.Zone,
+				Labels:       labels,
+			}
+			inst.InstanceID = instanceAddr(inst)
+			if ep.TargetRef != nil && ep.TargetRef.Name != "" && len(ep.Addresses) == 1 {
+				inst.InstanceID = ep.TargetRef.Name
+			}
+			instances = append(instances, inst)
+		}
+	}
+	return instances
+}
+
+func (r *EndpointSliceResolver) selectPort(ports []EndpointSlicePort) (int, bool) {
+	for _, p := range ports {
+		if r.opt.PortName == "" || p.Name == r.opt.PortName {
+			return p.Port, p.Port > 0
+		}
+	}
+	return 0, false
+}
+
+// endpointHealth follows the EndpointSlice API: a nil ready condition means ready,
+// and terminating endpoints are never callable for new requests.
+func endpointHealth(ep EndpointSliceEndpoint) HealthStatus {
+	if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+		return HealthStatusUnhealthy
+	}
+	if ep.Conditions.Ready == nil {
+		return HealthStatusHealthy
+	}
+	return readinessToHealth(*ep.Conditions.Ready)
+}
+
+func decodeEndpointSlices(content []byte) (*EndpointSliceList, error) {
+	var probe struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return nil, fmt.Errorf("decode endpoint slices failed: %w", err)
+	}
+	if probe.Kind == "EndpointSlice" {
+		var slice EndpointSlice
+		if err := json.Unmarshal(content, &slice); err != nil {
+			return nil, fmt.Errorf("decode endpoint slice failed: %w", err)
+		}
+		list := &EndpointSliceList{Items: []EndpointSlice{slice}}
+		list.Metadata.ResourceVersion = slice.Metadata.ResourceVersion
+		return list, nil
+	}
+	var list EndpointSliceList
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("decode endpoint slice list failed: %w", err)
+	}
+	return &list, nil
+}
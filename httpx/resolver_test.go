@@ -0,0 +1,270 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "services.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  - name: inventory
+    namespace: prod
+    instances:
+      - id: inv-1
+        host: 10.0.0.1
+        port: 8080
+        zone: zone-a
+        labels: {version: v1}
+      - host: 10.0.0.2
+        port: 8080
+        ready: false
+`), 0o600))
+
+	resolver, err := NewStaticFileResolver(path, time.Millisecond)
+	require.NoError(t, err)
+
+	req := ResolveRequest{ServiceName: "inventory", Namespace: "prod", ResolveMode: ResolveModeHealthyOnly}
+	resp, err := resolver.Resolve(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, resp.Instances, 1)
+	require.Equal(t, "inv-1", resp.Instances[0].InstanceID)
+	require.Equal(t, "zone-a", resp.Instances[0].Zone)
+	require.Equal(t, HealthStatusHealthy, resp.Instances[0].HealthStatus)
+
+	req.ResolveMode = ResolveModeAll
+	resp, err = resolver.Resolve(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, resp.Instances, 2)
+	require.Equal(t, "10.0.0.2:8080", resp.Instances[1].InstanceID)
+	require.Equal(t, HealthStatusUnhealthy, resp.Instances[1].HealthStatus)
+
+	req.LabelSelector = map[string]string{"version": "v2"}
+	resp, err = resolver.Resolve(context.Background(), req)
+	require.NoError(t, err)
+	require.Empty(t, resp.Instances)
+
+	_, err = resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "missing", Namespace: "prod"})
+	require.ErrorIs(t, err, ErrServiceNotFound)
+	require.Equal(t, ErrCodeHTTPServiceNotFound, datax.CodeOf(err))
+
+	t.Run("reloads the file when it changes and keeps the last good table on errors", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte(`
+version: v2
+services:
+  - name: inventory
+    namespace: prod
+    instances:
+      - host: 10.0.0.3
+        port: 9090
+`), 0o600))
+		require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+		time.Sleep(5 * time.Millisecond)
+
+		resp, err := resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.NoError(t, err)
+		require.Equal(t, "v2", resp.Version)
+		require.Len(t, resp.Instances, 1)
+		require.Equal(t, "10.0.0.3", resp.Instances[0].Host)
+
+		require.NoError(t, os.WriteFile(path, []byte("services: [broken"), 0o600))
+		require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second)))
+		time.Sleep(5 * time.Millisecond)
+
+		resp, err = resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.NoError(t, err)
+		require.Len(t, resp.Instances, 1)
+		require.NotEmpty(t, resp.Warnings)
+	})
+}
+
+func TestStaticResolverValidatesConfig(t *testing.T) {
+	_, err := NewStaticResolver(StaticConfig{Services: []StaticService{{Name: "inventory"}}})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	_, err = NewStaticResolver(StaticConfig{Services: []StaticService{{
+		Name:      "inventory",
+		Namespace: "prod",
+		Instances: []StaticInstance{{Port: 80}},
+	}}})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+type fakeDNSLookuper struct {
+	srv   []*net.SRV
+	hosts []string
+	err   error
+	names []string
+}
+
+func (f *fakeDNSLookuper) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	f.names = append(f.names, service+"/"+proto+"/"+name)
+	return name, f.srv, f.err
+}
+
+func (f *fakeDNSLookuper) LookupHost(_ context.Context, host string) ([]string, error) {
+	f.names = append(f.names, host)
+	return f.hosts, f.err
+}
+
+func TestDNSResolver(t *testing.T) {
+	t.Run("SRV records keep the lowest priority and map weight and port", func(t *testing.T) {
+		lookuper := &fakeDNSLookuper{srv: []*net.SRV{
+			{Target: "b.inventory.prod.svc.", Port: 9090, Priority: 20, Weight: 1},
+			{Target: "a.inventory.prod.svc.", Port: 8080, Priority: 10, Weight: 30},
+			{Target: "c.inventory.prod.svc.", Port: 8081, Priority: 10, Weight: 70},
+		}}
+		resolver := NewDNSResolver(DNSResolverOptions{
+			Lookuper:   lookuper,
+			Domain:     "svc.cluster.local.",
+			UseSRV:     true,
+			SRVService: "http",
+			SRVProto:   "tcp",
+			Scheme:     "http",
+		})
+
+		resp, err := resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"http/tcp/inventory.prod.svc.cluster.local"}, lookuper.names)
+		require.Len(t, resp.Instances, 2)
+		require.Equal(t, Instance{
+			InstanceID:   "a.inventory.prod.svc:8080",
+			Host:         "a.inventory.prod.svc",
+			Port:         8080,
+			Scheme:       "http",
+			HealthStatus: HealthStatusHealthy,
+			Weight:       30,
+		}, resp.Instances[0])
+		require.Equal(t, 70, resp.Instances[1].Weight)
+	})
+
+	t.Run("A records use the configured port", func(t *testing.T) {
+		resolver := NewDNSResolver(DNSResolverOptions{
+			Lookuper: &fakeDNSLookuper{hosts: []string{"10.0.0.1", "10.0.0.2"}},
+			Port:     8080,
+			Zone:     "zone-a",
+		})
+
+		resp, err := resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.NoError(t, err)
+		require.Len(t, resp.Instances, 2)
+		require.Equal(t, "10.0.0.2:8080", resp.Instances[1].InstanceID)
+		require.Equal(t, "zone-a", resp.Instances[1].Zone)
+	})
+
+	t.Run("NXDOMAIN maps to ErrServiceNotFound", func(t *testing.T) {
+		resolver := NewDNSResolver(DNSResolverOptions{
+			Lookuper: &fakeDNSLookuper{err: &net.DNSError{Err: "no such host", Name: "inventory.prod", IsNotFound: true}},
+		})
+
+		_, err := resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.ErrorIs(t, err, ErrServiceNotFound)
+	})
+}
+
+const testEndpointSlices = `{
+  "kind": "EndpointSliceList",
+  "metadata": {"resourceVersion": "42"},
+  "items": [{
+    "metadata": {"name": "inventory-abc", "namespace": "prod", "labels": {"kubernetes.io/service-name": "inventory"}},
+    "addressType": "IPv4",
+    "endpoints": [
+      {"addresses": ["10.0.0.1"], "conditions": {"ready": true}, "zone": "zone-a", "nodeName": "node-1", "targetRef": {"kind": "Pod", "name": "inventory-1"}},
+      {"addresses": ["10.0.0.2"], "conditions": {"ready": false}, "zone": "zone-b"},
+      {"addresses": ["10.0.0.3"], "conditions": {"ready": true, "terminating": true}, "zone": "zone-b"},
+      {"addresses": ["10.0.0.4"], "conditions": {}, "zone": "zone-b"}
+    ],
+    "ports": [{"name": "metrics", "port": 9100}, {"name": "http", "port": 8080}]
+  }, {
+    "metadata": {"name": "billing-abc", "namespace": "prod", "labels": {"kubernetes.io/service-name": "billing"}},
+    "endpoints": [{"addresses": ["10.0.1.1"]}],
+    "ports": [{"name": "http", "port": 8080}]
+  }]
+}`
+
+func TestEndpointSliceResolver(t *testing.T) {
+	t.Run("maps readiness, zone and named ports from a local file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "slices.json")
+		require.NoError(t, os.WriteFile(path, []byte(testEndpointSlices), 0o600))
+		resolver, err := NewEndpointSliceResolver(EndpointSliceOptions{FilePath: path, PortName: "http"})
+		require.NoError(t, err)
+
+		resp, err := resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod", ResolveMode: ResolveModeAll})
+		require.NoError(t, err)
+		require.Equal(t, "42", resp.Version)
+		require.Len(t, resp.Instances, 4)
+		require.Equal(t, Instance{
+			InstanceID:   "inventory-1",
+			Host:         "10.0.0.1",
+			Port:         8080,
+			HealthStatus: HealthStatusHealthy,
+			Zone:         "zone-a",
+			Labels:       map[string]string{LabelK8sNodeName: "node-1"},
+		}, resp.Instances[0])
+		require.Equal(t, HealthStatusUnhealthy, resp.Instances[1].HealthStatus)
+		require.Equal(t, HealthStatusUnhealthy, resp.Instances[2].HealthStatus)
+		require.Equal(t, HealthStatusHealthy, resp.Instances[3].HealthStatus)
+
+		resp, err = resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "prod"})
+		require.NoError(t, err)
+		require.Len(t, resp.Instances, 2)
+
+		_, err = resolver.Resolve(context.Background(), ResolveRequest{ServiceName: "inventory", Namespace: "staging"})
+		require.ErrorIs(t, err, ErrServiceNotFound)
+	})
+
+	t.Run("lists slices from a fake API server and drives discovery", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "inventory.prod", r.Host)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}))
+		defer upstream.Close()
+		addr := upstream.Listener.Addr().(*net.TCPAddr)
+
+		var gotPath, gotSelector, gotAuth string
+		apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			gotSelector = r.URL.Query().Get("labelSelector")
+			gotAuth = r.Header.Get("Authorization")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"kind": "EndpointSliceList",
+				"items": []any{map[string]any{
+					"metadata":  map[string]any{"namespace": "prod", "labels": map[string]string{LabelK8sServiceName: "inventory"}},
+					"endpoints": []any{map[string]any{"addresses": []string{addr.IP.String()}, "conditions": map[string]bool{"ready": true}}},
+					"ports":     []any{map[string]any{"name": "http", "port": addr.Port}},
+				}},
+			})
+		}))
+		defer apiServer.Close()
+
+		resolver, err := NewEndpointSliceResolver(EndpointSliceOptions{APIServer: apiServer.URL, BearerToken: "token", Scheme: "http"})
+		require.NoError(t, err)
+
+		var resp map[string]bool
+		err = Get("http://inventory.prod/api",
+			Service(ServiceOptions{EnableDiscovery: true, Resolver: resolver}),
+			JSONResp(&resp),
+		).Do()
+		require.NoError(t, err)
+		require.True(t, resp["ok"])
+		require.Equal(t, "/apis/discovery.k8s.io/v1/namespaces/prod/endpointslices", gotPath)
+		require.Equal(t, "kubernetes.io/service-name=inventory", gotSelector)
+		require.Equal(t, "Bearer token", gotAuth)
+	})
+
+	t.Run("requires exactly one source", func(t *testing.T) {
+		_, err := NewEndpointSliceResolver(EndpointSliceOptions{})
+		require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+	})
+}
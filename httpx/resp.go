@@ -2,12 +2,14 @@ package httpx
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
 	"reflect"
 
 	"github.com/dev-ofa/core-go/model/datax"
+	"google.golang.org/protobuf/proto"
 )
 
 // Wrapper describes a standard application response wrapper.
@@ -138,3 +140,83 @@ func (h *JSONRespHandler) InitialAgent(a *Agent) error {
 	a.respHandler = h
 	return nil
 }
+
+// XMLResp decodes an XML response into ret. ret must be a pointer.
+func XMLResp(ret any) *XMLRespHandler {
+	return &XMLRespHandler{ret: ret}
+}
+
+// XMLRespHandler decodes XML responses and validates optional wrappers.
+type XMLRespHandler struct {
+	ret any
+}
+
+// HandleResponse implements RespHandler.
+func (h *XMLRespHandler) HandleResponse(resp *http.Response, respWrapper Wrapper) error {
+	ret := h.ret
+	if respWrapper != nil {
+		respWrapper.SetData(h.ret)
+		ret = respWrapper
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body failed: %w", err)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if err := xml.Unmarshal(body, ret); err != nil {
+		return fmt.Errorf("unmarshal xml body failed: %w, body: %s", err, string(body))
+	}
+	if respWrapper != nil {
+		return respWrapper.Validate()
+	}
+	return nil
+}
+
+// InitialAgent installs the XML response handler.
+func (h *XMLRespHandler) InitialAgent(a *Agent) error {
+	if h.ret == nil || reflect.TypeOf(h.ret).Kind() != reflect.Ptr {
+		return datax.NewValidationError("result payload should be ptr", nil, nil)
+	}
+	a.respHandler = h
+	return nil
+}
+
+// ProtoResp decodes a binary protobuf response into msg.
+// Response wrappers are not applied because protobuf payloads carry their own schema.
+func ProtoResp(msg proto.Message) *ProtoRespHandler {
+	return &ProtoRespHandler{msg: msg}
+}
+
+// ProtoRespHandler decodes binary protobuf responses.
+type ProtoRespHandler struct {
+	msg proto.Message
+}
+
+// HandleResponse implements RespHandler.
+func (h *ProtoRespHandler) HandleResponse(resp *http.Response, _ Wrapper) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read body failed: %w", err)
+	}
+	if err := proto.Unmarshal(body, h.msg); err != nil {
+		return fmt.Errorf("unmarshal protobuf body failed: %w", err)
+	}
+	return nil
+}
+
+// InitialAgent installs the protobuf response handler.
+func (h *ProtoRespHandler) InitialAgent(a *Agent) error {
+	if h.msg == nil || reflect.ValueOf(h.msg).IsNil() {
+		return datax.NewValidationError("result payload should be a non-nil proto message", nil, nil)
+	}
+	a.respHandler = h
+	a.reqPreHandlers = append(a.reqPreHandlers, ReqPreHandlerFunc(func(req *http.Request) (*http.Request, error) {
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", "application/x-protobuf")
+		}
+		return req, nil
+	}))
+	return nil
+}
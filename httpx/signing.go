@@ -0,0 +1,330 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// HeaderSignDate carries the signing timestamp of HMACSigner.
+	HeaderSignDate = "ofa-direct-sign-date"
+	// HeaderContentSHA256 carries the hex SHA-256 of the request body signed by HMACSigner.
+	HeaderContentSHA256 = "ofa-direct-content-sha256"
+
+	// DefaultHMACScheme is the Authorization scheme written by HMACSigner.
+	DefaultHMACScheme = "OFA-HMAC-SHA256"
+
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Signer signs a request right before it is sent. Signers run on every attempt
+// after request pre-handlers, trace header injection, discovery rewrite and all
+// interceptors, so every retry is signed again with a fresh timestamp.
+type Signer interface {
+	Sign(req *http.Request, now time.Time) error
+}
+
+// SignerFunc adapts a function into a Signer.
+type SignerFunc func(req *http.Request, now time.Time) error
+
+// Sign implements Signer.
+func (f SignerFunc) Sign(req *http.Request, now time.Time) error {
+	return f(req, now)
+}
+
+// Sign appends signers to the final signing stage. Signers run in the order they are added.
+func Sign(signers ...Signer) AgentOpFunc {
+	return func(agent *Agent) error {
+		for _, signer := range signers {
+			if signer != nil {
+				agent.signers = append(agent.signers, signer)
+			}
+		}
+		return nil
+	}
+}
+
+func (a *Agent) sign(req *http.Request) error {
+	now := time.Now().UTC()
+	for _, signer := range a.signers {
+		if err := signer.Sign(req, now); err != nil {
+			return fmt.Errorf("sign request failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// PayloadHash returns the hex SHA-256 of the request body without consuming
+// it. Replayable bodies are hashed from a fresh reader and req.Body is then
+// reopened, since the readers of a body may share one source, e.g. a
+// SeekableBody. Other bodies are buffered in memory and made replayable.
+func PayloadHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+	if req.GetBody == nil {
+		content, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("read request body failed: %w", err)
+		}
+		setBytesBody(req, content)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "", fmt.Errorf("open request body failed: %w", err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, body)
+	_ = body.Close()
+	if err != nil {
+		return "", fmt.Errorf("hash request body failed: %w", err)
+	}
+	fresh, err := req.GetBody()
+	if err != nil {
+		return "", fmt.Errorf("reopen request body failed: %w", err)
+	}
+	_ = req.Body.Close()
+	req.Body = fresh
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HMACSigner signs a canonical request with HMAC-SHA256. It sets
+// HeaderSignDate and HeaderContentSHA256 and writes
+//
+//	Authorization: <Scheme> KeyId=<KeyID>, SignedHeaders=<h1;h2>, Signature=<hex>
+//
+// The signature covers "<Scheme>\n<date>\n<hex sha256 of canonical request>",
+// where the canonical request is the method, escaped path, sorted query,
+// signed headers and body hash, one per line, as in SigV4.
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+	// Scheme is the Authorization scheme. The default is DefaultHMACScheme.
+	Scheme string
+	// SignedHeaders lists extra headers to sign. Host, HeaderSignDate and
+	// HeaderContentSHA256 are always signed.
+	SignedHeaders []string
+}
+
+// Sign implements Signer.
+func (s *HMACSigner) Sign(req *http.Request, now time.Time) error {
+	payloadHash, err := PayloadHash(req)
+	if err != nil {
+		return err
+	}
+	scheme := s.Scheme
+	if scheme == "" {
+		scheme = DefaultHMACScheme
+	}
+	date := now.UTC().Format(sigV4TimeFormat)
+	req.Header.Set(HeaderSignDate, date)
+	req.Header.Set(HeaderContentSHA256, payloadHash)
+
+	names := append([]string{"host", HeaderSignDate, HeaderContentSHA256}, s.SignedHeaders...)
+	headers, signedHeaders := canonicalHeaders(req, names)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, false),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := scheme + "\n" + date + "\n" + sha256Hex([]byte(canonical))
+	signature := hex.EncodeToString(hmacSHA256(s.Secret, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s KeyId=%s, SignedHeaders=%s, Signature=%s", scheme, s.KeyID, signedHeaders, signature))
+	return nil
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4 in the Authorization header.
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent as X-Amz-Security-Token when set.
+	SessionToken string
+	Region       string
+	Service      string
+	// SignedHeaders lists extra headers to sign. Host, Content-Type and all
+	// X-Amz-* headers are always signed.
+	SignedHeaders []string
+	// ContentSHA256Header sets X-Amz-Content-Sha256, which S3 requires.
+	ContentSHA256Header bool
+	// UnsignedPayload skips body hashing, e.g. for large S3 uploads. It implies ContentSHA256Header.
+	UnsignedPayload bool
+	// DisableURIPathEscaping signs the path escaped once, as S3 expects. Other
+	// services escape the already escaped path again.
+	DisableURIPathEscaping bool
+}
+
+// Sign implements Signer.
+func (s *SigV4Signer) Sign(req *http.Request, now time.Time) error {
+	payloadHash := unsignedPayload
+	if !s.UnsignedPayload {
+		var err error
+		if payloadHash, err = PayloadHash(req); err != nil {
+			return err
+		}
+	}
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	if s.ContentSHA256Header || s.UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	names := append([]string{"host"}, s.SignedHeaders...)
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	headers, signedHeaders := canonicalHeaders(req, names)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, !s.DisableURIPathEscaping),
+		canonicalQuery(req.URL),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := strings.Join([]string{now.Format(sigV4DateFormat), s.Region, s.Service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// canonicalHeaders returns the "name:value\n" block and the ";"-joined names,
+// both lowercased, sorted and deduplicated. Host comes from req.Host when set,
+// which keeps the original service host after discovery rewrite.
+func canonicalHeaders(req *http.Request, names []string) (string, string) {
+	seen := map[string]struct{}{}
+	sorted := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := seen[name]; ok || name == "" {
+			continue
+		}
+		seen[name] = struct{}{}
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	var b strings.Builder
+	for _, name := range sorted {
+		var values []string
+		if name == "host" {
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			values = []string{host}
+		} else {
+			values = headerValues(req.Header, name)
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(values, ","))
+		b.WriteByte('\n')
+	}
+	return b.String(), strings.Join(sorted, ";")
+}
+
+// headerValues returns a copy of the values of name, including keys that were
+// written without canonicalization, e.g. by SetHeader.
+func headerValues(header http.Header, name string) []string {
+	var values []string
+	for key, vals := range header {
+		if strings.EqualFold(key, name) {
+			values = append(values, vals...)
+		}
+	}
+	return values
+}
+
+func canonicalPath(u *url.URL, escapeTwice bool) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if !escapeTwice {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return uriEncode(keys[i]) < uriEncode(keys[j]) })
+	var pairs []string
+	for _, key := range keys {
+		values := make([]string, len(query[key]))
+		for i, value := range query[key] {
+			values[i] = uriEncode(value)
+		}
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key)+"="+value)
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything except RFC 3986 unreserved characters.
+func uriEncode(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigV4SignerMatchesReferenceVectors(t *testing.T) {
+	signer := &SigV4Signer{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for target, signature := range map[string]string{
+		"https://example.amazonaws.com/":                             "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		"https://example.amazonaws.com/?Param2=value2&Param1=value1": "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	} {
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		require.NoError(t, err)
+		require.NoError(t, signer.Sign(req, now))
+		require.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		require.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+signature,
+			req.Header.Get("Authorization"), target)
+	}
+}
+
+func TestHMACSignerSignsEveryAttemptLast(t *testing.T) {
+	signer := &HMACSigner{KeyID: "partner", Secret: []byte("k"), SignedHeaders: []string{"Content-Type", "X-Tenant", HeaderTraceID}}
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "streamed payload", string(body))
+		require.NotEmpty(t, r.Header.Get(HeaderTraceID))
+
+		date, err := time.Parse(sigV4TimeFormat, r.Header.Get(HeaderSignDate))
+		require.NoError(t, err)
+		verify, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), bytes.NewReader(body))
+		require.NoError(t, err)
+		for k, v := range r.Header {
+			if k != "Authorization" {
+				verify.Header[k] = v
+			}
+		}
+		require.NoError(t, signer.Sign(verify, date))
+		require.Equal(t, verify.Header.Get("Authorization"), r.Header.Get("Authorization"))
+		require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "OFA-HMAC-SHA256 KeyId=partner, SignedHeaders=content-type;host;ofa-direct-content-sha256;ofa-direct-sign-date;ofa-pass-trace-id;x-tenant, "))
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	var signed atomic.Int32
+	err := Post(server.URL+"/v1/items?b=2&a=1",
+		Retry(&RetryOpt{Attempts: 2, Idempotent: true}),
+		RetryStatusCodes([]int{http.StatusServiceUnavailable}),
+		ReaderReq("text/plain", onlyReader{strings.NewReader("streamed payload")}),
+		Interceptors(InterceptorFunc(func(attempt *Attempt, next Invoker) (*http.Response, error) {
+			attempt.Request.Header.Set("X-Tenant", "t1")
+			return next(attempt)
+		})),
+		Sign(signer, SignerFunc(func(req *http.Request, now time.Time) error {
+			signed.Add(1)
+			require.NotEmpty(t, req.Header.Get("Authorization"), "signers run in order")
+			return nil
+		})),
+	).Do()
+	require.NoError(t, err)
+	require.Equal(t, int32(2), calls.Load())
+	require.Equal(t, int32(2), signed.Load(), "retries are signed again")
+}
+
+func TestPayloadHashKeepsBodyReadable(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.test", nil)
+	require.NoError(t, err)
+	hash, err := PayloadHash(req)
+	require.NoError(t, err)
+	require.Equal(t, emptyPayloadHash, hash)
+
+	req.Body = io.NopCloser(onlyReader{strings.NewReader("abc")})
+	hash, err = PayloadHash(req)
+	require.NoError(t, err)
+	require.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", hash)
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, "abc", string(body))
+	require.NotNil(t, req.GetBody)
+}
+
+func TestSignersKeepSeekableBodiesReadable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	signers := map[string]Signer{
+		"hmac":  &HMACSigner{KeyID: "partner", Secret: []byte("k")},
+		"sigv4": &SigV4Signer{AccessKeyID: "id", SecretAccessKey: "secret", Region: "us-east-1", Service: "service"},
+	}
+	for name, signer := range signers {
+		for kind, body := range map[string]io.ReadSeeker{
+			"strings": strings.NewReader("hello world"),
+			"bytes":   bytes.NewReader([]byte("hello world")),
+		} {
+			var echoed []byte
+			err := Post(server.URL, ReaderReq("text/plain", body), Sign(signer), RawResp(&http.Response{}, &echoed)).Do()
+			require.NoError(t, err, name+"/"+kind)
+			require.Equal(t, "hello world", string(echoed), name+"/"+kind)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+const (
+	// HeaderLastEventID is sent on reconnect so the server can resume the stream.
+	HeaderLastEventID = "Last-Event-ID"
+
+	defaultSSEReconnectDelay = 3 * time.Second
+	defaultSSEMaxEventSize   = 1 << 20
+)
+
+// SSEEvent is one dispatched Server-Sent Event.
+type SSEEvent struct {
+	// ID is the last event id seen on the stream, including ids set by earlier events.
+	ID string
+	// Event is the event type. The default is "message".
+	Event string
+	// Data joins every data line of the event with "\n".
+	Data string
+}
+
+// SSEOptions configures SSEStream.
+type SSEOptions struct {
+	// LastEventID resumes a stream from a known event id.
+	LastEventID string
+	// ReconnectDelay is the wait before reconnecting until the server sends retry:. The default is three seconds.
+	ReconnectDelay time.Duration
+	// MaxReconnects bounds reconnect attempts. Zero means unlimited within the timeout
+	// budget and a negative value disables reconnecting.
+	MaxReconnects int
+	// Timeout is the budget of the whole stream, including reconnects, when ctx has
+	// no authoritative deadline. Zero means the agent default.
+	Timeout time.Duration
+	// MaxEventSize bounds one line of the stream. The default is 1 MiB.
+	MaxEventSize int
+}
+
+// SSEStream reads Server-Sent Events and reconnects with Last-Event-ID when the
+// connection drops. Every connection carries the same trace id and the remaining
+// timeout budget. It is not safe for concurrent use.
+//
+//	stream := httpx.NewSSEStream(ctx, url, httpx.SSEOptions{Timeout: time.Minute})
+//	defer stream.Close()
+//	for stream.Next() {
+//		event := stream.Event()
+//	}
+//	if err := stream.Err(); err != nil { ... }
+type SSEStream struct {
+	url    string
+	opt    SSEOptions
+	ops    []AgentOp
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	resp        *http.Response
+	scanner     *bufio.Scanner
+	lastEventID string
+	delay       time.Duration
+	reconnects  int
+	connected   bool
+	event       SSEEvent
+	err         error
+	done        bool
+}
+
+// NewSSEStream returns a stream reading url with GET. ops are applied to every
+// connection; ctx, Accept and Last-Event-ID are set by the stream itself.
+func NewSSEStream(ctx context.Context, url string, opt SSEOptions, ops ...AgentOp) *SSEStream {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s := &SSEStream{url: url, opt: opt, ops: ops, lastEventID: opt.LastEventID, delay: opt.ReconnectDelay}
+	if s.delay <= 0 {
+		s.delay = defaultSSEReconnectDelay
+	}
+	if s.opt.MaxEventSize <= 0 {
+		s.opt.MaxEventSize = defaultSSEMaxEventSize
+	}
+	ctx, _, err := ensureTraceContext(ctx)
+	if err != nil {
+		s.err, s.done = err, true
+		s.ctx, s.cancel = ctx, func() {}
+		return s
+	}
+	if deadline, ok := authoritativeDeadline(ctx); ok {
+		s.ctx, s.cancel = contextWithAuthoritativeDeadline(ctx, deadline)
+	} else {
+		timeout := opt.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeoutQuota
+		}
+		s.ctx, s.cancel = contextWithAuthoritativeDeadline(ctx, time.Now().Add(timeout))
+	}
+	return s
+}
+
+// Next waits for the next event, reconnecting when the connection ends.
+// It returns false when the stream cannot continue; Err reports why.
+func (s *SSEStream) Next() bool {
+	for !s.done {
+		if s.resp == nil {
+			err := s.connect()
+			if err == nil {
+				continue
+			}
+			// Only a stream that was established once reconnects after a retryable failure.
+			if !s.connected || !datax.IsRetryableError(err) || !s.canReconnect() {
+				s.fail(err)
+				return false
+			}
+			if err := s.wait(); err != nil {
+				s.fail(err)
+				return false
+			}
+			continue
+		}
+		event, ok, err := s.readEvent()
+		if ok {
+			s.event = event
+			return true
+		}
+		s.closeResp()
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			s.fail(s.contextError(ctxErr))
+			return false
+		}
+		if !s.canReconnect() {
+			if err == nil {
+				s.done = true
+				return false
+			}
+			s.fail(fmt.Errorf("read event stream failed: %w", err))
+			return false
+		}
+		if err := s.wait(); err != nil {
+			s.fail(err)
+			return false
+		}
+	}
+	return false
+}
+
+// Event returns the event read by the last successful Next.
+func (s *SSEStream) Event() SSEEvent {
+	return s.event
+}
+
+// LastEventID returns the id that would be sent on the next reconnect.
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Err returns the error that stopped the stream, if any.
+func (s *SSEStream) Err() error {
+	return s.err
+}
+
+// Close stops the stream and releases the connection.
+func (s *SSEStream) Close() error {
+	s.done = true
+	s.closeResp()
+	s.cancel()
+	return nil
+}
+
+func (s *SSEStream) connect() error {
+	header := http.Header{"Accept": []string{"text/event-stream"}, "Cache-Control": []string{"no-cache"}}
+	if s.lastEventID != "" {
+		header.Set(HeaderLastEventID, s.lastEventID)
+	}
+	ops := append(append([]AgentOp(nil), s.ops...), Context(s.ctx), SetHeader(header))
+	resp, err := Get(s.url, ops...).DoStream()
+	if err != nil {
+		return err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		_ = resp.Body.Close()
+		return datax.NewValidationError(fmt.Sprintf("unexpected event stream content type %q", resp.Header.Get("Content-Type")), nil, nil)
+	}
+	s.connected = true
+	s.resp = resp
+	s.scanner = bufio.NewScanner(resp.Body)
+	s.scanner.Buffer(make([]byte, 0, 4096), s.opt.MaxEventSize)
+	s.scanner.Split(scanSSELines)
+	return nil
+}
+
+// readEvent follows the WHATWG event stream parsing rules. It returns ok=false
+// with a nil error when the server ends the stream cleanly.
+func (s *SSEStream) readEvent() (SSEEvent, bool, error) {
+	var (
+		data      strings.Builder
+		eventType string
+		hasData   bool
+	)
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return SSEEvent{ID: s.lastEventID, Event: eventType, Data: strings.TrimSuffix(data.String(), "\n")}, true, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.delay = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return SSEEvent{}, false, s.scanner.Err()
+}
+
+func (s *SSEStream) canReconnect() bool {
+	if s.opt.MaxReconnects < 0 {
+		return false
+	}
+	return s.opt.MaxReconnects == 0 || s.reconnects < s.opt.MaxReconnects
+}
+
+// wait sleeps for the reconnect delay unless it would outlive the timeout budget.
+func (s *SSEStream) wait() error {
+	s.reconnects++
+	if deadline, ok := s.ctx.Deadline(); ok && time.Until(deadline) <= s.delay {
+		return ErrTimeoutBudgetExhausted
+	}
+	timer := time.NewTimer(s.delay)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return s.contextError(s.ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (s *SSEStream) contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeoutBudgetExhausted
+	}
+	return err
+}
+
+func (s *SSEStream) fail(err error) {
+	s.done = true
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+func (s *SSEStream) closeResp() {
+	if s.resp != nil {
+		_ = s.resp.Body.Close()
+		s.resp = nil
+		s.scanner = nil
+	}
+}
+
+// scanSSELines splits on "\r\n", "\n" or a lone "\r" as the event stream format allows.
+func scanSSELines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// Need one more byte to tell "\r" from "\r\n".
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+var _ io.Closer = (*SSEStream)(nil)
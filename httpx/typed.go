@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"context"
+)
+
+// DoJSON executes agent and decodes the JSON response into a new T.
+// It works with agents created by Profile, which cannot have generic methods.
+func DoJSON[T any](agent *Agent) (T, error) {
+	var ret T
+	if err := agent.Ops(JSONResp(&ret)).Do(); err != nil {
+		var zero T
+		return zero, err
+	}
+	return ret, nil
+}
+
+// GetJSON sends a GET request and decodes the JSON response into T.
+// Pass RespWrapper to decode T from a wrapper's data field, or use GetData.
+func GetJSON[T any](ctx context.Context, url string, ops ...AgentOp) (T, error) {
+	return DoJSON[T](Get(url, withContext(ctx, ops)...))
+}
+
+// PostJSON sends reqBody as JSON with POST and decodes the JSON response into T.
+func PostJSON[T any](ctx context.Context, url string, reqBody any, ops ...AgentOp) (T, error) {
+	return DoJSON[T](Post(url, withContext(ctx, append([]AgentOp{JSONReq(reqBody)}, ops...))...))
+}
+
+// PutJSON sends reqBody as JSON with PUT and decodes the JSON response into T.
+func PutJSON[T any](ctx context.Context, url string, reqBody any, ops ...AgentOp) (T, error) {
+	return DoJSON[T](Put(url, withContext(ctx, append([]AgentOp{JSONReq(reqBody)}, ops...))...))
+}
+
+// PatchJSON sends reqBody as JSON with PATCH and decodes the JSON response into T.
+func PatchJSON[T any](ctx context.Context, url string, reqBody any, ops ...AgentOp) (T, error) {
+	return DoJSON[T](Patch(url, withContext(ctx, append([]AgentOp{JSONReq(reqBody)}, ops...))...))
+}
+
+// DeleteJSON sends a DELETE request and decodes the JSON response into T.
+func DeleteJSON[T any](ctx context.Context, url string, ops ...AgentOp) (T, error) {
+	return DoJSON[T](Delete(url, withContext(ctx, ops)...))
+}
+
+// GetData sends a GET request to an endpoint answering with the CommonWrapper
+// envelope and returns its data field as T. A non-zero application code returns
+// a *WrapperError carrying that code; to accept more codes pass
+// RespWrapper(NewCommonWrapper(allowCodes...)) in ops, which replaces the default.
+func GetData[T any](ctx context.Context, url string, ops ...AgentOp) (T, error) {
+	return GetJSON[T](ctx, url, append([]AgentOp{RespWrapper(NewCommonWrapper())}, ops...)...)
+}
+
+// PostData sends reqBody as JSON with POST to a CommonWrapper endpoint and
+// returns its data field as T. Application codes are checked as in GetData.
+func PostData[T any](ctx context.Context, url string, reqBody any, ops ...AgentOp) (T, error) {
+	return PostJSON[T](ctx, url, reqBody, append([]AgentOp{RespWrapper(NewCommonWrapper())}, ops...)...)
+}
+
+// withContext puts the context option first so an explicit Context op still wins.
+func withContext(ctx context.Context, ops []AgentOp) []AgentOp {
+	if ctx == nil {
+		return ops
+	}
+	return append([]AgentOp{Context(ctx)}, ops...)
+}
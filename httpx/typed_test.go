@@ -0,0 +1,55 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedJSONHelpers(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/plain":
+			if r.Method == http.MethodPost {
+				var in item
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+				_ = json.NewEncoder(w).Encode(item{Name: in.Name + "-created"})
+				return
+			}
+			_, _ = w.Write([]byte(`{"name":"plain"}`))
+		case "/wrapped":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"name":"wrapped"}}`))
+		case "/failed":
+			_, _ = w.Write([]byte(`{"code":1001,"msg":"denied"}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	got, err := GetJSON[item](ctx, server.URL+"/plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", got.Name)
+
+	got, err = PostJSON[item](ctx, server.URL+"/plain", item{Name: "x"})
+	require.NoError(t, err)
+	require.Equal(t, "x-created", got.Name)
+
+	names, err := GetData[map[string]string](ctx, server.URL+"/wrapped")
+	require.NoError(t, err)
+	require.Equal(t, "wrapped", names["name"])
+
+	got, err = GetData[item](ctx, server.URL+"/failed")
+	var wrapperErr *WrapperError
+	require.ErrorAs(t, err, &wrapperErr)
+	require.Empty(t, got.Name)
+
+	_, err = GetData[item](ctx, server.URL+"/failed", RespWrapper(NewCommonWrapper(1001)))
+	require.NoError(t, err, "a RespWrapper in ops replaces the default allow codes")
+}
@@ -32,6 +32,7 @@ func CtxCreateAudit(ctx context.Context, entity any) error {
 		}
 		tc.SetAppID(aId)
 	}
+	AuditRunContext(entity)
 
 	return CtxUpdateAudit(ctx, entity)
 }
@@ -49,11 +50,12 @@ func CtxUpdateAudit(ctx context.Context, entity any) error {
 	return nil
 }
 
-// CtxUpdateAuditAndEnv updates audit fields and keeps a hook for env sync.
+// CtxUpdateAuditAndEnv updates audit fields and records the current run context.
 func CtxUpdateAuditAndEnv(ctx context.Context, entity any) error {
 	if err := CtxUpdateAudit(ctx, entity); err != nil {
 		return err
 	}
+	AuditRunContext(entity)
 
 	return nil
 }
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+const (
+	// DeployEnvVar is the environment variable of the deploy env. It is the
+	// deploy env variable of config.NewOptions.
+	DeployEnvVar = "APP__ENV"
+	// DeployClusterVar is the environment variable of the deploy cluster.
+	DeployClusterVar = "APP__CLUSTER"
+)
+
+// DeployInfo identifies the environment and cluster a process is deployed to.
+type DeployInfo struct {
+	// Env is the deploy environment, e.g. dev or prod.
+	Env string `bson:"env,omitempty" json:"env,omitempty" gorm:"column:env;type:varchar(64)"`
+	// Cluster is the deploy cluster.
+	Cluster string `bson:"cluster,omitempty" json:"cluster,omitempty" gorm:"column:cluster;type:varchar(64)"`
+}
+
+// RunContext records the runtime that wrote an entity.
+type RunContext struct {
+	// Deploy is the deployment of the writer.
+	Deploy DeployInfo `bson:"deploy,omitempty" json:"deploy,omitempty" gorm:"embedded;embeddedPrefix:deploy_"`
+}
+
+// RunContextRecorder exposes the run context of an entity.
+type RunContextRecorder interface {
+	GetRunContext() RunContext
+	SetRunContext(rc RunContext)
+}
+
+var _ RunContextRecorder = (*RunContextAudit)(nil)
+
+// RunContextAudit stores the run context of the last writer. Repos scope reads
+// and writes by it according to RepoOpt.DeployIsolation.
+type RunContextAudit struct {
+	// RunContext is the recorded run context.
+	RunContext RunContext `bson:"run_context,omitempty" json:"run_context,omitempty" gorm:"embedded;embeddedPrefix:run_context_"`
+}
+
+// GetRunContext returns the recorded run context.
+func (c *RunContextAudit) GetRunContext() RunContext {
+	return c.RunContext
+}
+
+// SetRunContext sets the recorded run context.
+func (c *RunContextAudit) SetRunContext(rc RunContext) {
+	c.RunContext = rc
+}
+
+// DeployProvider returns the deployment of the current process.
+type DeployProvider func() DeployInfo
+
+var deployProvider atomic.Pointer[DeployProvider]
+
+// EnvDeployProvider reads the deployment from DeployEnvVar and DeployClusterVar.
+// It is the default provider.
+func EnvDeployProvider() DeployInfo {
+	return DeployInfo{
+		Env:     strings.TrimSpace(os.Getenv(DeployEnvVar)),
+		Cluster: strings.TrimSpace(os.Getenv(DeployClusterVar)),
+	}
+}
+
+// SetDeployProvider replaces the deployment provider. A nil provider restores
+// EnvDeployProvider.
+func SetDeployProvider(p DeployProvider) {
+	if p == nil {
+		deployProvider.Store(nil)
+		return
+	}
+	deployProvider.Store(&p)
+}
+
+// SetDeployInfo fixes the deployment, typically to values loaded from config.
+func SetDeployInfo(info DeployInfo) {
+	SetDeployProvider(func() DeployInfo { return info })
+}
+
+// CurrentDeploy returns the deployment of the current process.
+func CurrentDeploy() DeployInfo {
+	if p := deployProvider.Load(); p != nil {
+		return (*p)()
+	}
+	return EnvDeployProvider()
+}
+
+// AuditRunContext records the current deployment on entities that are
+// RunContextRecorder.
+func AuditRunContext(entity any) {
+	if rc, ok := entity.(RunContextRecorder); ok {
+		rc.SetRunContext(RunContext{Deploy: CurrentDeploy()})
+	}
+}
+
+// DeployIsolationCond returns the DeployInfo field, "env" or "cluster", and the
+// current value to filter by for di. The field is empty when di isolates nothing.
+func DeployIsolationCond(di DeployIsolation) (field string, value string, err error) {
+	deploy := CurrentDeploy()
+	switch di {
+	case DeployIsolationCluster:
+		field, value = "cluster", deploy.Cluster
+	case DeployIsolationEnv:
+		field, value = "env", deploy.Env
+	default:
+		return "", "", nil
+	}
+
+	if value == "" {
+		return "", "", datax.NewValidationError(fmt.Sprintf("there is no deploy %s", field), nil, nil)
+	}
+	return field, value, nil
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+)
+
+type runContextDoc struct {
+	CreateAudit
+	UpdateAudit
+	RunContextAudit
+}
+
+func TestCurrentDeploy(t *testing.T) {
+	t.Setenv(DeployEnvVar, " dev ")
+	t.Setenv(DeployClusterVar, "cluster-a")
+	t.Cleanup(func() { SetDeployProvider(nil) })
+
+	if got := CurrentDeploy(); got != (DeployInfo{Env: "dev", Cluster: "cluster-a"}) {
+		t.Fatalf("env deploy got %+v", got)
+	}
+
+	SetDeployInfo(DeployInfo{Env: "prod", Cluster: "cluster-b"})
+	if got := CurrentDeploy(); got != (DeployInfo{Env: "prod", Cluster: "cluster-b"}) {
+		t.Fatalf("config deploy got %+v", got)
+	}
+
+	SetDeployProvider(nil)
+	if got := CurrentDeploy(); got.Env != "dev" {
+		t.Fatalf("restored deploy got %+v", got)
+	}
+}
+
+func TestRunContextAudit(t *testing.T) {
+	t.Cleanup(func() { SetDeployProvider(nil) })
+	ctx := pass.CtxSetOperator(context.Background(), "vinci")
+
+	SetDeployInfo(DeployInfo{Env: "prod", Cluster: "cluster-a"})
+	doc := &runContextDoc{}
+	if err := CtxCreateAudit(ctx, doc); err != nil {
+		t.Fatalf("create audit: %v", err)
+	}
+	if doc.RunContext.Deploy.Cluster != "cluster-a" {
+		t.Fatalf("create should record run context, got %+v", doc.RunContext)
+	}
+
+	SetDeployInfo(DeployInfo{Env: "prod", Cluster: "cluster-b"})
+	if _, err := UpdateLockAndAudit(ctx, doc, nil); err != nil {
+		t.Fatalf("update audit: %v", err)
+	}
+	if doc.RunContext.Deploy.Cluster != "cluster-a" {
+		t.Fatalf("update at creating should keep run context, got %+v", doc.RunContext)
+	}
+	if _, err := UpdateLockAndAudit(ctx, doc, &RepoOpt{UpdateRunContext: UpdateRunContextAlways}); err != nil {
+		t.Fatalf("update audit: %v", err)
+	}
+	if doc.RunContext.Deploy.Cluster != "cluster-b" {
+		t.Fatalf("update always should record run context, got %+v", doc.RunContext)
+	}
+}
+
+func TestDeployIsolationCond(t *testing.T) {
+	t.Cleanup(func() { SetDeployProvider(nil) })
+	SetDeployInfo(DeployInfo{Env: "prod"})
+
+	if field, _, err := DeployIsolationCond(DeployIsolationNone); err != nil || field != "" {
+		t.Fatalf("none got field %q err %v", field, err)
+	}
+	if field, value, err := DeployIsolationCond(DeployIsolationEnv); err != nil || field != "env" || value != "prod" {
+		t.Fatalf("env got %q=%q err %v", field, value, err)
+	}
+	if _, _, err := DeployIsolationCond(DeployIsolationCluster); datax.CodeOf(err) != datax.ErrCodeValidate {
+		t.Fatalf("missing cluster should fail validation, got %v", err)
+	}
+}
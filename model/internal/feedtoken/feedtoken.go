@@ -0,0 +1,159 @@
+// Package feedtoken converts feed cursor values to and from page tokens. The
+// cursor field is named by its bson key, so Mongo and in-memory repos share it.
+package feedtoken
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+
+	"github.com/shiningrush/goext/gtx"
+)
+
+// Parse parses pageToken into the Go type of the bson field cursorField of T.
+func Parse[T any](cursorField string, pageToken string) (any, error) {
+	fieldType, ok := findFieldType(reflect.TypeOf(gtx.Zero[T]()), cursorField)
+	if !ok {
+		return nil, datax.NewValidationError(fmt.Sprintf("cursor field %s not found", cursorField), nil, nil)
+	}
+	return ParseByType(pageToken, fieldType)
+}
+
+// ParseByType parses pageToken into fieldType.
+func ParseByType(pageToken string, fieldType reflect.Type) (any, error) {
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	if fieldType == reflect.TypeOf(time.Time{}) {
+		v, err := time.Parse(time.RFC3339Nano, pageToken)
+		if err != nil {
+			return nil, datax.NewValidationError("parse time feed token failed", nil, err)
+		}
+		return v, nil
+	}
+	switch fieldType.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(pageToken).Convert(fieldType).Interface(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(pageToken, 10, fieldType.Bits())
+		if err != nil {
+			return nil, datax.NewValidationError("parse int feed token failed", nil, err)
+		}
+		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(pageToken, 10, fieldType.Bits())
+		if err != nil {
+			return nil, datax.NewValidationError("parse uint feed token failed", nil, err)
+		}
+		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(pageToken, fieldType.Bits())
+		if err != nil {
+			return nil, datax.NewValidationError("parse float feed token failed", nil, err)
+		}
+		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
+	default:
+		return nil, datax.NewValidationError(fmt.Sprintf("unsupported cursor field type %s", fieldType.String()), nil, nil)
+	}
+}
+
+// Format formats the bson field cursorField of row as a page token.
+func Format(row any, cursorField string) (string, error) {
+	value, ok := findFieldValue(reflect.ValueOf(row), cursorField)
+	if !ok {
+		return "", datax.NewValidationError(fmt.Sprintf("cursor field %s not found", cursorField), nil, nil)
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.Format(time.RFC3339Nano), nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func findFieldType(entityType reflect.Type, cursorField string) (reflect.Type, bool) {
+	for entityType.Kind() == reflect.Ptr {
+		entityType = entityType.Elem()
+	}
+	if entityType.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for i := 0; i < entityType.NumField(); i++ {
+		field := entityType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline, skip := parseFieldName(field)
+		if skip {
+			continue
+		}
+		if inline {
+			if fieldType, ok := findFieldType(field.Type, cursorField); ok {
+				return fieldType, true
+			}
+			continue
+		}
+		if name == cursorField {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+func findFieldValue(value reflect.Value, cursorField string) (any, bool) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil, false
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil, false
+	}
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, inline, skip := parseFieldName(field)
+		if skip {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if inline {
+			if v, ok := findFieldValue(fieldValue, cursorField); ok {
+				return v, true
+			}
+			continue
+		}
+		if name == cursorField {
+			return fieldValue.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func parseFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
+	name = strings.ToLower(field.Name)
+	if mongoTag, ok := field.Tag.Lookup("bson"); ok {
+		name = mongoTag
+	}
+	parts := strings.Split(name, ",")
+	name = parts[0]
+	if name == "-" {
+		return "", false, true
+	}
+	for _, part := range parts[1:] {
+		if part == "inline" {
+			inline = true
+			break
+		}
+	}
+	if name == "inline" {
+		inline = true
+	}
+	return name, inline, false
+}
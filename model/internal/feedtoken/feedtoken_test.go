@@ -0,0 +1,59 @@
+package feedtoken
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testRow struct {
+	model.Entity[string] `bson:"inline"`
+	Rank                 int       `bson:"rank"`
+	SeenAt               time.Time `bson:"seen_at"`
+	Skipped              int       `bson:"-"`
+}
+
+func TestParseByType(t *testing.T) {
+	stringToken, err := ParseByType("10", reflect.TypeOf(""))
+	require.NoError(t, err)
+	require.Equal(t, "10", stringToken)
+
+	intToken, err := ParseByType("10", reflect.TypeOf(0))
+	require.NoError(t, err)
+	require.Equal(t, 10, intToken)
+
+	snowflakeToken, err := ParseByType("623949464310157351", reflect.TypeOf(model.SnowflakeID("")))
+	require.NoError(t, err)
+	require.Equal(t, model.NewSnowflakeID(623949464310157351), snowflakeToken)
+
+	_, err = ParseByType("bad", reflect.TypeOf(0))
+	require.Error(t, err)
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	_, err = ParseByType("bad", reflect.TypeOf(time.Time{}))
+	require.Error(t, err)
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestParseAndFormatRoundTrip(t *testing.T) {
+	seenAt := time.Date(2024, 5, 1, 8, 30, 0, 123000000, time.UTC)
+	row := &testRow{Entity: model.Entity[string]{ID: "id_1"}, Rank: 7, SeenAt: seenAt}
+
+	for field, want := range map[string]any{"_id": "id_1", "rank": 7, "seen_at": seenAt} {
+		token, err := Format(row, field)
+		require.NoError(t, err)
+		value, err := Parse[*testRow](field, token)
+		require.NoError(t, err)
+		require.Equal(t, want, value, field)
+	}
+
+	_, err := Format(row, "-")
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+	_, err = Parse[*testRow]("missing_field", "1")
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+)
+
+// IsolationCond is one equality condition a repo adds to every query.
+type IsolationCond struct {
+	// Field is the entity field, e.g. "tenant_id", or the DeployInfo field
+	// when Deploy is true.
+	Field string
+	// Value is the value Field must equal.
+	Value string
+	// Deploy marks a DeployInfo field nested in the entity run context. Each
+	// backend renders its own key for it.
+	Deploy bool
+}
+
+// DataIsolationCond returns the field, "created_by", "tenant_id" or "app_id",
+// and the context value to filter entity by for di. The field is empty when
+// entity does not carry the isolated field or di isolates nothing.
+func DataIsolationCond(ctx context.Context, entity any, di DataIsolation) (field string, value string, err error) {
+	switch di {
+	case DataIsolationUser:
+		if _, ok := entity.(CreateAuditor); ok {
+			uid, ok := pass.CtxGetOperator(ctx)
+			if !ok {
+				return "", "", datax.NewValidationError("there is no user id in context", nil, nil)
+			}
+			return "created_by", uid, nil
+		}
+	case DataIsolationTenant:
+		if _, ok := entity.(TenantCarrier); ok {
+			tid, ok := pass.CtxGetTenantID(ctx)
+			if !ok {
+				return "", "", datax.NewValidationError("there is no tenant id in context", nil, nil)
+			}
+			return "tenant_id", tid, nil
+		}
+	case DataIsolationApp:
+		if _, ok := entity.(TenantCarrier); ok {
+			aid, ok := pass.CtxGetAppID(ctx)
+			if !ok {
+				return "", "", datax.NewValidationError("there is no app id in context", nil, nil)
+			}
+			return "app_id", aid, nil
+		}
+	}
+	return "", "", nil
+}
+
+// IsolationConds returns the data and deploy isolation conditions opt implies
+// for entity.
+func IsolationConds(ctx context.Context, entity any, opt *RepoOpt) ([]IsolationCond, error) {
+	var conds []IsolationCond
+
+	field, value, err := DataIsolationCond(ctx, entity, opt.DataIsolation)
+	if err != nil {
+		return nil, err
+	}
+	if field != "" {
+		conds = append(conds, IsolationCond{Field: field, Value: value})
+	}
+
+	if _, ok := entity.(RunContextRecorder); ok {
+		field, value, err := DeployIsolationCond(opt.DeployIsolation)
+		if err != nil {
+			return nil, err
+		}
+		if field != "" {
+			conds = append(conds, IsolationCond{Field: field, Value: value, Deploy: true})
+		}
+	}
+
+	return conds, nil
+}
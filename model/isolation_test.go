@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+func TestIsolationConds(t *testing.T) {
+	t.Cleanup(func() { SetDeployProvider(nil) })
+	SetDeployInfo(DeployInfo{Cluster: "cluster-a"})
+	ctx := GenUserInfoContext(NewByteReqInfo())
+
+	conds, err := IsolationConds(ctx, &runContextDoc{}, &RepoOpt{
+		DataIsolation:   DataIsolationUser,
+		DeployIsolation: DeployIsolationCluster,
+	})
+	if err != nil {
+		t.Fatalf("conds failed: %v", err)
+	}
+	want := []IsolationCond{
+		{Field: "created_by", Value: "vinci"},
+		{Field: "cluster", Value: "cluster-a", Deploy: true},
+	}
+	if len(conds) != len(want) || conds[0] != want[0] || conds[1] != want[1] {
+		t.Fatalf("conds got %+v", conds)
+	}
+
+	conds, err = IsolationConds(ctx, &runContextDoc{}, &RepoOpt{DataIsolation: DataIsolationTenant})
+	if err != nil || len(conds) != 0 {
+		t.Fatalf("entity without tenant should not be isolated, got %+v err %v", conds, err)
+	}
+
+	_, err = IsolationConds(context.Background(), &runContextDoc{}, &RepoOpt{DataIsolation: DataIsolationUser})
+	if datax.CodeOf(err) != datax.ErrCodeValidate {
+		t.Fatalf("missing user should fail validation, got %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+package memrepo
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dev-ofa/core-go/model/datax"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Documents are kept as decoded bson, so that field names, omitempty, inline
+// structs and the millisecond precision of time values behave as in MongoDB.
+
+func encodeDoc(v any) (bson.D, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal doc failed: %w", err)
+	}
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal doc failed: %w", err)
+	}
+	return doc, nil
+}
+
+func decodeDoc[T any](doc bson.D) (ret T, err error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return ret, fmt.Errorf("marshal doc failed: %w", err)
+	}
+	if err := bson.Unmarshal(raw, &ret); err != nil {
+		return ret, fmt.Errorf("decode doc failed: %w", err)
+	}
+	return ret, nil
+}
+
+// buildPatchPayload returns the non-zero fields of the struct v keyed by their
+// dotted bson path, like mongox.BuildPatchPayload.
+func buildPatchPayload(v any) bson.M {
+	return patchPayloadWithParent(reflect.ValueOf(v), "")
+}
+
+func patchPayloadWithParent(v reflect.Value, parent string) bson.M {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = v.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return bson.M{}
+	}
+
+	payload := bson.M{}
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() || v.Field(i).IsZero() {
+			continue
+		}
+
+		name := strings.ToLower(t.Field(i).Name)
+		if tag, ok := t.Field(i).Tag.Lookup("bson"); ok {
+			name = tag
+		}
+		name = strings.Split(name, ",")[0]
+		if name == "-" {
+			continue
+		}
+		if parent != "inline" && parent != "" {
+			name = parent + "." + name
+		}
+
+		if v.Field(i).Kind() == reflect.Struct {
+			for k, v := range patchPayloadWithParent(v.Field(i), name) {
+				payload[k] = v
+			}
+			continue
+		}
+		payload[name] = v.Field(i).Interface()
+	}
+	return payload
+}
+
+// normalizeFilter converts filter values to the types they have in stored documents.
+func normalizeFilter(filter bson.M) (bson.D, error) {
+	if len(filter) == 0 {
+		return bson.D{}, nil
+	}
+	return encodeDoc(filter)
+}
+
+func sameDoc(a, b bson.D) bool {
+	rawA, errA := bson.Marshal(a)
+	rawB, errB := bson.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(rawA, rawB)
+}
+
+func lookup(doc bson.D, path string) (any, bool) {
+	key, rest, nested := strings.Cut(path, ".")
+	for _, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return e.Value, true
+		}
+		sub, ok := e.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return lookup(sub, rest)
+	}
+	return nil, false
+}
+
+func setField(doc bson.D, path string, value any) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			doc[i].Value = value
+			return doc
+		}
+		sub, _ := e.Value.(bson.D)
+		doc[i].Value = setField(sub, rest, value)
+		return doc
+	}
+	if !nested {
+		return append(doc, bson.E{Key: key, Value: value})
+	}
+	return append(doc, bson.E{Key: key, Value: setField(bson.D{}, rest, value)})
+}
+
+func unsetField(doc bson.D, path string) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return append(doc[:i:i], doc[i+1:]...)
+		}
+		if sub, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetField(sub, rest)
+		}
+		return doc
+	}
+	return doc
+}
+
+// match reports whether doc matches a normalized filter. It supports field
+// equality, dotted paths, $and, $or, $nor and the operators $eq, $ne, $gt,
+// $gte, $lt, $lte, $in, $nin and $exists.
+func match(doc bson.D, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		switch e.Key {
+		case "$and", "$or", "$nor":
+			items, ok := e.Value.(bson.A)
+			if !ok {
+				return false, datax.NewValidationError(fmt.Sprintf("%s expects an array", e.Key), nil, nil)
+			}
+			matched := 0
+			for _, item := range items {
+				sub, ok := item.(bson.D)
+				if !ok {
+					return false, datax.NewValidationError(fmt.Sprintf("%s expects filter documents", e.Key), nil, nil)
+				}
+				ok, err := match(doc, sub)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					matched++
+				}
+			}
+			if (e.Key == "$and" && matched < len(items)) || (e.Key == "$or" && matched == 0) || (e.Key == "$nor" && matched > 0) {
+				return false, nil
+			}
+		default:
+			if strings.HasPrefix(e.Key, "$") {
+				return false, datax.NewValidationError(fmt.Sprintf("unsupported filter operator %s", e.Key), nil, nil)
+			}
+			actual, found := lookup(doc, e.Key)
+			ops, isOps := e.Value.(bson.D)
+			if !isOps || len(ops) == 0 || !strings.HasPrefix(ops[0].Key, "$") {
+				if !equals(actual, found, e.Value) {
+					return false, nil
+				}
+				continue
+			}
+			ok, err := matchOps(actual, found, ops)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func matchOps(actual any, found bool, ops bson.D) (bool, error) {
+	for _, op := range ops {
+		var ok bool
+		switch op.Key {
+		case "$eq":
+			ok = equals(actual, found, op.Value)
+		case "$ne":
+			ok = !equals(actual, found, op.Value)
+		case "$gt", "$gte", "$lt", "$lte":
+			c, comparable := compareValues(actual, op.Value)
+			ok = found && comparable && ((op.Key == "$gt" && c > 0) || (op.Key == "$gte" && c >= 0) ||
+				(op.Key == "$lt" && c < 0) || (op.Key == "$lte" && c <= 0))
+		case "$in", "$nin":
+			items, isArray := op.Value.(bson.A)
+			if !isArray {
+				return false, datax.NewValidationError(fmt.Sprintf("%s expects an array", op.Key), nil, nil)
+			}
+			for _, item := range items {
+				if equals(actual, found, item) {
+					ok = true
+					break
+				}
+			}
+			if op.Key == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = found == truthy(op.Value)
+		default:
+			return false, datax.NewValidationError(fmt.Sprintf("unsupported filter operator %s", op.Key), nil, nil)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func equals(actual any, found bool, want any) bool {
+	if want == nil {
+		return !found || actual == nil
+	}
+	if !found {
+		return false
+	}
+	if items, ok := actual.(bson.A); ok {
+		if _, wantArray := want.(bson.A); !wantArray {
+			for _, item := range items {
+				if equals(item, true, want) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if c, ok := compareValues(actual, want); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(actual, want)
+}
+
+func truthy(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	default:
+		c, ok := compareValues(v, int32(0))
+		return !ok || c != 0
+	}
+}
+
+// compareValues orders two bson values of the same kind. Numbers of different
+// widths compare by value.
+func compareValues(a, b any) (int, bool) {
+	if x, ok := toInt64(a); ok {
+		if y, ok := toInt64(b); ok {
+			return compareOrdered(x, y), true
+		}
+	}
+	if x, ok := toFloat64(a); ok {
+		if y, ok := toFloat64(b); ok {
+			return compareOrdered(x, y), true
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	case bson.DateTime:
+		if y, ok := b.(bson.DateTime); ok {
+			return compareOrdered(x, y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			if x == y {
+				return 0, true
+			}
+			if !x {
+				return -1, true
+			}
+			return 1, true
+		}
+	case bson.ObjectID:
+		if y, ok := b.(bson.ObjectID); ok {
+			return bytes.Compare(x[:], y[:]), true
+		}
+	}
+	return 0, false
+}
+
+func compareOrdered[V int64 | float64 | bson.DateTime](x, y V) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+type sortKey struct {
+	field        string
+	isDescending bool
+}
+
+// sortDocs sorts docs stably; missing fields sort first in ascending order.
+func sortDocs(docs []bson.D, keys []sortKey) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, key := range keys {
+			c := compareField(docs[i], docs[j], key.field)
+			if c == 0 {
+				continue
+			}
+			if key.isDescending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+func compareField(a, b bson.D, field string) int {
+	x, okX := lookup(a, field)
+	y, okY := lookup(b, field)
+	switch {
+	case !okX && !okY:
+		return 0
+	case !okX:
+		return -1
+	case !okY:
+		return 1
+	}
+	c, _ := compareValues(x, y)
+	return c
+}
@@ -0,0 +1,607 @@
+// Package memrepo provides an in-memory model.Repo with the semantics of
+// mongox.CollectionLib, so that service unit tests run without MongoDB. It
+// depends only on the bson package of the Mongo driver.
+package memrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/model/internal/feedtoken"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/shiningrush/goext/gtx"
+	"github.com/shiningrush/goext/timex"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var _ model.Repo[string, *model.Entity[string]] = (*Repo[string, *model.Entity[string]])(nil)
+
+// New creates an in-memory repository for entity type T. name plays the role
+// of the collection name in error resources.
+func New[P model.IDType, T model.EntityConstraint[P]](name string) *Repo[P, T] {
+	return &Repo[P, T]{
+		name:  name,
+		idKey: "_id",
+		opt:   &model.RepoOpt{},
+	}
+}
+
+// Repo is an in-memory repository with repo options. Filters use the bson
+// field names of T, like mongox.CollectionLib. It is safe for concurrent use.
+// TryFixSyncDelay is ignored since reads never lag behind writes.
+type Repo[P model.IDType, T model.EntityConstraint[P]] struct {
+	name string
+	opt  *model.RepoOpt
+
+	idKey string
+
+	mu   sync.RWMutex
+	docs []bson.D
+}
+
+// WithRepoOpt sets repo options on the repository.
+func (r *Repo[P, T]) WithRepoOpt(opt *model.RepoOpt) *Repo[P, T] {
+	r.opt = opt
+	return r
+}
+
+// GetMergedRepoOpt merges context repo options with local options.
+func (r *Repo[P, T]) GetMergedRepoOpt(ctx context.Context) *model.RepoOpt {
+	return model.CtxMergeRepoOpt(ctx, r.opt)
+}
+
+func (r *Repo[P, T]) injectIsolationCond(ctx context.Context, filter bson.M) (bson.M, error) {
+	conds, err := model.IsolationConds(ctx, any(gtx.Zero[T]()), r.GetMergedRepoOpt(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range conds {
+		key := cond.Field
+		if cond.Deploy {
+			key = "run_context.deploy." + key
+		}
+		filter[key] = cond.Value
+	}
+	return filter, nil
+}
+
+func (r *Repo[P, T]) injectSoftDeleteCond(ctx context.Context, filter bson.M) (bson.M, error) {
+	opt := r.GetMergedRepoOpt(ctx)
+	if opt.SoftDelete == model.SoftDeleteDisable {
+		return filter, nil
+	}
+
+	if _, ok := any(gtx.Zero[T]()).(model.DeleteAuditor); ok {
+		filter["deleted_at"] = bson.M{"$exists": false}
+	}
+	return filter, nil
+}
+
+func (r *Repo[P, T]) injectCond(ctx context.Context, filter bson.M) (bson.M, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	filter, err := r.injectIsolationCond(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.injectSoftDeleteCond(ctx, filter)
+}
+
+// Find queries documents by filter.
+func (r *Repo[P, T]) Find(ctx context.Context, filter bson.M) (ret []T, err error) {
+	filter, err = r.injectCond(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := r.find(filter)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDocs[T](docs)
+}
+
+// Count counts documents by filter with isolation and soft-delete rules.
+func (r *Repo[P, T]) Count(ctx context.Context, filter bson.M) (int64, error) {
+	filter, err := r.injectCond(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	docs, err := r.find(filter)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+// PageQueryInput wraps filter, pager, and sorter for paging queries.
+type PageQueryInput struct {
+	// Filter is the Mongo filter.
+	Filter bson.M
+	// Pager is the paging input.
+	Pager datax.PagerInfo
+	// Sort is the sorting input.
+	Sort datax.SortInfo
+}
+
+// FeedQueryInput wraps filter, pager, and cursor field for feed queries.
+type FeedQueryInput struct {
+	// Filter is the Mongo filter.
+	Filter bson.M
+	// Pager is the feed paging input. PageSize is used; PageNum is ignored.
+	Pager datax.PagerInfo
+	// CursorField is the single field used as feed cursor. Defaults to _id.
+	CursorField string
+	// IsDescending controls cursor sort direction.
+	IsDescending bool
+}
+
+// PageQuery performs a paged query with filter, sort, and paging input.
+func (r *Repo[P, T]) PageQuery(ctx context.Context, input *PageQueryInput) (ret *model.PagedResult[T], err error) {
+	input.Filter, err = r.injectCond(ctx, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := r.find(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	ret = &model.PagedResult[T]{TotalCount: len(docs)}
+
+	var keys []sortKey
+	if input.Sort != nil {
+		for _, v := range input.Sort.GetSortInfo() {
+			keys = append(keys, sortKey{field: v.Field, isDescending: v.IsDescending})
+		}
+	}
+	if len(keys) == 0 {
+		if _, ok := any(gtx.Zero[T]()).(model.CreateAuditor); ok {
+			keys = append(keys, sortKey{field: "created_at"})
+		}
+	}
+	sortDocs(docs, keys)
+
+	pSize, pNum := 0, 0
+	if input.Pager != nil {
+		pSize, pNum, _ = input.Pager.GetPageInfo()
+	}
+	if pSize > 0 {
+		docs = window(docs, pSize*max(pNum-1, 0), pSize)
+	}
+
+	ret.Rows, err = decodeDocs[T](docs)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FeedQuery performs a single-field cursor-based feed query without counting total rows.
+func (r *Repo[P, T]) FeedQuery(ctx context.Context, input *FeedQueryInput) (ret *model.FeedResult[T], err error) {
+	pageSize, _, pageToken := 0, 0, ""
+	if input.Pager != nil {
+		pageSize, _, pageToken = input.Pager.GetPageInfo()
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	cursorField := input.CursorField
+	if cursorField == "" || cursorField == "id" {
+		cursorField = r.idKey
+	}
+
+	input.Filter, err = r.injectCond(ctx, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if pageToken != "" {
+		value, err := feedtoken.Parse[T](cursorField, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("build feed cursor filter failed: %w", err)
+		}
+		op := "$gt"
+		if input.IsDescending {
+			op = "$lt"
+		}
+		input.Filter = bson.M{"$and": bson.A{input.Filter, bson.M{cursorField: bson.M{op: value}}}}
+	}
+
+	docs, err := r.find(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	sortDocs(docs, []sortKey{{field: cursorField, isDescending: input.IsDescending}})
+	rows, err := decodeDocs[T](window(docs, 0, pageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	ret = &model.FeedResult[T]{Rows: rows}
+	if len(rows) > pageSize {
+		ret.Rows = rows[:pageSize]
+		ret.NextPageToken, err = feedtoken.Format(ret.Rows[len(ret.Rows)-1], cursorField)
+		if err != nil {
+			return nil, fmt.Errorf("build next page token failed: %w", err)
+		}
+	}
+
+	return ret, nil
+}
+
+// Get fetches one document by id.
+func (r *Repo[P, T]) Get(ctx context.Context, id P) (ret T, err error) {
+	return r.GetByFilter(ctx, bson.M{r.idKey: id})
+}
+
+// GetByFilter fetches the first document matching filter.
+func (r *Repo[P, T]) GetByFilter(ctx context.Context, filter bson.M) (ret T, err error) {
+	filter, err = r.injectCond(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	docs, err := r.find(filter)
+	if err != nil {
+		return ret, err
+	}
+	if len(docs) == 0 {
+		return ret, datax.NewResourceNotFoundError(r.resourceByFilter(filter), nil)
+	}
+	return decodeDoc[T](docs[0])
+}
+
+// Create inserts a new document with audit fields applied.
+func (r *Repo[P, T]) Create(ctx context.Context, doc T) (T, error) {
+	if err := r.checkIfIDExisted(doc); err != nil {
+		return gtx.Zero[T](), err
+	}
+
+	if err := model.CtxCreateAudit(ctx, doc); err != nil {
+		return gtx.Zero[T](), fmt.Errorf("audit doc failed: %w", err)
+	}
+
+	encoded, err := encodeDoc(doc)
+	if err != nil {
+		return gtx.Zero[T](), err
+	}
+	if !r.insert(encoded) {
+		return gtx.Zero[T](), datax.NewResourceConflictError(r.resourceByDoc(doc), nil)
+	}
+
+	return doc, nil
+}
+
+func (r *Repo[P, T]) checkIfIDExisted(doc T) error {
+	if gtx.IsZero(doc.GetID()) {
+		return datax.NewValidationError("id can not be empty", nil, nil)
+	}
+	return nil
+}
+
+// Update replaces a document, using optimistic checks when configured.
+func (r *Repo[P, T]) Update(ctx context.Context, doc T) (ret T, err error) {
+	return r.commonReplace(ctx, doc, false)
+}
+
+// Upsert replaces or inserts a document.
+func (r *Repo[P, T]) Upsert(ctx context.Context, doc T) (ret T, err error) {
+	return r.commonReplace(ctx, doc, true)
+}
+
+func (r *Repo[P, T]) commonReplace(ctx context.Context, doc T, isUpsert bool) (ret T, err error) {
+	filter, err := r.auditAndBuildReplaceFilter(ctx, doc, isUpsert)
+	if err != nil {
+		return gtx.Zero[T](), err
+	}
+
+	encoded, err := encodeDoc(doc)
+	if err != nil {
+		return gtx.Zero[T](), err
+	}
+	matched, modified, upserted, err := r.replaceOne(filter, encoded, isUpsert)
+	if err != nil {
+		if errors.Is(err, errDuplicateKey) {
+			return gtx.Zero[T](), datax.NewResourceConflictError(r.resourceByDoc(doc), nil)
+		}
+		return gtx.Zero[T](), fmt.Errorf("replace doc failed: %w", err)
+	}
+	if isUpsert && upserted {
+		return doc, nil
+	}
+
+	if matched == 0 {
+		delete(filter, "updated_at")
+		docs, err := r.find(filter)
+		if err != nil {
+			return gtx.Zero[T](), fmt.Errorf("check id failed: %w", err)
+		}
+		if len(docs) > 0 {
+			return gtx.Zero[T](), datax.NewResourceError(datax.ErrCodeConflict, "data is modified by other", r.resourceByFilter(filter), nil)
+		}
+
+		return gtx.Zero[T](), datax.NewResourceNotFoundError(r.resourceByFilter(filter), nil)
+	}
+
+	if _, ok := filter["updated_at"]; ok && modified == 0 {
+		return gtx.Zero[T](), datax.NewResourceError(datax.ErrCodeConflict, "optimistic locking failed", r.resourceByFilter(filter), nil)
+	}
+
+	return doc, nil
+}
+
+func (r *Repo[P, T]) auditAndBuildReplaceFilter(ctx context.Context, doc T, isUpsert bool) (bson.M, error) {
+	if !isUpsert {
+		return r.baseUpdateOp(ctx, doc)
+	}
+
+	cr, ok := any(doc).(model.CreateAuditor)
+	if !ok {
+		return r.baseUpdateOp(ctx, doc)
+	}
+
+	if _, createTime := cr.GetCreatorInfo(); createTime.IsZero() {
+		if err := model.CtxCreateAudit(ctx, doc); err != nil {
+			return nil, err
+		}
+		return bson.M{r.idKey: doc.GetID()}, nil
+	}
+	return r.baseUpdateOp(ctx, doc)
+}
+
+func (r *Repo[P, T]) baseUpdateOp(ctx context.Context, doc T) (bson.M, error) {
+	ret, err := model.UpdateLockAndAudit(ctx, doc, r.GetMergedRepoOpt(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		r.idKey: doc.GetID(),
+	}
+	if ret.HasOriginalUpdate {
+		filter["updated_at"] = ret.OriginalUpdatedAt
+	}
+	return r.injectCond(ctx, filter)
+}
+
+// Patch updates non-zero fields on a document by id.
+func (r *Repo[P, T]) Patch(ctx context.Context, doc T) error {
+	filter, err := r.baseUpdateOp(ctx, doc)
+	if err != nil {
+		return err
+	}
+
+	return r.PatchRaw(ctx, &PatchRawInput{
+		Filter:         filter,
+		PatchPayload:   buildPatchPayload(doc),
+		SkipInjectCond: true,
+	})
+}
+
+// PatchRawInput defines raw patch parameters.
+type PatchRawInput struct {
+	// Filter is the Mongo filter for patch.
+	Filter bson.M
+	// PatchPayload is the update payload.
+	PatchPayload bson.M
+	// UnsetPayload is the update payload for $unset.
+	UnsetPayload bson.M
+	// IsMany controls UpdateMany vs UpdateOne.
+	IsMany bool
+	// SkipInjectCond skips isolation and soft delete injection.
+	SkipInjectCond bool
+}
+
+// PatchRaw applies a patch payload with optional filter injection.
+func (r *Repo[P, T]) PatchRaw(ctx context.Context, input *PatchRawInput) (err error) {
+	if !input.SkipInjectCond {
+		input.Filter, err = r.injectCond(ctx, input.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := any(gtx.Zero[T]()).(model.UpdateAuditor); ok {
+		u, hasUser := pass.CtxGetOperator(ctx)
+		if !hasUser {
+			return datax.NewValidationError("there is no user in context", nil, nil)
+		}
+		if input.PatchPayload == nil {
+			input.PatchPayload = bson.M{}
+		}
+		input.PatchPayload["updated_at"] = timex.Now()
+		input.PatchPayload["updated_by"] = u
+	}
+	if len(input.PatchPayload) == 0 && len(input.UnsetPayload) == 0 {
+		return nil
+	}
+
+	matched, modified, err := r.update(input.Filter, input.PatchPayload, input.UnsetPayload, input.IsMany)
+	if err != nil {
+		return fmt.Errorf("patch doc failed: %w", err)
+	}
+
+	if matched == 0 {
+		return datax.NewResourceNotFoundError(r.resourceByFilter(input.Filter), nil)
+	}
+
+	if _, ok := input.Filter["updated_at"]; ok && modified == 0 {
+		return datax.NewResourceError(datax.ErrCodeConflict, "optimistic locking failed", r.resourceByFilter(input.Filter), nil)
+	}
+
+	return nil
+}
+
+// Delete deletes a document or applies soft delete when enabled.
+func (r *Repo[P, T]) Delete(ctx context.Context, doc T) error {
+	hasDeleteAudit, err := model.CtxDeleteAudit(ctx, doc)
+	if err != nil {
+		return fmt.Errorf("audit doc failed: %w", err)
+	}
+
+	opt := r.GetMergedRepoOpt(ctx)
+	if hasDeleteAudit && opt.SoftDelete != model.SoftDeleteDisable {
+		_, err := r.Update(ctx, doc)
+		return err
+	}
+
+	filter, err := r.injectCond(ctx, bson.M{r.idKey: doc.GetID()})
+	if err != nil {
+		return err
+	}
+	deleted, err := r.delete(filter, false)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return datax.NewResourceNotFoundError(r.resourceByDoc(doc), nil)
+	}
+
+	return nil
+}
+
+// BatchCreate inserts documents in batch. Like MongoDB ordered inserts,
+// documents before a duplicate id stay inserted.
+func (r *Repo[P, T]) BatchCreate(ctx context.Context, docs []T) error {
+	encodedDocs := make([]bson.D, 0, len(docs))
+	for _, doc := range docs {
+		if err := r.checkIfIDExisted(doc); err != nil {
+			return err
+		}
+
+		if err := model.CtxCreateAudit(ctx, doc); err != nil {
+			return fmt.Errorf("audit doc[%+v] failed: %w", doc, err)
+		}
+		encoded, err := encodeDoc(doc)
+		if err != nil {
+			return err
+		}
+		encodedDocs = append(encodedDocs, encoded)
+	}
+
+	for _, encoded := range encodedDocs {
+		if !r.insert(encoded) {
+			return datax.NewResourceConflictError(r.resourceByDocs(docs), nil)
+		}
+	}
+
+	return nil
+}
+
+// BatchUpdate updates documents one by one.
+func (r *Repo[P, T]) BatchUpdate(ctx context.Context, docs []T) error {
+	for _, v := range docs {
+		if _, err := r.Update(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchDelete deletes documents by ids.
+func (r *Repo[P, T]) BatchDelete(ctx context.Context, docs []T) (int, error) {
+	var ids []P
+	for _, v := range docs {
+		ids = append(ids, v.GetID())
+	}
+
+	return r.BatchDeleteByIDs(ctx, ids)
+}
+
+// BatchDeleteByIDs deletes documents by id list.
+func (r *Repo[P, T]) BatchDeleteByIDs(ctx context.Context, ids []P) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	return r.BatchDeleteByFilter(ctx, bson.M{r.idKey: bson.M{"$in": ids}})
+}
+
+// BatchDeleteByFilter deletes documents by filter with isolation rules.
+func (r *Repo[P, T]) BatchDeleteByFilter(ctx context.Context, filter bson.M) (cnt int, err error) {
+	filter, err = r.injectCond(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	opt := r.GetMergedRepoOpt(ctx)
+	if _, ok := any(gtx.Zero[T]()).(model.DeleteAuditor); ok && opt.SoftDelete != model.SoftDeleteDisable {
+		u, ok := pass.CtxGetOperator(ctx)
+		if !ok {
+			return 0, datax.NewValidationError("there is no user", nil, nil)
+		}
+
+		matched, _, err := r.update(filter, bson.M{"deleted_at": timex.Now(), "deleted_by": u}, nil, true)
+		if err != nil {
+			return 0, fmt.Errorf("batch delete failed: %w", err)
+		}
+		if matched == 0 {
+			return 0, datax.NewResourceNotFoundError(r.resourceByFilter(filter), nil)
+		}
+
+		return matched, nil
+	}
+
+	deleted, err := r.delete(filter, true)
+	if err != nil {
+		return 0, fmt.Errorf("batch delete failed: %w", err)
+	}
+	if deleted == 0 {
+		return 0, datax.NewResourceNotFoundError(r.resourceByFilter(filter), nil)
+	}
+
+	return deleted, nil
+}
+
+func (r *Repo[P, T]) resourceByID(id any) string {
+	return fmt.Sprintf("%s/%v", r.name, id)
+}
+
+func (r *Repo[P, T]) resourceByDoc(doc T) string {
+	return r.resourceByID(doc.GetID())
+}
+
+func (r *Repo[P, T]) resourceByDocs(docs []T) string {
+	ids := make([]P, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.GetID())
+	}
+	return fmt.Sprintf("%s ids=%v", r.name, ids)
+}
+
+func (r *Repo[P, T]) resourceByFilter(filter bson.M) string {
+	if id, ok := filter[r.idKey]; ok {
+		return r.resourceByID(id)
+	}
+	return fmt.Sprintf("%s filter=%v", r.name, filter)
+}
+
+func decodeDocs[T any](docs []bson.D) ([]T, error) {
+	ret := make([]T, 0, len(docs))
+	for _, doc := range docs {
+		v, err := decodeDoc[T](doc)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+func window(docs []bson.D, skip int, limit int) []bson.D {
+	if skip >= len(docs) {
+		return nil
+	}
+	docs = docs[skip:]
+	if limit < len(docs) {
+		docs = docs[:limit]
+	}
+	return docs
+}
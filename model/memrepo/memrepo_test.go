@@ -0,0 +1,180 @@
+package memrepo
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/model/repotest"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		return repotest.Repos{
+			Repo:       New[string, *repotest.Entity]("entities"),
+			SoftRepo:   New[string, *repotest.SoftEntity]("soft_entities"),
+			DeployRepo: New[string, *repotest.DeployEntity]("deploy_entities"),
+		}
+	})
+}
+
+type item struct {
+	model.Entity[model.SnowflakeID] `bson:"inline"`
+	Name                            string   `bson:"name"`
+	Rank                            int      `bson:"rank"`
+	Tags                            []string `bson:"tags"`
+	Meta                            struct {
+		Color string `bson:"color"`
+	} `bson:"meta"`
+
+	model.DeleteAudit `bson:"inline"`
+}
+
+func seed(t *testing.T) *Repo[model.SnowflakeID, *item] {
+	t.Helper()
+	repo := New[model.SnowflakeID, *item]("items")
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+	for i := 1; i <= 5; i++ {
+		doc := &item{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(uint64(i))}, Name: "item-" + strconv.Itoa(i), Rank: 10 - i}
+		if i%2 == 0 {
+			doc.Tags = []string{"even"}
+			doc.Meta.Color = "red"
+		}
+		_, err := repo.Create(ctx, doc)
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func TestFind(t *testing.T) {
+	repo := seed(t)
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+
+	cases := []struct {
+		name   string
+		filter bson.M
+		ids    []uint64
+	}{
+		{"all", nil, []uint64{1, 2, 3, 4, 5}},
+		{"equal", bson.M{"name": "item-3"}, []uint64{3}},
+		{"array contains", bson.M{"tags": "even"}, []uint64{2, 4}},
+		{"dotted path", bson.M{"meta.color": "red"}, []uint64{2, 4}},
+		{"range", bson.M{"rank": bson.M{"$gte": 6, "$lt": 8}}, []uint64{3, 4}},
+		{"in ids", bson.M{"_id": bson.M{"$in": []model.SnowflakeID{model.NewSnowflakeID(1), model.NewSnowflakeID(5)}}}, []uint64{1, 5}},
+		{"nin", bson.M{"name": bson.M{"$nin": []string{"item-1", "item-2"}}}, []uint64{3, 4, 5}},
+		{"ne", bson.M{"meta.color": bson.M{"$ne": "red"}}, []uint64{1, 3, 5}},
+		{"or", bson.M{"$or": bson.A{bson.M{"name": "item-1"}, bson.M{"rank": 5}}}, []uint64{1, 5}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ret, err := repo.Find(ctx, c.filter)
+			require.NoError(t, err)
+			var ids []uint64
+			for _, v := range ret {
+				id, err := v.ID.Uint64()
+				require.NoError(t, err)
+				ids = append(ids, id)
+			}
+			require.Equal(t, c.ids, ids)
+		})
+	}
+
+	_, err := repo.Find(ctx, bson.M{"name": bson.M{"$regex": "item"}})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	cnt, err := repo.BatchDeleteByFilter(ctx, bson.M{"tags": "even"})
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	total, err := repo.Count(ctx, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	total, err = repo.Count(model.SetCtxSoftDelete(ctx, model.SoftDeleteDisable), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, total)
+}
+
+func TestPageQuery(t *testing.T) {
+	repo := seed(t)
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+
+	ret, err := repo.PageQuery(ctx, &PageQueryInput{Pager: &model.Pager{PageSize: 2, PageNum: 2}})
+	require.NoError(t, err)
+	require.Equal(t, 5, ret.TotalCount)
+	require.Equal(t, []string{"item-3", "item-4"}, names(ret.Rows))
+
+	ret, err = repo.PageQuery(ctx, &PageQueryInput{
+		Filter: bson.M{"rank": bson.M{"$gt": 5}},
+		Pager:  &model.Pager{},
+		Sort:   &datax.SortAble{OrderBy: "rank"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, ret.TotalCount)
+	require.Equal(t, []string{"item-4", "item-3", "item-2", "item-1"}, names(ret.Rows))
+
+	ret, err = repo.PageQuery(ctx, &PageQueryInput{
+		Pager: &model.Pager{PageSize: 2, PageNum: 3},
+		Sort:  &datax.SortAble{OrderBy: "meta.color desc, name desc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-1"}, names(ret.Rows))
+}
+
+func TestFeedQuery(t *testing.T) {
+	repo := seed(t)
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+
+	var seen []string
+	pager := &model.Pager{PageSize: 2}
+	for {
+		ret, err := repo.FeedQuery(ctx, &FeedQueryInput{Pager: pager})
+		require.NoError(t, err)
+		seen = append(seen, names(ret.Rows)...)
+		if ret.NextPageToken == "" {
+			break
+		}
+		pager.PageToken = ret.NextPageToken
+	}
+	require.Equal(t, []string{"item-1", "item-2", "item-3", "item-4", "item-5"}, seen)
+
+	ret, err := repo.FeedQuery(ctx, &FeedQueryInput{
+		Pager:        &model.Pager{PageSize: 2},
+		CursorField:  "rank",
+		IsDescending: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-1", "item-2"}, names(ret.Rows))
+	require.Equal(t, "8", ret.NextPageToken)
+	ret, err = repo.FeedQuery(ctx, &FeedQueryInput{
+		Pager:        &model.Pager{PageSize: 2, PageToken: ret.NextPageToken},
+		CursorField:  "rank",
+		IsDescending: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-3", "item-4"}, names(ret.Rows))
+
+	_, err = repo.FeedQuery(ctx, &FeedQueryInput{Pager: &model.Pager{PageToken: "bad"}, CursorField: "rank"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestWithRepoOpt(t *testing.T) {
+	repo := seed(t).WithRepoOpt(&model.RepoOpt{SoftDelete: model.SoftDeleteDisable})
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+
+	doc, err := repo.Get(ctx, model.NewSnowflakeID(1))
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(ctx, doc))
+	total, err := repo.Count(model.SetCtxSoftDelete(ctx, model.SoftDeleteDisable), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, total, "the repo option hard deletes")
+}
+
+func names(rows []*item) []string {
+	var ret []string
+	for _, v := range rows {
+		ret = append(ret, v.Name)
+	}
+	return ret
+}
@@ -0,0 +1,165 @@
+package memrepo
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var errDuplicateKey = errors.New("duplicate key")
+
+// find returns copies of the documents matching filter in insertion order.
+func (r *Repo[P, T]) find(filter bson.M) ([]bson.D, error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ret []bson.D
+	for _, doc := range r.docs {
+		ok, err := match(doc, normalized)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			cloned, err := encodeDoc(doc)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, cloned)
+		}
+	}
+	return ret, nil
+}
+
+// insert adds doc unless its id is taken.
+func (r *Repo[P, T]) insert(doc bson.D) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insertLocked(doc)
+}
+
+func (r *Repo[P, T]) insertLocked(doc bson.D) bool {
+	id, _ := lookup(doc, r.idKey)
+	for _, existed := range r.docs {
+		if existedID, _ := lookup(existed, r.idKey); equals(existedID, true, id) {
+			return false
+		}
+	}
+	r.docs = append(r.docs, doc)
+	return true
+}
+
+// replaceOne replaces the first document matching filter with doc, or inserts
+// doc when isUpsert is set and nothing matches.
+func (r *Repo[P, T]) replaceOne(filter bson.M, doc bson.D, isUpsert bool) (matched, modified int, upserted bool, err error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existed := range r.docs {
+		ok, err := match(existed, normalized)
+		if err != nil {
+			return 0, 0, false, err
+		}
+		if !ok {
+			continue
+		}
+		if !sameDoc(existed, doc) {
+			r.docs[i] = doc
+			modified = 1
+		}
+		return 1, modified, false, nil
+	}
+
+	if !isUpsert {
+		return 0, 0, false, nil
+	}
+	if !r.insertLocked(doc) {
+		return 0, 0, false, errDuplicateKey
+	}
+	return 0, 0, true, nil
+}
+
+// update applies $set and $unset payloads to the first or all documents matching filter.
+func (r *Repo[P, T]) update(filter bson.M, set bson.M, unset bson.M, isMany bool) (matched, modified int, err error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	setDoc, err := normalizeFilter(set)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existed := range r.docs {
+		ok, err := match(existed, normalized)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !ok {
+			continue
+		}
+		matched++
+
+		updated, err := encodeDoc(existed)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, e := range setDoc {
+			updated = setField(updated, e.Key, e.Value)
+		}
+		for key := range unset {
+			updated = unsetField(updated, key)
+		}
+		if !sameDoc(existed, updated) {
+			r.docs[i] = updated
+			modified++
+		}
+		if !isMany {
+			break
+		}
+	}
+	return matched, modified, nil
+}
+
+// delete removes the first or all documents matching filter.
+func (r *Repo[P, T]) delete(filter bson.M, isMany bool) (int, error) {
+	normalized, err := normalizeFilter(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := make([]bool, len(r.docs))
+	deleted := 0
+	for i, doc := range r.docs {
+		if !isMany && deleted > 0 {
+			break
+		}
+		ok, err := match(doc, normalized)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			removed[i] = true
+			deleted++
+		}
+	}
+	kept := make([]bson.D, 0, len(r.docs)-deleted)
+	for i, doc := range r.docs {
+		if !removed[i] {
+			kept = append(kept, doc)
+		}
+	}
+	r.docs = kept
+	return deleted, nil
+}
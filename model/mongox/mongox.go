@@ -5,13 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dev-ofa/core-go/model/datax"
 
 	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/internal/feedtoken"
 	"github.com/dev-ofa/core-go/pass"
 
 	"github.com/avast/retry-go"
@@ -20,6 +20,7 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 )
 
 // BuildPatchPayload builds a bson patch payload from a struct pointer.
@@ -114,20 +115,31 @@ func NewCollectionLib[P model.IDType, T model.EntityConstraint[P]](cls *mongo.Co
 	}
 
 	return &CollectionLib[P, T]{
-		cls:   cls,
-		idKey: "_id",
-		opt:   &model.RepoOpt{},
+		cls:        cls,
+		primaryCls: cls.Clone(options.Collection().SetReadPreference(readpref.Primary())),
+		idKey:      "_id",
+		opt:        &model.RepoOpt{},
 	}
 }
 
 // CollectionLib is a Mongo collection helper with repo options.
 type CollectionLib[P model.IDType, T model.EntityConstraint[P]] struct {
 	cls *mongo.Collection
-	opt *model.RepoOpt
+	// primaryCls reads from the primary, see model.FixedStrategyPrimary.
+	primaryCls *mongo.Collection
+	opt        *model.RepoOpt
 
 	idKey string
 }
 
+// readCls returns the collection reads should go through for ctx.
+func (l *CollectionLib[P, T]) readCls(ctx context.Context) *mongo.Collection {
+	if l.GetMergedRepoOpt(ctx).TryFixSyncDelay == model.FixedStrategyPrimary {
+		return l.primaryCls
+	}
+	return l.cls
+}
+
 // PageQueryInput wraps filter, pager, and sorter for paging queries.
 type PageQueryInput struct {
 	// Filter is the Mongo filter.
@@ -162,45 +174,17 @@ func (l *CollectionLib[P, T]) GetMergedRepoOpt(ctx context.Context) *model.RepoO
 }
 
 func (l *CollectionLib[P, T]) injectIsolationCond(ctx context.Context, filter bson.M) (bson.M, error) {
-	opt := l.GetMergedRepoOpt(ctx)
-
-	switch opt.DataIsolation {
-	case model.DataIsolationUser:
-		if _, ok := any(gtx.Zero[T]()).(model.CreateAuditor); ok {
-			uid, ok := pass.CtxGetOperator(ctx)
-			if !ok {
-				return nil, datax.NewValidationError("there is no user id in context", nil, nil)
-			}
-			filter["created_by"] = uid
-		}
-	case model.DataIsolationTenant:
-		if _, ok := any(gtx.Zero[T]()).(model.TenantCarrier); ok {
-			tid, ok := pass.CtxGetTenantID(ctx)
-			if !ok {
-				return nil, datax.NewValidationError("there is no tenant id in context", nil, nil)
-			}
-			filter["tenant_id"] = tid
-		}
-	case model.DataIsolationApp:
-		if _, ok := any(gtx.Zero[T]()).(model.TenantCarrier); ok {
-			tid, ok := pass.CtxGetAppID(ctx)
-			if !ok {
-				return nil, datax.NewValidationError("there is no app id in context", nil, nil)
-			}
-			filter["app_id"] = tid
+	conds, err := model.IsolationConds(ctx, any(gtx.Zero[T]()), l.GetMergedRepoOpt(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range conds {
+		key := cond.Field
+		if cond.Deploy {
+			key = "run_context.deploy." + key
 		}
+		filter[key] = cond.Value
 	}
-
-	// TODO: deploy isolation
-	//if _, ok := any(gtx.Zero[T]()).(runenv.Recorder); ok {
-	//	switch opt.DeployIsolation {
-	//	case model.DeployIsolationCluster:
-	//		filter["run_context.deploy.cluster"] = env.Cluster()
-	//	case model.DeployIsolationEnv:
-	//		filter["run_context.deploy.env"] = runenv.GetEnv()
-	//	}
-	//}
-
 	return filter, nil
 }
 
@@ -239,7 +223,7 @@ func (l *CollectionLib[P, T]) Find(ctx context.Context, filter bson.M, opts ...o
 		return nil, err
 	}
 
-	cur, err := l.cls.Find(ctx, filter, opts...)
+	cur, err := l.readCls(ctx).Find(ctx, filter, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("find mongo failed: %w", err)
 	}
@@ -257,7 +241,7 @@ func (l *CollectionLib[P, T]) Count(ctx context.Context, filter bson.M) (ret int
 	if err != nil {
 		return 0, err
 	}
-	return l.cls.CountDocuments(ctx, filter)
+	return l.readCls(ctx).CountDocuments(ctx, filter)
 }
 
 // PageQuery performs a paged query with filter, sort, and paging input.
@@ -267,7 +251,7 @@ func (l *CollectionLib[P, T]) PageQuery(ctx context.Context, input *PageQueryInp
 		return nil, err
 	}
 
-	count, err := l.cls.CountDocuments(ctx, input.Filter)
+	count, err := l.readCls(ctx).CountDocuments(ctx, input.Filter)
 	if err != nil {
 		return nil, fmt.Errorf("count document failed: %w", err)
 	}
@@ -279,7 +263,7 @@ func (l *CollectionLib[P, T]) PageQuery(ctx context.Context, input *PageQueryInp
 	l.modifyPageOpt(opt, input)
 	l.modifySortOpt(opt, input)
 
-	cur, err := l.cls.Find(ctx, input.Filter, opt)
+	cur, err := l.readCls(ctx).Find(ctx, input.Filter, opt)
 	if err != nil {
 		return nil, fmt.Errorf("find result failed: %w", err)
 	}
@@ -317,7 +301,7 @@ func (l *CollectionLib[P, T]) FeedQuery(ctx context.Context, input *FeedQueryInp
 	opt.SetLimit(int64(pageSize + 1))
 	opt.SetSort(bson.D{{Key: cursorField, Value: feedSortOrder(input.IsDescending)}})
 
-	cur, err := l.cls.Find(ctx, input.Filter, opt)
+	cur, err := l.readCls(ctx).Find(ctx, input.Filter, opt)
 	if err != nil {
 		return nil, fmt.Errorf("find feed result failed: %w", err)
 	}
@@ -355,7 +339,7 @@ func (l *CollectionLib[P, T]) buildFeedCursorFilter(cursorField string, pageToke
 	}
 	if cursorField == l.idKey {
 		var zero P
-		value, err := parseFeedTokenByType(pageToken, reflect.TypeOf(zero))
+		value, err := feedtoken.ParseByType(pageToken, reflect.TypeOf(zero))
 		if err != nil {
 			return nil, err
 		}
@@ -376,48 +360,7 @@ func feedSortOrder(isDescending bool) int {
 }
 
 func (l *CollectionLib[P, T]) parseFeedCursorToken(cursorField string, pageToken string) (any, error) {
-	fieldType, ok := findBSONFieldType(reflect.TypeOf(gtx.Zero[T]()), cursorField)
-	if !ok {
-		return nil, datax.NewValidationError(fmt.Sprintf("cursor field %s not found", cursorField), nil, nil)
-	}
-	return parseFeedTokenByType(pageToken, fieldType)
-}
-
-func parseFeedTokenByType(pageToken string, fieldType reflect.Type) (any, error) {
-	for fieldType.Kind() == reflect.Ptr {
-		fieldType = fieldType.Elem()
-	}
-	if fieldType == reflect.TypeOf(time.Time{}) {
-		v, err := time.Parse(time.RFC3339Nano, pageToken)
-		if err != nil {
-			return nil, datax.NewValidationError("parse time feed token failed", nil, err)
-		}
-		return v, nil
-	}
-	switch fieldType.Kind() {
-	case reflect.String:
-		return reflect.ValueOf(pageToken).Convert(fieldType).Interface(), nil
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		v, err := strconv.ParseInt(pageToken, 10, fieldType.Bits())
-		if err != nil {
-			return nil, datax.NewValidationError("parse int feed token failed", nil, err)
-		}
-		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		v, err := strconv.ParseUint(pageToken, 10, fieldType.Bits())
-		if err != nil {
-			return nil, datax.NewValidationError("parse uint feed token failed", nil, err)
-		}
-		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
-	case reflect.Float32, reflect.Float64:
-		v, err := strconv.ParseFloat(pageToken, fieldType.Bits())
-		if err != nil {
-			return nil, datax.NewValidationError("parse float feed token failed", nil, err)
-		}
-		return reflect.ValueOf(v).Convert(fieldType).Interface(), nil
-	default:
-		return nil, datax.NewValidationError(fmt.Sprintf("unsupported cursor field type %s", fieldType.String()), nil, nil)
-	}
+	return feedtoken.Parse[T](cursorField, pageToken)
 }
 
 func (l *CollectionLib[P, T]) feedCursorToken(row T, cursorField string) (string, error) {
@@ -425,99 +368,7 @@ func (l *CollectionLib[P, T]) feedCursorToken(row T, cursorField string) (string
 		return fmt.Sprint(row.GetID()), nil
 	}
 
-	value, ok := findBSONFieldValue(reflect.ValueOf(row), cursorField)
-	if !ok {
-		return "", datax.NewValidationError(fmt.Sprintf("cursor field %s not found", cursorField), nil, nil)
-	}
-	if t, ok := value.(time.Time); ok {
-		return t.Format(time.RFC3339Nano), nil
-	}
-	return fmt.Sprint(value), nil
-}
-
-func findBSONFieldType(entityType reflect.Type, cursorField string) (reflect.Type, bool) {
-	for entityType.Kind() == reflect.Ptr {
-		entityType = entityType.Elem()
-	}
-	if entityType.Kind() != reflect.Struct {
-		return nil, false
-	}
-	for i := 0; i < entityType.NumField(); i++ {
-		field := entityType.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-		name, inline, skip := parseBSONFieldName(field)
-		if skip {
-			continue
-		}
-		if inline {
-			if fieldType, ok := findBSONFieldType(field.Type, cursorField); ok {
-				return fieldType, true
-			}
-			continue
-		}
-		if name == cursorField {
-			return field.Type, true
-		}
-	}
-	return nil, false
-}
-
-func findBSONFieldValue(value reflect.Value, cursorField string) (any, bool) {
-	for value.Kind() == reflect.Ptr {
-		if value.IsNil() {
-			return nil, false
-		}
-		value = value.Elem()
-	}
-	if value.Kind() != reflect.Struct {
-		return nil, false
-	}
-	valueType := value.Type()
-	for i := 0; i < valueType.NumField(); i++ {
-		field := valueType.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-		name, inline, skip := parseBSONFieldName(field)
-		if skip {
-			continue
-		}
-		fieldValue := value.Field(i)
-		if inline {
-			if v, ok := findBSONFieldValue(fieldValue, cursorField); ok {
-				return v, true
-			}
-			continue
-		}
-		if name == cursorField {
-			return fieldValue.Interface(), true
-		}
-	}
-	return nil, false
-}
-
-func parseBSONFieldName(field reflect.StructField) (name string, inline bool, skip bool) {
-	name = strings.ToLower(field.Name)
-	if mongoTag, ok := field.Tag.Lookup("bson"); ok {
-		name = mongoTag
-	}
-	parts := strings.Split(name, ",")
-	name = parts[0]
-	if name == "-" {
-		return "", false, true
-	}
-	for _, part := range parts[1:] {
-		if part == "inline" {
-			inline = true
-			break
-		}
-	}
-	if name == "inline" {
-		inline = true
-	}
-	return name, inline, false
+	return feedtoken.Format(row, cursorField)
 }
 
 func mergeFeedFilter(base bson.M, cursor bson.M) bson.M {
@@ -572,6 +423,8 @@ func (l *CollectionLib[P, T]) Get(ctx context.Context, id P) (ret T, err error)
 }
 
 // GetByFilter fetches one document by filter with optional retry strategy.
+// FixedStrategyPrimary reads once from the primary; any strategy other than
+// none or primary retries not-found results with backoff.
 func (l *CollectionLib[P, T]) GetByFilter(ctx context.Context, filter bson.M) (ret T, err error) {
 	filter, err = l.injectCond(ctx, filter)
 	if err != nil {
@@ -580,8 +433,9 @@ func (l *CollectionLib[P, T]) GetByFilter(ctx context.Context, filter bson.M) (r
 	resource := l.resourceByFilter(filter)
 
 	opt := l.GetMergedRepoOpt(ctx)
-	if opt.TryFixSyncDelay == model.FixedStrategyNone || opt.TryFixSyncDelay == "" {
-		if err := l.cls.FindOne(ctx, filter).Decode(&ret); err != nil {
+	switch opt.TryFixSyncDelay {
+	case model.FixedStrategyNone, "", model.FixedStrategyPrimary:
+		if err := l.readCls(ctx).FindOne(ctx, filter).Decode(&ret); err != nil {
 			if errors.Is(err, mongo.ErrNoDocuments) {
 				return ret, datax.NewResourceNotFoundError(resource, nil)
 			}
@@ -592,8 +446,9 @@ func (l *CollectionLib[P, T]) GetByFilter(ctx context.Context, filter bson.M) (r
 		return
 	}
 
-	// FixedStrategyBackoff retries not-found results twice with backoff to reduce
-	// inconsistencies caused by replica lag.
+	// FixedStrategyBackoff, and any strategy this version does not know, retries
+	// not-found results twice with backoff to reduce inconsistencies caused by
+	// replica lag.
 	err = retry.Do(func() error {
 		if err := l.cls.FindOne(ctx, filter).Decode(&ret); err != nil {
 			if errors.Is(err, mongo.ErrNoDocuments) {
@@ -672,7 +527,9 @@ func (l *CollectionLib[P, T]) commonReplace(ctx context.Context, doc T, isUpsert
 
 	if rpRet.MatchedCount == 0 {
 		delete(filter, "updated_at")
-		cnt, err := l.cls.CountDocuments(ctx, filter)
+		// The write just missed; a lagging secondary must not turn a lock
+		// conflict into not found.
+		cnt, err := l.primaryCls.CountDocuments(ctx, filter)
 		if err != nil {
 			return gtx.Zero[T](), fmt.Errorf("check id failed: %w", err)
 		}
@@ -847,6 +704,7 @@ func (l *CollectionLib[P, T]) Delete(ctx context.Context, doc T) (err error) {
 }
 
 // BatchCreate inserts documents in batch without transactional guarantees.
+// Run it inside WithTransaction to make it atomic.
 func (l *CollectionLib[P, T]) BatchCreate(ctx context.Context, docs []T) error {
 	var mongoDocs []interface{}
 	for _, doc := range docs {
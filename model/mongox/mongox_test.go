@@ -2,8 +2,8 @@ package mongox
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -11,6 +11,7 @@ import (
 	"github.com/dev-ofa/core-go/model/datax"
 
 	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/repotest"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -343,30 +344,10 @@ func TestFeedCursorHelpers(t *testing.T) {
 	require.Equal(t, "42", token)
 }
 
-func TestParseFeedTokenByType(t *testing.T) {
+func TestParseFeedCursorToken(t *testing.T) {
 	lib := &CollectionLib[string, *testEntity]{idKey: "_id"}
 
-	stringToken, err := parseFeedTokenByType("10", reflect.TypeOf(""))
-	require.NoError(t, err)
-	require.Equal(t, "10", stringToken)
-
-	intToken, err := parseFeedTokenByType("10", reflect.TypeOf(0))
-	require.NoError(t, err)
-	require.Equal(t, 10, intToken)
-
-	snowflakeToken, err := parseFeedTokenByType("623949464310157351", reflect.TypeOf(model.SnowflakeID("")))
-	require.NoError(t, err)
-	require.Equal(t, model.NewSnowflakeID(623949464310157351), snowflakeToken)
-
-	_, err = parseFeedTokenByType("bad", reflect.TypeOf(0))
-	require.Error(t, err)
-	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
-
-	_, err = parseFeedTokenByType("bad", reflect.TypeOf(time.Time{}))
-	require.Error(t, err)
-	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
-
-	_, err = lib.parseFeedCursorToken("missing_field", "bad")
+	_, err := lib.parseFeedCursorToken("missing_field", "bad")
 	require.Error(t, err)
 	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
 }
@@ -421,23 +402,128 @@ func (ct *CollectionLibTests) TestDataIsolation() {
 	_, _ = lib.BatchDeleteByIDs(cx, []string{"100", "101", "102"})
 }
 
-// TODO: waiting implement
-//func (ct *CollectionLibTests) TestDeployIsolation() {
-//	lib := ct.lib
-//	cx := ct.ctx
-//
-//	cx = model.SetCtxRepoDeployIsolation(cx, model.DeployIsolationCluster)
-//	ret, err := lib.Find(cx, bson.M{})
-//	ct.Require().NoError(err)
-//	ct.Require().Equal(0, len(ret))
-//
-//	_, err = lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "200"}})
-//	ct.Require().NoError(err)
-//	ret, err = lib.Find(cx, bson.M{})
-//	ct.Require().NoError(err)
-//	ct.Require().Equal(1, len(ret))
-//	_, _ = lib.BatchDeleteByIDs(cx, []string{"200"})
-//}
+func (ct *CollectionLibTests) TestReadYourWrites() {
+	lib := ct.lib
+	cx, end, err := WithCausalSession(ct.ctx, lib.cls.Database().Client())
+	ct.Require().NoError(err)
+	defer end()
+
+	nested, nestedEnd, err := WithCausalSession(cx, lib.cls.Database().Client())
+	ct.Require().NoError(err)
+	nestedEnd()
+	ct.Equal(mongo.SessionFromContext(cx), mongo.SessionFromContext(nested), "nested calls join the outer session")
+
+	_, err = lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "300"}, StrField: "causal"})
+	ct.Require().NoError(err)
+	defer func() { _, _ = lib.BatchDeleteByIDs(ct.ctx, []string{"300"}) }()
+
+	got, err := lib.Get(cx, "300")
+	ct.Require().NoError(err)
+	ct.Equal("causal", got.StrField)
+
+	primary := model.SetCtxFixedStrategy(ct.ctx, model.FixedStrategyPrimary)
+	ct.Same(lib.primaryCls, lib.readCls(primary))
+	ct.Same(lib.cls, lib.readCls(ct.ctx))
+	got, err = lib.Get(primary, "300")
+	ct.Require().NoError(err)
+	ct.Equal("causal", got.StrField)
+	cnt, err := lib.Count(primary, bson.M{"str_field": "causal"})
+	ct.Require().NoError(err)
+	ct.EqualValues(1, cnt)
+
+	// Unknown strategies keep the backoff path instead of silently dropping it.
+	unknown := model.SetCtxFixedStrategy(ct.ctx, "legacy")
+	got, err = lib.Get(unknown, "300")
+	ct.Require().NoError(err)
+	ct.Equal("causal", got.StrField)
+	_, err = lib.Get(unknown, "missing")
+	ct.True(datax.IsErrCode(datax.ErrCodeNotFound, err))
+}
+
+func (ct *CollectionLibTests) TestWithTransaction() {
+	lib := ct.lib
+	cli := lib.cls.Database().Client()
+	defer func() { _, _ = lib.BatchDeleteByIDs(ct.ctx, []string{"400", "401", "402"}) }()
+
+	err := WithTransaction(ct.ctx, cli, func(cx context.Context) error {
+		ct.True(InTransaction(cx))
+		if _, err := lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "400"}}); err != nil {
+			return err
+		}
+		// Nested calls join the outer transaction.
+		return WithTransaction(cx, cli, func(cx context.Context) error {
+			return lib.BatchCreate(cx, []*testEntity{{Entity: model.Entity[string]{ID: "401"}}})
+		})
+	})
+	ct.Require().NoError(err)
+	cnt, err := lib.Count(ct.ctx, bson.M{"_id": bson.M{"$in": []string{"400", "401"}}})
+	ct.Require().NoError(err)
+	ct.EqualValues(2, cnt)
+
+	err = WithTransaction(ct.ctx, cli, func(cx context.Context) error {
+		if _, err := lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "402"}}); err != nil {
+			return err
+		}
+		_, err := lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "400"}})
+		return err
+	})
+	ct.Require().Equal(datax.ErrCodeConflict, datax.CodeOf(err))
+	_, err = lib.Get(ct.ctx, "402")
+	ct.Require().Equal(datax.ErrCodeNotFound, datax.CodeOf(err), "the transaction is rolled back")
+
+	attempts := 0
+	err = WithTransaction(ct.ctx, cli, func(cx context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return fmt.Errorf("update doc failed: %w", mongo.CommandError{Code: 112, Labels: []string{labelTransientTransaction}})
+		}
+		_, err := lib.Create(cx, &testEntity{Entity: model.Entity[string]{ID: "402"}})
+		return err
+	})
+	ct.Require().NoError(err)
+	ct.Equal(2, attempts)
+}
+
+func (ct *CollectionLibTests) TestConformance() {
+	db := ct.lib.cls.Database()
+	var n int
+	repotest.Run(ct.T(), func(t *testing.T) repotest.Repos {
+		n++
+		cls := db.Collection(fmt.Sprintf("repotest_%d", n))
+		softCls := db.Collection(fmt.Sprintf("repotest_soft_%d", n))
+		deployCls := db.Collection(fmt.Sprintf("repotest_deploy_%d", n))
+		drop := func() {
+			_ = cls.Drop(context.Background())
+			_ = softCls.Drop(context.Background())
+			_ = deployCls.Drop(context.Background())
+		}
+		drop()
+		t.Cleanup(drop)
+		return repotest.Repos{
+			Repo:       NewCollectionLib[string, *repotest.Entity](cls),
+			SoftRepo:   NewCollectionLib[string, *repotest.SoftEntity](softCls),
+			DeployRepo: NewCollectionLib[string, *repotest.DeployEntity](deployCls),
+		}
+	})
+}
+
+func TestMapTransactionError(t *testing.T) {
+	writeConflict := mongo.CommandError{Code: errCodeWriteConflict, Name: "WriteConflict"}
+	transient := mongo.CommandError{Code: 251, Labels: []string{labelTransientTransaction}}
+	dup := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000}}}
+	notFound := datax.NewResourceNotFoundError("test_cls/1", nil)
+	other := errors.New("boom")
+
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(mapTransactionError(fmt.Errorf("update: %w", writeConflict))))
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(mapTransactionError(transient)))
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(mapTransactionError(dup)))
+	require.Same(t, notFound, mapTransactionError(notFound))
+	require.Same(t, other, mapTransactionError(other))
+
+	require.True(t, hasErrorLabel(fmt.Errorf("wrapped: %w", transient), labelTransientTransaction))
+	require.False(t, hasErrorLabel(other, labelTransientTransaction))
+	require.False(t, InTransaction(context.Background()))
+}
 
 func TestMongoEx(t *testing.T) {
 	suite.Run(t, new(CollectionLibTests))
@@ -0,0 +1,32 @@
+package mongox
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// WithCausalSession starts a causally consistent session and binds it to ctx.
+// CollectionLib calls made with the returned context run in the session, so a
+// read observes the writes made before it in the same context even when it is
+// served by a lagging secondary. The guarantee needs majority read and write
+// concerns. Reads still go to the primary only when the context opts in with
+// model.SetCtxFixedStrategy(ctx, model.FixedStrategyPrimary). Call end when the
+// flow is done.
+func WithCausalSession(ctx context.Context, client *mongo.Client) (sessCtx context.Context, end func(), err error) {
+	if sess := mongo.SessionFromContext(ctx); sess != nil {
+		// Joining the outer session keeps its causal chain.
+		return ctx, func() {}, nil
+	}
+
+	sess, err := client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return nil, nil, fmt.Errorf("start session failed: %w", err)
+	}
+
+	return mongo.NewSessionContext(ctx, sess), func() {
+		sess.EndSession(context.WithoutCancel(ctx))
+	}, nil
+}
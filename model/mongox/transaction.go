@@ -0,0 +1,123 @@
+package mongox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dev-ofa/core-go/model/datax"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	labelTransientTransaction = "TransientTransactionError"
+	labelUnknownCommitResult  = "UnknownTransactionCommitResult"
+
+	// errCodeWriteConflict is the server code of a write conflict between transactions.
+	errCodeWriteConflict = 112
+
+	// transactionRetryTimeout bounds retries like mongo.Session.WithTransaction does.
+	transactionRetryTimeout = 120 * time.Second
+)
+
+type txCtxKey struct{}
+
+// InTransaction reports whether ctx runs inside WithTransaction.
+func InTransaction(ctx context.Context) bool {
+	v, _ := ctx.Value(txCtxKey{}).(bool)
+	return v
+}
+
+// WithTransaction runs fn in a transaction. The session is stored in the
+// context passed to fn, so every CollectionLib call made with it joins the
+// transaction. fn may run several times: the whole transaction is retried on
+// transient transaction errors and the commit is retried on unknown commit
+// results. A nested call joins the outer transaction, and a session already in
+// ctx, e.g. from WithCausalSession, is reused.
+//
+// Write conflicts and duplicate keys that remain after retries are returned
+// as datax conflict errors; errors of fn that carry a datax code are returned
+// as is.
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error,
+	opts ...options.Lister[options.TransactionOptions]) error {
+	if InTransaction(ctx) {
+		return fn(ctx)
+	}
+
+	sess := mongo.SessionFromContext(ctx)
+	if sess == nil {
+		var err error
+		sess, err = client.StartSession()
+		if err != nil {
+			return fmt.Errorf("start session failed: %w", err)
+		}
+		defer sess.EndSession(context.WithoutCancel(ctx))
+	}
+	txCtx := context.WithValue(mongo.NewSessionContext(ctx, sess), txCtxKey{}, true)
+
+	deadline := time.Now().Add(transactionRetryTimeout)
+	for {
+		err := runTransaction(txCtx, sess, fn, deadline, opts)
+		if err == nil {
+			return nil
+		}
+		if hasErrorLabel(err, labelTransientTransaction) && ctx.Err() == nil && time.Now().Before(deadline) {
+			continue
+		}
+		return mapTransactionError(err)
+	}
+}
+
+func runTransaction(ctx context.Context, sess *mongo.Session, fn func(ctx context.Context) error,
+	deadline time.Time, opts []options.Lister[options.TransactionOptions]) error {
+	if err := sess.StartTransaction(opts...); err != nil {
+		return fmt.Errorf("start transaction failed: %w", err)
+	}
+
+	if err := fn(ctx); err != nil {
+		// Aborting must not be skipped because ctx is done.
+		_ = sess.AbortTransaction(context.WithoutCancel(ctx))
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		_ = sess.AbortTransaction(context.WithoutCancel(ctx))
+		return err
+	}
+
+	for {
+		err := sess.CommitTransaction(context.WithoutCancel(ctx))
+		if err == nil {
+			return nil
+		}
+
+		var cmdErr mongo.CommandError
+		if hasErrorLabel(err, labelUnknownCommitResult) && time.Now().Before(deadline) &&
+			!(errors.As(err, &cmdErr) && cmdErr.IsMaxTimeMSExpiredError()) {
+			continue
+		}
+		return fmt.Errorf("commit transaction failed: %w", err)
+	}
+}
+
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
+
+func mapTransactionError(err error) error {
+	var coded datax.CodedError
+	if errors.As(err, &coded) && coded.ErrorCode() != 0 {
+		return err
+	}
+
+	var srvErr mongo.ServerError
+	if mongo.IsDuplicateKeyError(err) ||
+		(errors.As(err, &srvErr) && srvErr.HasErrorCode(errCodeWriteConflict)) ||
+		hasErrorLabel(err, labelTransientTransaction) {
+		return datax.NewResourceError(datax.ErrCodeConflict, "transaction conflict", "transaction", err)
+	}
+	return err
+}
@@ -0,0 +1,433 @@
+// Package repotest provides a conformance suite for model.Repo implementations.
+//
+// The suite pins the semantics of mongox.CollectionLib, so that every backend
+// fills audit fields, isolates data, soft deletes and reports errors with the
+// same datax codes.
+package repotest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/stretchr/testify/require"
+)
+
+// Entity is the audited, tenant-scoped entity used by the suite.
+type Entity struct {
+	model.Entity[string] `bson:"inline"`
+	Name                 string `bson:"name" json:"name"`
+
+	model.UpdateAudit `bson:"inline"`
+	model.TenantAudit `bson:"inline"`
+}
+
+// SoftEntity is the soft-deletable entity used by the suite.
+type SoftEntity struct {
+	model.Entity[string] `bson:"inline"`
+	Name                 string `bson:"name" json:"name"`
+
+	model.DeleteAudit `bson:"inline"`
+}
+
+// DeployEntity records the run context of its writer and is used to test
+// deploy isolation.
+type DeployEntity struct {
+	model.Entity[string] `bson:"inline"`
+	Name                 string `bson:"name" json:"name"`
+
+	model.UpdateAudit     `bson:"inline"`
+	model.RunContextAudit `bson:"inline"`
+}
+
+// Repos holds the repositories under test. All must start empty and carry no
+// RepoOpt of their own; the suite sets options through the context.
+type Repos struct {
+	// Repo stores Entity.
+	Repo model.Repo[string, *Entity]
+	// SoftRepo stores SoftEntity.
+	SoftRepo model.Repo[string, *SoftEntity]
+	// DeployRepo stores DeployEntity.
+	DeployRepo model.Repo[string, *DeployEntity]
+}
+
+// Factory returns fresh repositories for one sub-test. Resources should be
+// released with t.Cleanup.
+type Factory func(t *testing.T) Repos
+
+// Run runs the conformance suite against the repositories built by factory.
+func Run(t *testing.T, factory Factory) {
+	t.Run("Audit", func(t *testing.T) { testAudit(t, factory(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, factory(t)) })
+	t.Run("UpdateUpsertPatch", func(t *testing.T) { testUpdateUpsertPatch(t, factory(t)) })
+	t.Run("OptimisticLock", func(t *testing.T) { testOptimisticLock(t, factory(t)) })
+	t.Run("SoftDelete", func(t *testing.T) { testSoftDelete(t, factory(t)) })
+	t.Run("DataIsolation", func(t *testing.T) { testDataIsolation(t, factory(t)) })
+	t.Run("DeployIsolation", func(t *testing.T) { testDeployIsolation(t, factory(t)) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, factory(t)) })
+	t.Run("SoftBatchDelete", func(t *testing.T) { testSoftBatchDelete(t, factory(t)) })
+}
+
+func userCtx(uid, tid, aid string) context.Context {
+	return model.GenUserInfoContext(&model.ByteReqInfo{UID: uid, TID: tid, AID: aid})
+}
+
+func newEntity(id string) *Entity {
+	return &Entity{Entity: model.Entity[string]{ID: id}, Name: "name-" + id}
+}
+
+func newSoftEntity(id string) *SoftEntity {
+	return &SoftEntity{Entity: model.Entity[string]{ID: id}, Name: "name-" + id}
+}
+
+// tick lets the clock move on, so that stores with millisecond precision see
+// a new updated_at on every write.
+func tick() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+func requireCode(t *testing.T, code int, err error) {
+	t.Helper()
+	require.Error(t, err)
+	require.Equal(t, code, datax.CodeOf(err), "unexpected error: %v", err)
+}
+
+func testAudit(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	created, err := repos.Repo.Create(ctx, newEntity("1"))
+	require.NoError(t, err)
+	require.Equal(t, "alice", created.CreatedBy)
+	require.Equal(t, "alice", created.UpdatedBy)
+	require.Equal(t, "tenant", created.TenantID)
+	require.Equal(t, "app", created.AppID)
+	require.False(t, created.CreatedAt.IsZero())
+	require.False(t, created.UpdatedAt.IsZero())
+
+	got, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "name-1", got.Name)
+	require.Equal(t, "alice", got.CreatedBy)
+	require.Equal(t, "alice", got.UpdatedBy)
+	require.Equal(t, "tenant", got.TenantID)
+	require.Equal(t, "app", got.AppID)
+	require.False(t, got.CreatedAt.IsZero())
+
+	tick()
+	_, err = repos.Repo.Update(userCtx("bob", "tenant", "app"), got)
+	require.NoError(t, err)
+	got, err = repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "alice", got.CreatedBy)
+	require.Equal(t, "bob", got.UpdatedBy)
+	require.True(t, got.UpdatedAt.After(got.CreatedAt))
+
+	_, err = repos.Repo.Create(ctx, newEntity("1"))
+	requireCode(t, datax.ErrCodeConflict, err)
+
+	_, err = repos.Repo.Create(ctx, newEntity(""))
+	requireCode(t, datax.ErrCodeValidate, err)
+
+	_, err = repos.Repo.Create(context.Background(), newEntity("2"))
+	requireCode(t, datax.ErrCodeValidate, err)
+	_, err = repos.Repo.Create(pass.CtxSetOperator(context.Background(), "alice"), newEntity("2"))
+	requireCode(t, datax.ErrCodeValidate, err)
+}
+
+func testNotFound(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	_, err := repos.Repo.Get(ctx, "missing")
+	requireCode(t, datax.ErrCodeNotFound, err)
+	_, err = repos.Repo.Update(ctx, newEntity("missing"))
+	requireCode(t, datax.ErrCodeNotFound, err)
+	requireCode(t, datax.ErrCodeNotFound, repos.Repo.Patch(ctx, newEntity("missing")))
+	requireCode(t, datax.ErrCodeNotFound, repos.Repo.Delete(ctx, newEntity("missing")))
+	requireCode(t, datax.ErrCodeNotFound, repos.SoftRepo.Delete(ctx, newSoftEntity("missing")))
+
+	created, err := repos.Repo.Create(ctx, newEntity("1"))
+	require.NoError(t, err)
+	require.NoError(t, repos.Repo.Delete(ctx, created))
+	_, err = repos.Repo.Get(ctx, "1")
+	requireCode(t, datax.ErrCodeNotFound, err)
+	requireCode(t, datax.ErrCodeNotFound, repos.Repo.Delete(ctx, created))
+}
+
+func testUpdateUpsertPatch(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	_, err := repos.Repo.Create(ctx, newEntity("1"))
+	require.NoError(t, err)
+	got, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+
+	// An unchanged document still updates, it gets a new updated_at.
+	tick()
+	_, err = repos.Repo.Update(ctx, got)
+	require.NoError(t, err)
+	got.Name = "updated"
+	tick()
+	_, err = repos.Repo.Update(ctx, got)
+	require.NoError(t, err)
+	got, err = repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "updated", got.Name)
+
+	tick()
+	require.NoError(t, repos.Repo.Patch(ctx, &Entity{Entity: model.Entity[string]{ID: "1"}, Name: "patched"}))
+	patched, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "patched", patched.Name)
+	require.Equal(t, "tenant", patched.TenantID, "patch keeps zero fields")
+	require.Equal(t, "alice", patched.CreatedBy)
+	require.True(t, patched.UpdatedAt.After(got.UpdatedAt))
+
+	upserted, err := repos.Repo.Upsert(ctx, newEntity("2"))
+	require.NoError(t, err)
+	require.Equal(t, "alice", upserted.CreatedBy)
+	require.Equal(t, "tenant", upserted.TenantID)
+	inserted, err := repos.Repo.Get(ctx, "2")
+	require.NoError(t, err)
+	require.Equal(t, "name-2", inserted.Name)
+
+	inserted.Name = "upserted"
+	tick()
+	_, err = repos.Repo.Upsert(userCtx("bob", "tenant", "app"), inserted)
+	require.NoError(t, err)
+	got, err = repos.Repo.Get(ctx, "2")
+	require.NoError(t, err)
+	require.Equal(t, "upserted", got.Name)
+	require.Equal(t, "alice", got.CreatedBy)
+	require.Equal(t, "bob", got.UpdatedBy)
+	require.True(t, got.CreatedAt.Equal(inserted.CreatedAt))
+}
+
+func testOptimisticLock(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	_, err := repos.Repo.Create(ctx, newEntity("1"))
+	require.NoError(t, err)
+	first, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	stale, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+
+	first.Name = "first"
+	tick()
+	_, err = repos.Repo.Update(ctx, first)
+	require.NoError(t, err)
+
+	stale.Name = "stale"
+	tick()
+	_, err = repos.Repo.Update(ctx, stale)
+	requireCode(t, datax.ErrCodeConflict, err)
+
+	got, err := repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "first", got.Name)
+
+	// A document without updated_at is not locked.
+	tick()
+	_, err = repos.Repo.Update(ctx, &Entity{Entity: model.Entity[string]{ID: "1"}, Name: "unlocked"})
+	require.NoError(t, err)
+	got, err = repos.Repo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "unlocked", got.Name)
+}
+
+func testSoftDelete(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	_, err := repos.SoftRepo.Create(ctx, newSoftEntity("1"))
+	require.NoError(t, err)
+	got, err := repos.SoftRepo.Get(ctx, "1")
+	require.NoError(t, err)
+	require.NoError(t, repos.SoftRepo.Delete(ctx, got))
+
+	_, err = repos.SoftRepo.Get(ctx, "1")
+	requireCode(t, datax.ErrCodeNotFound, err)
+	_, err = repos.SoftRepo.Update(ctx, newSoftEntity("1"))
+	requireCode(t, datax.ErrCodeNotFound, err)
+	requireCode(t, datax.ErrCodeNotFound, repos.SoftRepo.Delete(ctx, newSoftEntity("1")))
+	_, err = repos.SoftRepo.Create(ctx, newSoftEntity("1"))
+	requireCode(t, datax.ErrCodeConflict, err)
+
+	disabled := model.SetCtxSoftDelete(ctx, model.SoftDeleteDisable)
+	deleted, err := repos.SoftRepo.Get(disabled, "1")
+	require.NoError(t, err)
+	require.Equal(t, "alice", deleted.DeletedBy)
+	require.False(t, deleted.DeletedAt.IsZero())
+
+	require.NoError(t, repos.SoftRepo.Delete(disabled, deleted))
+	_, err = repos.SoftRepo.Get(disabled, "1")
+	requireCode(t, datax.ErrCodeNotFound, err)
+	_, err = repos.SoftRepo.Create(ctx, newSoftEntity("1"))
+	require.NoError(t, err, "hard deleted ids can be reused")
+}
+
+func testDataIsolation(t *testing.T, repos Repos) {
+	cases := []struct {
+		name      string
+		isolation model.DataIsolation
+		owner     context.Context
+		other     context.Context
+	}{
+		{"user", model.DataIsolationUser, userCtx("alice", "tenant", "app"), userCtx("bob", "tenant", "app")},
+		{"tenant", model.DataIsolationTenant, userCtx("alice", "tenant", "app"), userCtx("alice", "other", "app")},
+		{"app", model.DataIsolationApp, userCtx("alice", "tenant", "app"), userCtx("alice", "tenant", "other")},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			owner := model.SetCtxRepoDataIsolation(c.owner, c.isolation)
+			other := model.SetCtxRepoDataIsolation(c.other, c.isolation)
+			id := "isolated-" + c.name
+
+			_, err := repos.Repo.Create(owner, newEntity(id))
+			require.NoError(t, err)
+			got, err := repos.Repo.Get(owner, id)
+			require.NoError(t, err)
+
+			_, err = repos.Repo.Get(other, id)
+			requireCode(t, datax.ErrCodeNotFound, err)
+			tick()
+			_, err = repos.Repo.Update(other, got)
+			requireCode(t, datax.ErrCodeNotFound, err)
+			requireCode(t, datax.ErrCodeNotFound, repos.Repo.Patch(other, &Entity{Entity: model.Entity[string]{ID: id}, Name: "patched"}))
+			requireCode(t, datax.ErrCodeNotFound, repos.Repo.Delete(other, newEntity(id)))
+			cnt, err := repos.Repo.BatchDeleteByIDs(other, []string{id})
+			requireCode(t, datax.ErrCodeNotFound, err)
+			require.Zero(t, cnt)
+
+			_, err = repos.Repo.Get(model.SetCtxRepoDataIsolation(c.other, model.DataIsolationNone), id)
+			require.NoError(t, err, "isolation none sees every row")
+			got, err = repos.Repo.Get(owner, id)
+			require.NoError(t, err)
+			require.Equal(t, "name-"+id, got.Name)
+		})
+	}
+
+	noTenant := model.SetCtxRepoDataIsolation(pass.CtxSetOperator(context.Background(), "alice"), model.DataIsolationTenant)
+	_, err := repos.Repo.Get(noTenant, "isolated-tenant")
+	requireCode(t, datax.ErrCodeValidate, err)
+}
+
+func testDeployIsolation(t *testing.T, repos Repos) {
+	t.Cleanup(func() { model.SetDeployProvider(nil) })
+	ctx := userCtx("alice", "tenant", "app")
+	newDeployEntity := func(id string) *DeployEntity {
+		return &DeployEntity{Entity: model.Entity[string]{ID: id}, Name: "name-" + id}
+	}
+
+	model.SetDeployInfo(model.DeployInfo{Env: "prod", Cluster: "cluster-a"})
+	_, err := repos.DeployRepo.Create(ctx, newDeployEntity("a"))
+	require.NoError(t, err)
+
+	model.SetDeployInfo(model.DeployInfo{Env: "prod", Cluster: "cluster-b"})
+	clusterCtx := model.SetCtxRepoDeployIsolation(ctx, model.DeployIsolationCluster)
+	_, err = repos.DeployRepo.Get(clusterCtx, "a")
+	requireCode(t, datax.ErrCodeNotFound, err)
+
+	created, err := repos.DeployRepo.Create(clusterCtx, newDeployEntity("b"))
+	require.NoError(t, err)
+	require.Equal(t, model.DeployInfo{Env: "prod", Cluster: "cluster-b"}, created.RunContext.Deploy)
+	got, err := repos.DeployRepo.Get(clusterCtx, "b")
+	require.NoError(t, err)
+	require.Equal(t, "cluster-b", got.RunContext.Deploy.Cluster)
+
+	tick()
+	_, err = repos.DeployRepo.Update(clusterCtx, newDeployEntity("a"))
+	requireCode(t, datax.ErrCodeNotFound, err)
+	requireCode(t, datax.ErrCodeNotFound, repos.DeployRepo.Delete(clusterCtx, newDeployEntity("a")))
+
+	envCtx := model.SetCtxRepoDeployIsolation(ctx, model.DeployIsolationEnv)
+	for _, id := range []string{"a", "b"} {
+		_, err = repos.DeployRepo.Get(envCtx, id)
+		require.NoError(t, err, "both clusters share the env")
+	}
+
+	model.SetDeployInfo(model.DeployInfo{Env: "prod"})
+	_, err = repos.DeployRepo.Get(clusterCtx, "b")
+	requireCode(t, datax.ErrCodeValidate, err)
+}
+
+func testBatch(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+
+	cnt, err := repos.Repo.BatchDelete(ctx, nil)
+	require.NoError(t, err)
+	require.Zero(t, cnt)
+	cnt, err = repos.Repo.BatchDeleteByIDs(ctx, nil)
+	require.NoError(t, err)
+	require.Zero(t, cnt)
+
+	docs := []*Entity{newEntity("1"), newEntity("2"), newEntity("3")}
+	require.NoError(t, repos.Repo.BatchCreate(ctx, docs))
+	for _, doc := range docs {
+		require.Equal(t, "alice", doc.CreatedBy)
+		require.Equal(t, "tenant", doc.TenantID)
+		got, err := repos.Repo.Get(ctx, doc.ID)
+		require.NoError(t, err)
+		require.Equal(t, doc.Name, got.Name)
+	}
+
+	err = repos.Repo.BatchCreate(ctx, []*Entity{newEntity("4"), newEntity("")})
+	requireCode(t, datax.ErrCodeValidate, err)
+	err = repos.Repo.BatchCreate(ctx, []*Entity{newEntity("1")})
+	requireCode(t, datax.ErrCodeConflict, err)
+
+	var fetched []*Entity
+	for _, doc := range docs {
+		got, err := repos.Repo.Get(ctx, doc.ID)
+		require.NoError(t, err)
+		got.Name = "batch-" + got.ID
+		fetched = append(fetched, got)
+	}
+	tick()
+	require.NoError(t, repos.Repo.BatchUpdate(ctx, fetched))
+	for _, doc := range docs {
+		got, err := repos.Repo.Get(ctx, doc.ID)
+		require.NoError(t, err)
+		require.Equal(t, "batch-"+doc.ID, got.Name)
+	}
+	err = repos.Repo.BatchUpdate(ctx, []*Entity{newEntity("missing")})
+	requireCode(t, datax.ErrCodeNotFound, err)
+
+	cnt, err = repos.Repo.BatchDelete(ctx, []*Entity{newEntity("1")})
+	require.NoError(t, err)
+	require.Equal(t, 1, cnt)
+	cnt, err = repos.Repo.BatchDeleteByIDs(ctx, []string{"1", "2", "3", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	cnt, err = repos.Repo.BatchDeleteByIDs(ctx, []string{"2", "3"})
+	requireCode(t, datax.ErrCodeNotFound, err)
+	require.Zero(t, cnt)
+}
+
+func testSoftBatchDelete(t *testing.T, repos Repos) {
+	ctx := userCtx("alice", "tenant", "app")
+	docs := []*SoftEntity{newSoftEntity("1"), newSoftEntity("2")}
+	require.NoError(t, repos.SoftRepo.BatchCreate(ctx, docs))
+
+	cnt, err := repos.SoftRepo.BatchDelete(ctx, docs)
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	_, err = repos.SoftRepo.Get(ctx, "1")
+	requireCode(t, datax.ErrCodeNotFound, err)
+	cnt, err = repos.SoftRepo.BatchDelete(ctx, docs)
+	requireCode(t, datax.ErrCodeNotFound, err)
+	require.Zero(t, cnt)
+
+	disabled := model.SetCtxSoftDelete(ctx, model.SoftDeleteDisable)
+	deleted, err := repos.SoftRepo.Get(disabled, "2")
+	require.NoError(t, err)
+	require.Equal(t, "alice", deleted.DeletedBy)
+	cnt, err = repos.SoftRepo.BatchDeleteByIDs(disabled, []string{"1", "2"})
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	cnt, err = repos.SoftRepo.BatchDeleteByIDs(disabled, []string{"1", "2"})
+	requireCode(t, datax.ErrCodeNotFound, err)
+	require.Zero(t, cnt)
+}
@@ -0,0 +1,443 @@
+package sqlrepo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dev-ofa/core-go/model/datax"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// column maps one struct field to a table column.
+type column struct {
+	name  string
+	index []int
+	typ   reflect.Type
+	// isJSON stores slices, maps and plain structs as JSON text.
+	isJSON bool
+	// nullZero stores zero values as NULL, like omitempty in mongox, so that
+	// unset audit times such as deleted_at read as absent.
+	nullZero bool
+}
+
+// schema lists the columns of an entity type in field order.
+type schema struct {
+	columns []*column
+	byName  map[string]*column
+	id      *column
+}
+
+// parseSchema maps the fields of the struct type t to columns. Column names
+// follow gorm: the `column:` option or a bare name in the gorm tag, otherwise
+// the snake case of the field name. Anonymous structs and fields tagged
+// `gorm:"embedded"` are flattened; `gorm:"-"` skips a field.
+func parseSchema(t reflect.Type) (*schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("entity type %s is not a struct", t)
+	}
+
+	s := &schema{byName: map[string]*column{}}
+	var primary *column
+	if err := s.collect(t, nil, "", &primary); err != nil {
+		return nil, err
+	}
+	s.id = primary
+	if s.id == nil {
+		s.id = s.byName["id"]
+	}
+	if s.id == nil {
+		return nil, fmt.Errorf("entity type %s has no primary key column", t)
+	}
+	return s, nil
+}
+
+func (s *schema) collect(t reflect.Type, parent []int, prefix string, primary **column) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := parseGormTag(field.Tag.Get("gorm"))
+		if tag.skip {
+			continue
+		}
+		index := append(append([]int(nil), parent...), i)
+
+		isStruct := field.Type.Kind() == reflect.Struct && field.Type != timeType &&
+			!reflect.PointerTo(field.Type).Implements(scannerType)
+		if isStruct && (field.Anonymous || tag.embedded) {
+			if err := s.collect(field.Type, index, prefix+tag.embeddedPrefix, primary); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := tag.column
+		if name == "" {
+			name = toSnake(field.Name)
+		}
+		name = prefix + name
+		if _, ok := s.byName[name]; ok {
+			return fmt.Errorf("duplicate column %s in entity type %s", name, t)
+		}
+		col := &column{
+			name:     name,
+			index:    index,
+			typ:      field.Type,
+			isJSON:   isJSONKind(field.Type),
+			nullZero: field.Type == timeType || isAuditTime(name),
+		}
+		s.columns = append(s.columns, col)
+		s.byName[name] = col
+		if tag.primaryKey && *primary == nil {
+			*primary = col
+		}
+	}
+	return nil
+}
+
+func isAuditTime(name string) bool {
+	return name == "created_at" || name == "updated_at" || name == "deleted_at"
+}
+
+func isJSONKind(t reflect.Type) bool {
+	if t.Implements(valuerType) || reflect.PointerTo(t).Implements(scannerType) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		return isJSONKind(t.Elem())
+	}
+	switch t.Kind() {
+	case reflect.Map, reflect.Struct:
+		return t != timeType
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+type gormTag struct {
+	column         string
+	primaryKey     bool
+	embedded       bool
+	embeddedPrefix string
+	skip           bool
+}
+
+// gormFlags are bare gorm tag options that are not column names.
+var gormFlags = map[string]bool{
+	"primarykey":    true,
+	"embedded":      true,
+	"autoincrement": true,
+	"unique":        true,
+	"index":         true,
+	"uniqueindex":   true,
+	"not null":      true,
+}
+
+func parseGormTag(tag string) gormTag {
+	var ret gormTag
+	if tag == "-" {
+		ret.skip = true
+		return ret
+	}
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(part, ":")
+		switch lower := strings.ToLower(key); {
+		case hasValue && lower == "column":
+			ret.column = value
+		case hasValue && lower == "embeddedprefix":
+			ret.embeddedPrefix = value
+		case !hasValue && lower == "primarykey":
+			ret.primaryKey = true
+		case !hasValue && lower == "embedded":
+			ret.embedded = true
+		case !hasValue && lower == "-":
+			ret.skip = true
+		case !hasValue && !gormFlags[lower] && isIdentifier(key) && ret.column == "":
+			// The audit structs name their column as a bare tag option.
+			ret.column = key
+		}
+	}
+	return ret
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// toSnake converts a Go field name to snake case, keeping initialisms
+// together: TenantID becomes tenant_id and HTTPServer becomes http_server.
+func toSnake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// value returns the bind value of col in the struct v.
+func (c *column) value(v reflect.Value) (any, error) {
+	fv := v.FieldByIndex(c.index)
+	if (c.nullZero || fv.Kind() == reflect.Ptr) && fv.IsZero() {
+		return nil, nil
+	}
+	if c.isJSON {
+		data, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("marshal column %s failed: %w", c.name, err)
+		}
+		return string(data), nil
+	}
+	return normalizeArg(fv.Interface()), nil
+}
+
+// normalizeArg stores times in UTC with millisecond precision, matching
+// datetime(3) columns and the precision of mongox. Pointers bind their
+// element, and nil pointers bind NULL.
+func normalizeArg(v any) any {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		if _, ok := v.(driver.Valuer); !ok {
+			return normalizeArg(rv.Elem().Interface())
+		}
+	}
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Truncate(time.Millisecond)
+	}
+	return v
+}
+
+// scanner scans a column into a struct field. NULL scans to the zero value.
+type scanner struct {
+	col *column
+	dst reflect.Value
+}
+
+// Scan implements sql.Scanner.
+func (s scanner) Scan(src any) error {
+	if src == nil {
+		s.dst.Set(reflect.Zero(s.dst.Type()))
+		return nil
+	}
+	if sc, ok := s.dst.Addr().Interface().(sql.Scanner); ok {
+		return sc.Scan(src)
+	}
+	if s.col.isJSON {
+		var data []byte
+		switch v := src.(type) {
+		case string:
+			data = []byte(v)
+		case []byte:
+			data = v
+		default:
+			return fmt.Errorf("scan column %s: unexpected json source %T", s.col.name, src)
+		}
+		return json.Unmarshal(data, s.dst.Addr().Interface())
+	}
+	if s.dst.Kind() == reflect.Ptr {
+		elem := reflect.New(s.dst.Type().Elem())
+		if err := (scanner{col: s.col, dst: elem.Elem()}).Scan(src); err != nil {
+			return err
+		}
+		s.dst.Set(elem)
+		return nil
+	}
+	if err := assign(s.dst, src); err != nil {
+		return fmt.Errorf("scan column %s: %w", s.col.name, err)
+	}
+	return nil
+}
+
+func assign(dst reflect.Value, src any) error {
+	if dst.Type() == timeType {
+		t, err := asTime(src)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if b, ok := src.([]byte); ok {
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		src = string(b)
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		switch v := src.(type) {
+		case string:
+			dst.SetString(v)
+		case time.Time:
+			dst.SetString(v.Format(time.RFC3339Nano))
+		default:
+			dst.SetString(fmt.Sprint(v))
+		}
+		return nil
+	case reflect.Bool:
+		switch v := src.(type) {
+		case bool:
+			dst.SetBool(v)
+		case int64:
+			dst.SetBool(v != 0)
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return err
+			}
+			dst.SetBool(b)
+		default:
+			return fmt.Errorf("cannot assign %T to bool", src)
+		}
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := asInt64(src)
+		if err != nil {
+			return err
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := asInt64(src)
+		if err != nil {
+			return err
+		}
+		dst.SetUint(uint64(n))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch v := src.(type) {
+		case float64:
+			dst.SetFloat(v)
+		case int64:
+			dst.SetFloat(float64(v))
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return err
+			}
+			dst.SetFloat(f)
+		default:
+			return fmt.Errorf("cannot assign %T to float", src)
+		}
+		return nil
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().ConvertibleTo(dst.Type()) {
+		dst.Set(sv.Convert(dst.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot assign %T to %s", src, dst.Type())
+}
+
+func asInt64(src any) (int64, error) {
+	switch v := src.(type) {
+	case int64:
+		return v, nil
+	case float64:
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case time.Time:
+		return v.UnixMilli(), nil
+	}
+	return 0, fmt.Errorf("cannot assign %T to integer", src)
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func asTime(src any) (time.Time, error) {
+	switch v := src.(type) {
+	case time.Time:
+		return v, nil
+	case int64:
+		return time.UnixMilli(v), nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse time %q", v)
+	}
+	return time.Time{}, fmt.Errorf("cannot assign %T to time", src)
+}
+
+// parseFeedToken parses a feed page token into the Go type of col.
+func parseFeedToken(col *column, pageToken string) (any, error) {
+	if col.isJSON {
+		return nil, datax.NewValidationError(fmt.Sprintf("unsupported cursor column %s", col.name), nil, nil)
+	}
+	dst := reflect.New(col.typ).Elem()
+	var src any = pageToken
+	if col.typ == timeType {
+		t, err := time.Parse(time.RFC3339Nano, pageToken)
+		if err != nil {
+			return nil, datax.NewValidationError("parse time feed token failed", nil, err)
+		}
+		src = t
+	}
+	if err := (scanner{col: col, dst: dst}).Scan(src); err != nil {
+		return nil, datax.NewValidationError(fmt.Sprintf("parse feed token for %s failed", col.name), nil, err)
+	}
+	return normalizeArg(dst.Interface()), nil
+}
+
+// formatFeedToken formats the value of col in the struct v as a feed page token.
+func formatFeedToken(col *column, v reflect.Value) string {
+	value := v.FieldByIndex(col.index).Interface()
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(value)
+}
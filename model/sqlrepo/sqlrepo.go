@@ -0,0 +1,938 @@
+// Package sqlrepo provides a database/sql repository with the semantics of
+// mongox.CollectionLib: audit filling, data isolation, soft delete and
+// optimistic locking on updated_at.
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/pass"
+	"github.com/shiningrush/goext/gtx"
+	"github.com/shiningrush/goext/timex"
+)
+
+// Executor runs statements. It is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// PlaceholderStyle selects how bind parameters are written.
+type PlaceholderStyle int
+
+const (
+	// PlaceholderQuestion writes ? as used by SQLite and MySQL.
+	PlaceholderQuestion PlaceholderStyle = iota
+	// PlaceholderDollar writes $1, $2, ... as used by PostgreSQL.
+	PlaceholderDollar
+)
+
+// Options configures a TableLib.
+type Options struct {
+	// Placeholder is the bind parameter style. The default is PlaceholderQuestion.
+	Placeholder PlaceholderStyle
+	// ClassifyConflict reports whether err is a constraint violation by the
+	// error type of the driver, e.g. the SQLSTATE of a PostgreSQL error. ok is
+	// false when it does not recognize err; IsConstraintViolation is the
+	// fallback then, and when ClassifyConflict is nil.
+	ClassifyConflict func(err error) (conflict bool, ok bool)
+}
+
+// Filter matches columns by value. A key is a column name, optionally followed
+// by one of the operators =, !=, <, <=, > and >=, e.g. "rank >=". Slice values
+// match with IN and nil matches IS NULL.
+type Filter map[string]any
+
+// PageQueryInput wraps filter, pager, and sorter for paging queries.
+type PageQueryInput struct {
+	// Filter is the column filter.
+	Filter Filter
+	// Pager is the paging input.
+	Pager datax.PagerInfo
+	// Sort is the sorting input. Fields are column names.
+	Sort datax.SortInfo
+}
+
+// FeedQueryInput wraps filter, pager, and cursor column for feed queries.
+type FeedQueryInput struct {
+	// Filter is the column filter.
+	Filter Filter
+	// Pager is the feed paging input. PageSize is used; PageNum is ignored.
+	Pager datax.PagerInfo
+	// CursorField is the single column used as feed cursor. Defaults to the primary key.
+	CursorField string
+	// IsDescending controls cursor sort direction.
+	IsDescending bool
+}
+
+// PatchRawInput defines raw patch parameters.
+type PatchRawInput struct {
+	// Filter is the column filter for patch.
+	Filter Filter
+	// PatchPayload maps columns to new values; nil sets NULL.
+	PatchPayload map[string]any
+	// SkipInjectCond skips isolation and soft delete injection.
+	SkipInjectCond bool
+}
+
+// NewTableLib creates a SQL table helper for a given entity type. Columns are
+// derived from the gorm tags of T, see the model audit structs.
+func NewTableLib[P model.IDType, T model.EntityConstraint[P]](db Executor, table string) *TableLib[P, T] {
+	if db == nil {
+		panic("db should not be empty")
+	}
+	if !isIdentifier(table) {
+		panic(fmt.Sprintf("invalid table name %q", table))
+	}
+
+	s, err := parseSchema(reflect.TypeOf(gtx.Zero[T]()))
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return &TableLib[P, T]{
+		db:     db,
+		table:  table,
+		schema: s,
+		opt:    &model.RepoOpt{},
+	}
+}
+
+// TableLib is a SQL table helper with repo options.
+type TableLib[P model.IDType, T model.EntityConstraint[P]] struct {
+	db      Executor
+	table   string
+	schema  *schema
+	opt     *model.RepoOpt
+	options Options
+}
+
+// WithRepoOpt sets repo options on the table helper.
+func (l *TableLib[P, T]) WithRepoOpt(opt *model.RepoOpt) *TableLib[P, T] {
+	l.opt = opt
+	return l
+}
+
+// WithOptions sets SQL dialect options on the table helper.
+func (l *TableLib[P, T]) WithOptions(options Options) *TableLib[P, T] {
+	l.options = options
+	return l
+}
+
+// GetMergedRepoOpt merges context repo options with local options.
+func (l *TableLib[P, T]) GetMergedRepoOpt(ctx context.Context) *model.RepoOpt {
+	return model.CtxMergeRepoOpt(ctx, l.opt)
+}
+
+func (l *TableLib[P, T]) injectIsolationCond(ctx context.Context, filter Filter) (Filter, error) {
+	conds, err := model.IsolationConds(ctx, any(gtx.Zero[T]()), l.GetMergedRepoOpt(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for _, cond := range conds {
+		key := cond.Field
+		if cond.Deploy {
+			key = "run_context_deploy_" + key
+		}
+		filter[key] = cond.Value
+	}
+	return filter, nil
+}
+
+func (l *TableLib[P, T]) injectSoftDeleteCond(ctx context.Context, filter Filter) (Filter, error) {
+	opt := l.GetMergedRepoOpt(ctx)
+	if opt.SoftDelete == model.SoftDeleteDisable {
+		return filter, nil
+	}
+
+	if _, ok := any(gtx.Zero[T]()).(model.DeleteAuditor); ok {
+		filter["deleted_at"] = nil
+	}
+	return filter, nil
+}
+
+func (l *TableLib[P, T]) injectCond(ctx context.Context, filter Filter) (Filter, error) {
+	if filter == nil {
+		filter = Filter{}
+	}
+	filter, err := l.injectIsolationCond(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.injectSoftDeleteCond(ctx, filter)
+}
+
+// Find queries rows by filter.
+func (l *TableLib[P, T]) Find(ctx context.Context, filter Filter) (ret []T, err error) {
+	filter, err = l.injectCond(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := l.buildWhere(filter)
+	if err != nil {
+		return nil, err
+	}
+	return l.query(ctx, where+" ORDER BY "+l.schema.id.name, args)
+}
+
+// Count counts rows by filter with isolation and soft-delete rules.
+func (l *TableLib[P, T]) Count(ctx context.Context, filter Filter) (int64, error) {
+	filter, err := l.injectCond(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt, err := l.countRaw(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("count rows failed: %w", err)
+	}
+	return cnt, nil
+}
+
+// PageQuery performs a paged query with filter, sort, and paging input.
+func (l *TableLib[P, T]) PageQuery(ctx context.Context, input *PageQueryInput) (ret *model.PagedResult[T], err error) {
+	count, err := l.Count(ctx, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	input.Filter, err = l.injectCond(ctx, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := l.buildWhere(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	orderBy, err := l.buildOrderBy(input.Sort)
+	if err != nil {
+		return nil, err
+	}
+	tail := where + orderBy
+	pSize, pNum := 0, 0
+	if input.Pager != nil {
+		pSize, pNum, _ = input.Pager.GetPageInfo()
+	}
+	if pSize > 0 {
+		tail += " LIMIT ? OFFSET ?"
+		args = append(args, pSize, pSize*max(pNum-1, 0))
+	}
+
+	ret = &model.PagedResult[T]{TotalCount: int(count)}
+	ret.Rows, err = l.query(ctx, tail, args)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// FeedQuery performs a single-column cursor-based feed query without counting total rows.
+func (l *TableLib[P, T]) FeedQuery(ctx context.Context, input *FeedQueryInput) (ret *model.FeedResult[T], err error) {
+	pageSize, _, pageToken := 0, 0, ""
+	if input.Pager != nil {
+		pageSize, _, pageToken = input.Pager.GetPageInfo()
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	cursor := l.schema.id
+	if input.CursorField != "" && input.CursorField != "id" {
+		var ok bool
+		if cursor, ok = l.schema.byName[input.CursorField]; !ok {
+			return nil, datax.NewValidationError(fmt.Sprintf("cursor field %s not found", input.CursorField), nil, nil)
+		}
+	}
+
+	input.Filter, err = l.injectCond(ctx, input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := l.buildWhere(input.Filter)
+	if err != nil {
+		return nil, err
+	}
+	op, order := ">", " ASC"
+	if input.IsDescending {
+		op, order = "<", " DESC"
+	}
+	if pageToken != "" {
+		value, err := parseFeedToken(cursor, pageToken)
+		if err != nil {
+			return nil, fmt.Errorf("build feed cursor filter failed: %w", err)
+		}
+		if where == "" {
+			where = " WHERE "
+		} else {
+			where += " AND "
+		}
+		where += cursor.name + " " + op + " ?"
+		args = append(args, value)
+	}
+
+	rows, err := l.query(ctx, where+" ORDER BY "+cursor.name+order+" LIMIT ?", append(args, pageSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	ret = &model.FeedResult[T]{Rows: rows}
+	if len(rows) > pageSize {
+		ret.Rows = rows[:pageSize]
+		ret.NextPageToken = formatFeedToken(cursor, reflect.ValueOf(ret.Rows[len(ret.Rows)-1]).Elem())
+	}
+
+	return ret, nil
+}
+
+// Get fetches one row by id.
+func (l *TableLib[P, T]) Get(ctx context.Context, id P) (ret T, err error) {
+	return l.GetByFilter(ctx, Filter{l.schema.id.name: id})
+}
+
+// GetByFilter fetches the first row matching filter.
+func (l *TableLib[P, T]) GetByFilter(ctx context.Context, filter Filter) (ret T, err error) {
+	filter, err = l.injectCond(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	where, args, err := l.buildWhere(filter)
+	if err != nil {
+		return ret, err
+	}
+	rows, err := l.query(ctx, where+" LIMIT 1", args)
+	if err != nil {
+		return ret, err
+	}
+	if len(rows) == 0 {
+		return ret, datax.NewResourceNotFoundError(l.resourceByFilter(filter), nil)
+	}
+	return rows[0], nil
+}
+
+// Create inserts a new row with audit fields applied.
+func (l *TableLib[P, T]) Create(ctx context.Context, doc T) (T, error) {
+	if err := l.checkIfIDExisted(doc); err != nil {
+		return gtx.Zero[T](), err
+	}
+
+	if err := model.CtxCreateAudit(ctx, doc); err != nil {
+		return gtx.Zero[T](), fmt.Errorf("audit doc failed: %w", err)
+	}
+
+	if err := l.insert(ctx, l.db, doc); err != nil {
+		return gtx.Zero[T](), err
+	}
+	return doc, nil
+}
+
+func (l *TableLib[P, T]) checkIfIDExisted(doc T) error {
+	if gtx.IsZero(doc.GetID()) {
+		return datax.NewValidationError("id can not be empty", nil, nil)
+	}
+	return nil
+}
+
+func (l *TableLib[P, T]) insert(ctx context.Context, db Executor, doc T) error {
+	v := reflect.ValueOf(doc).Elem()
+	names := make([]string, 0, len(l.schema.columns))
+	args := make([]any, 0, len(l.schema.columns))
+	for _, col := range l.schema.columns {
+		value, err := col.value(v)
+		if err != nil {
+			return err
+		}
+		names = append(names, col.name)
+		args = append(args, value)
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", l.table, strings.Join(names, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", "))
+	if _, err := db.ExecContext(ctx, l.rebind(query), args...); err != nil {
+		if l.isConflict(err) {
+			return datax.NewResourceConflictError(l.resourceByDoc(doc), err)
+		}
+		return fmt.Errorf("create doc failed: %w", err)
+	}
+	return nil
+}
+
+// Update replaces a row, using optimistic checks when configured.
+func (l *TableLib[P, T]) Update(ctx context.Context, doc T) (ret T, err error) {
+	return l.commonReplace(ctx, doc, false)
+}
+
+// Upsert replaces or inserts a row.
+func (l *TableLib[P, T]) Upsert(ctx context.Context, doc T) (ret T, err error) {
+	return l.commonReplace(ctx, doc, true)
+}
+
+func (l *TableLib[P, T]) commonReplace(ctx context.Context, doc T, isUpsert bool) (ret T, err error) {
+	filter, err := l.auditAndBuildReplaceFilter(ctx, doc, isUpsert)
+	if err != nil {
+		return gtx.Zero[T](), err
+	}
+
+	v := reflect.ValueOf(doc).Elem()
+	payload := map[string]any{}
+	for _, col := range l.schema.columns {
+		if col == l.schema.id {
+			continue
+		}
+		if payload[col.name], err = col.value(v); err != nil {
+			return gtx.Zero[T](), err
+		}
+	}
+	affected, err := l.update(ctx, filter, payload)
+	if err != nil {
+		if l.isConflict(err) {
+			return gtx.Zero[T](), datax.NewResourceConflictError(l.resourceByDoc(doc), err)
+		}
+		return gtx.Zero[T](), fmt.Errorf("update doc failed: %w", err)
+	}
+	if affected > 0 {
+		return doc, nil
+	}
+
+	if isUpsert {
+		if err := l.insert(ctx, l.db, doc); err != nil {
+			return gtx.Zero[T](), err
+		}
+		return doc, nil
+	}
+
+	if _, ok := filter["updated_at"]; ok {
+		delete(filter, "updated_at")
+		cnt, err := l.countRaw(ctx, filter)
+		if err != nil {
+			return gtx.Zero[T](), fmt.Errorf("check id failed: %w", err)
+		}
+		if cnt > 0 {
+			return gtx.Zero[T](), datax.NewResourceError(datax.ErrCodeConflict, "data is modified by other", l.resourceByFilter(filter), nil)
+		}
+	}
+
+	return gtx.Zero[T](), datax.NewResourceNotFoundError(l.resourceByFilter(filter), nil)
+}
+
+func (l *TableLib[P, T]) auditAndBuildReplaceFilter(ctx context.Context, doc T, isUpsert bool) (Filter, error) {
+	if !isUpsert {
+		return l.baseUpdateOp(ctx, doc)
+	}
+
+	cr, ok := any(doc).(model.CreateAuditor)
+	if !ok {
+		return l.baseUpdateOp(ctx, doc)
+	}
+
+	if _, createTime := cr.GetCreatorInfo(); createTime.IsZero() {
+		if err := model.CtxCreateAudit(ctx, doc); err != nil {
+			return nil, err
+		}
+		return Filter{l.schema.id.name: doc.GetID()}, nil
+	}
+	return l.baseUpdateOp(ctx, doc)
+}
+
+func (l *TableLib[P, T]) baseUpdateOp(ctx context.Context, doc T) (Filter, error) {
+	ret, err := model.UpdateLockAndAudit(ctx, doc, l.GetMergedRepoOpt(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	filter := Filter{
+		l.schema.id.name: doc.GetID(),
+	}
+	if ret.HasOriginalUpdate {
+		filter["updated_at"] = ret.OriginalUpdatedAt
+	}
+	return l.injectCond(ctx, filter)
+}
+
+// Patch updates non-zero fields on a row by id.
+func (l *TableLib[P, T]) Patch(ctx context.Context, doc T) error {
+	filter, err := l.baseUpdateOp(ctx, doc)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(doc).Elem()
+	payload := map[string]any{}
+	for _, col := range l.schema.columns {
+		if col == l.schema.id || v.FieldByIndex(col.index).IsZero() {
+			continue
+		}
+		if payload[col.name], err = col.value(v); err != nil {
+			return err
+		}
+	}
+	return l.PatchRaw(ctx, &PatchRawInput{
+		Filter:         filter,
+		PatchPayload:   payload,
+		SkipInjectCond: true,
+	})
+}
+
+// PatchRaw updates the columns of PatchPayload on all rows matching the filter.
+func (l *TableLib[P, T]) PatchRaw(ctx context.Context, input *PatchRawInput) (err error) {
+	if !input.SkipInjectCond {
+		input.Filter, err = l.injectCond(ctx, input.Filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := any(gtx.Zero[T]()).(model.UpdateAuditor); ok {
+		u, hasUser := pass.CtxGetOperator(ctx)
+		if !hasUser {
+			return datax.NewValidationError("there is no user in context", nil, nil)
+		}
+		if input.PatchPayload == nil {
+			input.PatchPayload = map[string]any{}
+		}
+		input.PatchPayload["updated_at"] = l.auditTime("updated_at")
+		input.PatchPayload["updated_by"] = u
+	}
+	if len(input.PatchPayload) == 0 {
+		return nil
+	}
+
+	affected, err := l.update(ctx, input.Filter, input.PatchPayload)
+	if err != nil {
+		if l.isConflict(err) {
+			return datax.NewResourceConflictError(l.resourceByFilter(input.Filter), err)
+		}
+		return fmt.Errorf("patch doc failed: %w", err)
+	}
+	if affected == 0 {
+		return datax.NewResourceNotFoundError(l.resourceByFilter(input.Filter), nil)
+	}
+
+	return nil
+}
+
+// Delete deletes a row or applies soft delete when enabled.
+func (l *TableLib[P, T]) Delete(ctx context.Context, doc T) error {
+	hasDeleteAudit, err := model.CtxDeleteAudit(ctx, doc)
+	if err != nil {
+		return fmt.Errorf("audit doc failed: %w", err)
+	}
+
+	opt := l.GetMergedRepoOpt(ctx)
+	if hasDeleteAudit && opt.SoftDelete != model.SoftDeleteDisable {
+		_, err := l.Update(ctx, doc)
+		return err
+	}
+
+	filter, err := l.injectCond(ctx, Filter{l.schema.id.name: doc.GetID()})
+	if err != nil {
+		return err
+	}
+	deleted, err := l.delete(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return datax.NewResourceNotFoundError(l.resourceByDoc(doc), nil)
+	}
+
+	return nil
+}
+
+// BatchCreate inserts rows in one transaction when the executor can begin one.
+func (l *TableLib[P, T]) BatchCreate(ctx context.Context, docs []T) (err error) {
+	for _, doc := range docs {
+		if err := l.checkIfIDExisted(doc); err != nil {
+			return err
+		}
+
+		if err := model.CtxCreateAudit(ctx, doc); err != nil {
+			return fmt.Errorf("audit doc[%+v] failed: %w", doc, err)
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	db := l.db
+	if beginner, ok := l.db.(txBeginner); ok {
+		tx, beginErr := beginner.BeginTx(ctx, nil)
+		if beginErr != nil {
+			return fmt.Errorf("begin tx failed: %w", beginErr)
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback()
+			}
+		}()
+		db = tx
+	}
+
+	for _, doc := range docs {
+		if err := l.insert(ctx, db, doc); err != nil {
+			if datax.IsErrCode(datax.ErrCodeConflict, err) {
+				return datax.NewResourceConflictError(l.resourceByDocs(docs), err)
+			}
+			return err
+		}
+	}
+	if tx, ok := db.(*sql.Tx); ok {
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit tx failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// BatchUpdate updates rows one by one.
+func (l *TableLib[P, T]) BatchUpdate(ctx context.Context, docs []T) error {
+	for _, v := range docs {
+		if _, err := l.Update(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BatchDelete deletes rows by ids.
+func (l *TableLib[P, T]) BatchDelete(ctx context.Context, docs []T) (int, error) {
+	var ids []P
+	for _, v := range docs {
+		ids = append(ids, v.GetID())
+	}
+
+	return l.BatchDeleteByIDs(ctx, ids)
+}
+
+// BatchDeleteByIDs deletes rows by id list.
+func (l *TableLib[P, T]) BatchDeleteByIDs(ctx context.Context, ids []P) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	return l.BatchDeleteByFilter(ctx, Filter{l.schema.id.name: ids})
+}
+
+// BatchDeleteByFilter deletes rows by filter with isolation rules.
+func (l *TableLib[P, T]) BatchDeleteByFilter(ctx context.Context, filter Filter) (cnt int, err error) {
+	filter, err = l.injectCond(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	opt := l.GetMergedRepoOpt(ctx)
+	if _, ok := any(gtx.Zero[T]()).(model.DeleteAuditor); ok && opt.SoftDelete != model.SoftDeleteDisable {
+		u, ok := pass.CtxGetOperator(ctx)
+		if !ok {
+			return 0, datax.NewValidationError("there is no user", nil, nil)
+		}
+
+		affected, err := l.update(ctx, filter, map[string]any{
+			"deleted_at": l.auditTime("deleted_at"),
+			"deleted_by": u,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("batch delete rows failed: %w", err)
+		}
+		if affected == 0 {
+			return 0, datax.NewResourceNotFoundError(l.resourceByFilter(filter), nil)
+		}
+
+		return int(affected), nil
+	}
+
+	deleted, err := l.delete(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("batch delete rows failed: %w", err)
+	}
+	if deleted == 0 {
+		return 0, datax.NewResourceNotFoundError(l.resourceByFilter(filter), nil)
+	}
+
+	return int(deleted), nil
+}
+
+// auditTime returns the current time in the Go type of the audit column name.
+func (l *TableLib[P, T]) auditTime(name string) any {
+	now := timex.Now()
+	if col, ok := l.schema.byName[name]; ok && col.typ.Kind() == reflect.Int64 {
+		return now.UnixMilli()
+	}
+	return now
+}
+
+func (l *TableLib[P, T]) query(ctx context.Context, tail string, args []any) ([]T, error) {
+	names := make([]string, 0, len(l.schema.columns))
+	for _, col := range l.schema.columns {
+		names = append(names, col.name)
+	}
+	query := "SELECT " + strings.Join(names, ", ") + " FROM " + l.table + tail
+	rows, err := l.db.QueryContext(ctx, l.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query rows failed: %w", err)
+	}
+	defer rows.Close()
+
+	entityType := reflect.TypeOf(gtx.Zero[T]()).Elem()
+	var ret []T
+	for rows.Next() {
+		v := reflect.New(entityType)
+		dest := make([]any, 0, len(l.schema.columns))
+		for _, col := range l.schema.columns {
+			dest = append(dest, scanner{col: col, dst: v.Elem().FieldByIndex(col.index)})
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan row failed: %w", err)
+		}
+		ret = append(ret, v.Interface().(T))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows failed: %w", err)
+	}
+	return ret, nil
+}
+
+func (l *TableLib[P, T]) countRaw(ctx context.Context, filter Filter) (int64, error) {
+	where, args, err := l.buildWhere(filter)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := l.db.QueryContext(ctx, l.rebind("SELECT COUNT(*) FROM "+l.table+where), args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var cnt int64
+	if rows.Next() {
+		if err := rows.Scan(&cnt); err != nil {
+			return 0, err
+		}
+	}
+	return cnt, rows.Err()
+}
+
+func (l *TableLib[P, T]) update(ctx context.Context, filter Filter, payload map[string]any) (int64, error) {
+	names := make([]string, 0, len(payload))
+	for name := range payload {
+		if _, ok := l.schema.byName[name]; !ok {
+			return 0, datax.NewValidationError(fmt.Sprintf("unknown column %s", name), nil, nil)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sets := make([]string, 0, len(names))
+	args := make([]any, 0, len(names))
+	for _, name := range names {
+		sets = append(sets, name+" = ?")
+		args = append(args, normalizeArg(payload[name]))
+	}
+	where, whereArgs, err := l.buildWhere(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "UPDATE " + l.table + " SET " + strings.Join(sets, ", ") + where
+	ret, err := l.db.ExecContext(ctx, l.rebind(query), append(args, whereArgs...)...)
+	if err != nil {
+		return 0, err
+	}
+	return ret.RowsAffected()
+}
+
+func (l *TableLib[P, T]) delete(ctx context.Context, filter Filter) (int64, error) {
+	where, args, err := l.buildWhere(filter)
+	if err != nil {
+		return 0, err
+	}
+	ret, err := l.db.ExecContext(ctx, l.rebind("DELETE FROM "+l.table+where), args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete rows failed: %w", err)
+	}
+	return ret.RowsAffected()
+}
+
+var filterOps = map[string]bool{"=": true, "!=": true, "<>": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// buildWhere renders filter as a WHERE clause with ? placeholders. Keys are
+// sorted so that equal filters render equal statements.
+func (l *TableLib[P, T]) buildWhere(filter Filter) (string, []any, error) {
+	if len(filter) == 0 {
+		return "", nil, nil
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conds := make([]string, 0, len(keys))
+	var args []any
+	for _, key := range keys {
+		name, op, _ := strings.Cut(strings.TrimSpace(key), " ")
+		op = strings.TrimSpace(op)
+		if op == "" {
+			op = "="
+		}
+		if _, ok := l.schema.byName[name]; !ok {
+			return "", nil, datax.NewValidationError(fmt.Sprintf("unknown column %s", name), nil, nil)
+		}
+		if !filterOps[op] {
+			return "", nil, datax.NewValidationError(fmt.Sprintf("unsupported filter operator %s", op), nil, nil)
+		}
+
+		value := filter[key]
+		rv := reflect.ValueOf(value)
+		switch {
+		case value == nil && (op == "=" || op == "!=" || op == "<>"):
+			if op == "=" {
+				conds = append(conds, name+" IS NULL")
+			} else {
+				conds = append(conds, name+" IS NOT NULL")
+			}
+		case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8 && (op == "=" || op == "!=" || op == "<>"):
+			if rv.Len() == 0 {
+				if op == "=" {
+					conds = append(conds, "1 = 0")
+				}
+				continue
+			}
+			in := " IN ("
+			if op != "=" {
+				in = " NOT IN ("
+			}
+			conds = append(conds, name+in+strings.TrimSuffix(strings.Repeat("?, ", rv.Len()), ", ")+")")
+			for i := 0; i < rv.Len(); i++ {
+				args = append(args, normalizeArg(rv.Index(i).Interface()))
+			}
+		default:
+			conds = append(conds, name+" "+op+" ?")
+			args = append(args, normalizeArg(value))
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+func (l *TableLib[P, T]) buildOrderBy(sortInfo datax.SortInfo) (string, error) {
+	var items []string
+	hasID := false
+	if sortInfo != nil {
+		for _, v := range sortInfo.GetSortInfo() {
+			name := v.Field
+			if name == "id" {
+				name = l.schema.id.name
+			}
+			if _, ok := l.schema.byName[name]; !ok {
+				return "", datax.NewValidationError(fmt.Sprintf("unknown sort field %s", v.Field), nil, nil)
+			}
+			hasID = hasID || name == l.schema.id.name
+			if v.IsDescending {
+				name += " DESC"
+			}
+			items = append(items, name)
+		}
+	}
+
+	if len(items) == 0 {
+		if _, ok := any(gtx.Zero[T]()).(model.CreateAuditor); ok {
+			items = append(items, "created_at")
+		}
+	}
+	// The primary key breaks ties, so that pages never overlap.
+	if !hasID {
+		items = append(items, l.schema.id.name)
+	}
+	return " ORDER BY " + strings.Join(items, ", "), nil
+}
+
+func (l *TableLib[P, T]) rebind(query string) string {
+	if l.options.Placeholder != PlaceholderDollar {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (l *TableLib[P, T]) isConflict(err error) bool {
+	if l.options.ClassifyConflict != nil {
+		if conflict, ok := l.options.ClassifyConflict(err); ok {
+			return conflict
+		}
+	}
+	return IsConstraintViolation(err)
+}
+
+// IsConstraintViolation reports whether err is a unique, primary key or
+// foreign key violation reported by SQLite, MySQL or PostgreSQL. It matches
+// error messages, which drivers may word differently; prefer
+// Options.ClassifyConflict where the driver exposes error codes.
+func IsConstraintViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range []string{
+		"unique constraint",       // SQLite, PostgreSQL
+		"primary key constraint",  // SQLite
+		"foreign key constraint",  // SQLite, MySQL, PostgreSQL
+		"duplicate entry",         // MySQL 1062
+		"duplicate key value",     // PostgreSQL 23505
+		"violates unique",         // PostgreSQL
+		"constraint failed: uniq", // SQLite extended codes
+	} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *TableLib[P, T]) resourceByID(id any) string {
+	return fmt.Sprintf("%s/%v", l.table, id)
+}
+
+func (l *TableLib[P, T]) resourceByDoc(doc T) string {
+	return l.resourceByID(doc.GetID())
+}
+
+func (l *TableLib[P, T]) resourceByDocs(docs []T) string {
+	ids := make([]P, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.GetID())
+	}
+	return fmt.Sprintf("%s ids=%v", l.table, ids)
+}
+
+func (l *TableLib[P, T]) resourceByFilter(filter Filter) string {
+	if id, ok := filter[l.schema.id.name]; ok {
+		return l.resourceByID(id)
+	}
+	return fmt.Sprintf("%s filter=%v", l.table, filter)
+}
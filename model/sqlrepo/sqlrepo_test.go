@@ -0,0 +1,372 @@
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dev-ofa/core-go/model"
+	"github.com/dev-ofa/core-go/model/datax"
+	"github.com/dev-ofa/core-go/model/repotest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+func openDB(t *testing.T, ddl ...string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	// Every connection of :memory: is a new database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	for _, stmt := range ddl {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repos {
+		db := openDB(t,
+			`CREATE TABLE entities (
+				id TEXT PRIMARY KEY, created_at DATETIME, created_by TEXT, name TEXT,
+				updated_at DATETIME, updated_by TEXT, tenant_id TEXT, app_id TEXT)`,
+			`CREATE TABLE soft_entities (
+				id TEXT PRIMARY KEY, created_at DATETIME, created_by TEXT, name TEXT,
+				deleted_at DATETIME, deleted_by TEXT)`,
+			`CREATE TABLE deploy_entities (
+				id TEXT PRIMARY KEY, created_at DATETIME, created_by TEXT, name TEXT,
+				updated_at DATETIME, updated_by TEXT, run_context_deploy_env TEXT, run_context_deploy_cluster TEXT)`,
+		)
+		return repotest.Repos{
+			Repo:       NewTableLib[string, *repotest.Entity](db, "entities"),
+			SoftRepo:   NewTableLib[string, *repotest.SoftEntity](db, "soft_entities"),
+			DeployRepo: NewTableLib[string, *repotest.DeployEntity](db, "deploy_entities"),
+		}
+	})
+}
+
+type item struct {
+	model.Entity[model.SnowflakeID]
+	Name string   `gorm:"column:name;uniqueIndex"`
+	Rank int      `gorm:"column:rank"`
+	Tags []string `gorm:"column:tags"`
+
+	model.DeleteAudit `gorm:"embedded"`
+}
+
+const itemDDL = `CREATE TABLE items (
+	id INTEGER PRIMARY KEY, created_at DATETIME, created_by TEXT,
+	name TEXT UNIQUE, rank INTEGER, tags TEXT, deleted_at DATETIME, deleted_by TEXT)`
+
+func seed(t *testing.T) (*TableLib[model.SnowflakeID, *item], context.Context) {
+	t.Helper()
+	repo := NewTableLib[model.SnowflakeID, *item](openDB(t, itemDDL), "items")
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+	var docs []*item
+	for i := 1; i <= 5; i++ {
+		doc := &item{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(uint64(i))}, Name: "item-" + strconv.Itoa(i), Rank: 10 - i}
+		if i%2 == 0 {
+			doc.Tags = []string{"even"}
+		}
+		docs = append(docs, doc)
+	}
+	require.NoError(t, repo.BatchCreate(ctx, docs))
+	return repo, ctx
+}
+
+func TestFind(t *testing.T) {
+	repo, ctx := seed(t)
+
+	cases := []struct {
+		name   string
+		filter Filter
+		ids    []uint64
+	}{
+		{"all", nil, []uint64{1, 2, 3, 4, 5}},
+		{"equal", Filter{"name": "item-3"}, []uint64{3}},
+		{"range", Filter{"rank >=": 6, "rank <": 8}, []uint64{3, 4}},
+		{"in ids", Filter{"id": []model.SnowflakeID{model.NewSnowflakeID(1), model.NewSnowflakeID(5)}}, []uint64{1, 5}},
+		{"not in", Filter{"name !=": []string{"item-1", "item-2"}}, []uint64{3, 4, 5}},
+		{"empty in", Filter{"id": []model.SnowflakeID{}}, nil},
+		{"is null", Filter{"tags": nil}, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ret, err := repo.Find(ctx, c.filter)
+			require.NoError(t, err)
+			var ids []uint64
+			for _, v := range ret {
+				id, err := v.ID.Uint64()
+				require.NoError(t, err)
+				ids = append(ids, id)
+			}
+			require.Equal(t, c.ids, ids)
+		})
+	}
+
+	doc, err := repo.Get(ctx, model.NewSnowflakeID(2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"even"}, doc.Tags)
+	assert.Equal(t, "vinci", doc.CreatedBy)
+	assert.False(t, doc.CreatedAt.IsZero())
+
+	_, err = repo.Find(ctx, Filter{"name; DROP TABLE items": "x"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+	_, err = repo.Find(ctx, Filter{"name like": "x"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+
+	cnt, err := repo.BatchDeleteByFilter(ctx, Filter{"rank <": 7})
+	require.NoError(t, err)
+	require.Equal(t, 2, cnt)
+	total, err := repo.Count(ctx, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	total, err = repo.Count(model.SetCtxSoftDelete(ctx, model.SoftDeleteDisable), nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, total)
+}
+
+func TestPageQuery(t *testing.T) {
+	repo, ctx := seed(t)
+
+	ret, err := repo.PageQuery(ctx, &PageQueryInput{Pager: &model.Pager{PageSize: 2, PageNum: 2}})
+	require.NoError(t, err)
+	require.Equal(t, 5, ret.TotalCount)
+	require.Equal(t, []string{"item-3", "item-4"}, names(ret.Rows))
+
+	ret, err = repo.PageQuery(ctx, &PageQueryInput{
+		Filter: Filter{"rank >": 5},
+		Pager:  &model.Pager{},
+		Sort:   &datax.SortAble{OrderBy: "rank"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 4, ret.TotalCount)
+	require.Equal(t, []string{"item-4", "item-3", "item-2", "item-1"}, names(ret.Rows))
+
+	ret, err = repo.PageQuery(ctx, &PageQueryInput{
+		Pager: &model.Pager{PageSize: 2, PageNum: 3},
+		Sort:  &datax.SortAble{OrderBy: "name desc"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-1"}, names(ret.Rows))
+
+	_, err = repo.PageQuery(ctx, &PageQueryInput{Sort: &datax.SortAble{OrderBy: "rank; --"}})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestFeedQuery(t *testing.T) {
+	repo, ctx := seed(t)
+
+	var seen []string
+	pager := &model.Pager{PageSize: 2}
+	for {
+		ret, err := repo.FeedQuery(ctx, &FeedQueryInput{Pager: pager})
+		require.NoError(t, err)
+		seen = append(seen, names(ret.Rows)...)
+		if ret.NextPageToken == "" {
+			break
+		}
+		pager.PageToken = ret.NextPageToken
+	}
+	require.Equal(t, []string{"item-1", "item-2", "item-3", "item-4", "item-5"}, seen)
+
+	ret, err := repo.FeedQuery(ctx, &FeedQueryInput{
+		Pager:        &model.Pager{PageSize: 2},
+		CursorField:  "rank",
+		IsDescending: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-1", "item-2"}, names(ret.Rows))
+	require.Equal(t, "8", ret.NextPageToken)
+	ret, err = repo.FeedQuery(ctx, &FeedQueryInput{
+		Pager:        &model.Pager{PageSize: 2, PageToken: ret.NextPageToken},
+		CursorField:  "rank",
+		IsDescending: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"item-3", "item-4"}, names(ret.Rows))
+
+	_, err = repo.FeedQuery(ctx, &FeedQueryInput{Pager: &model.Pager{PageToken: "bad"}, CursorField: "rank"})
+	require.Equal(t, datax.ErrCodeValidate, datax.CodeOf(err))
+}
+
+func TestConstraintConflict(t *testing.T) {
+	repo, ctx := seed(t)
+
+	_, err := repo.Create(ctx, &item{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(9)}, Name: "item-1"})
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(err))
+
+	doc, err := repo.Get(ctx, model.NewSnowflakeID(2))
+	require.NoError(t, err)
+	doc.Name = "item-3"
+	_, err = repo.Update(ctx, doc)
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(err))
+
+	err = repo.BatchCreate(ctx, []*item{
+		{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(10)}, Name: "item-10"},
+		{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(11)}, Name: "item-10"},
+	})
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(err))
+	_, err = repo.Get(ctx, model.NewSnowflakeID(10))
+	require.Equal(t, datax.ErrCodeNotFound, datax.CodeOf(err), "the batch is rolled back")
+
+	custom := NewTableLib[model.SnowflakeID, *item](repo.db, "items").WithOptions(Options{
+		ClassifyConflict: func(err error) (bool, bool) { return false, true },
+	})
+	_, err = custom.Create(ctx, &item{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(12)}, Name: "item-1"})
+	require.Error(t, err)
+	require.NotEqual(t, datax.ErrCodeConflict, datax.CodeOf(err))
+}
+
+func TestClassifyConflict(t *testing.T) {
+	repo, ctx := seed(t)
+	recognized := 0
+	classified := NewTableLib[model.SnowflakeID, *item](repo.db, "items").WithOptions(Options{
+		ClassifyConflict: func(err error) (bool, bool) {
+			var sqliteErr *sqlite.Error
+			if !errors.As(err, &sqliteErr) {
+				return false, false
+			}
+			recognized++
+			code := sqliteErr.Code()
+			return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, true
+		},
+	})
+	_, err := classified.Create(ctx, &item{Entity: model.Entity[model.SnowflakeID]{ID: model.NewSnowflakeID(9)}, Name: "item-1"})
+	require.Equal(t, datax.ErrCodeConflict, datax.CodeOf(err))
+	require.Equal(t, 1, recognized)
+
+	assert.True(t, classified.isConflict(errors.New("UNIQUE constraint failed: items.name")),
+		"unrecognized errors fall back to the message match")
+
+	coded := NewTableLib[model.SnowflakeID, *item](repo.db, "items").WithOptions(Options{
+		ClassifyConflict: func(err error) (bool, bool) {
+			var codeErr sqlStateError
+			if !errors.As(err, &codeErr) {
+				return false, false
+			}
+			return codeErr == "23505", true
+		},
+	})
+	assert.True(t, coded.isConflict(fmt.Errorf("insert failed: %w", sqlStateError("23505"))))
+	assert.False(t, coded.isConflict(sqlStateError("42P01")))
+}
+
+// sqlStateError is a driver error whose message does not name the violation.
+type sqlStateError string
+
+func (e sqlStateError) Error() string { return "sql error " + string(e) }
+
+func TestIsConstraintViolation(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"UNIQUE constraint failed: items.name":                                         true,
+		"Error 1062 (23000): Duplicate entry 'a' for key 'name'":                       true,
+		`pq: duplicate key value violates unique constraint "items_pkey"`:              true,
+		"FOREIGN KEY constraint failed":                                                true,
+		"no such table: items":                                                         false,
+		"Error 1452: Cannot add or update a child row: a foreign key constraint fails": true,
+	} {
+		assert.Equal(t, want, IsConstraintViolation(errors.New(msg)), msg)
+	}
+	assert.False(t, IsConstraintViolation(nil))
+}
+
+func TestRebind(t *testing.T) {
+	repo := NewTableLib[model.SnowflakeID, *item](openDB(t), "items").WithOptions(Options{Placeholder: PlaceholderDollar})
+	assert.Equal(t, "UPDATE items SET name = $1 WHERE id = $2", repo.rebind("UPDATE items SET name = ? WHERE id = ?"))
+}
+
+func TestParseSchema(t *testing.T) {
+	type embedded struct {
+		Street string
+		City   string `gorm:"column:town"`
+	}
+	type row struct {
+		Key      string `gorm:"primaryKey;type:varchar(64)"`
+		TenantID string
+		HTTPCode int
+		Ignored  string   `gorm:"-"`
+		Addr     embedded `gorm:"embedded;embeddedPrefix:addr_"`
+		Extra    map[string]string
+		internal string
+	}
+
+	s, err := parseSchema(reflect.TypeOf(&row{}))
+	require.NoError(t, err)
+	var cols []string
+	for _, c := range s.columns {
+		cols = append(cols, c.name)
+	}
+	assert.Equal(t, []string{"key", "tenant_id", "http_code", "addr_street", "addr_town", "extra"}, cols)
+	assert.Equal(t, "key", s.id.name)
+	assert.True(t, s.byName["extra"].isJSON)
+
+	_, err = parseSchema(reflect.TypeOf(struct{ Name string }{}))
+	assert.Error(t, err)
+}
+
+type nullableItem struct {
+	model.Entity[string]
+	Note   *string    `gorm:"column:note"`
+	Count  *int       `gorm:"column:count"`
+	SeenAt *time.Time `gorm:"column:seen_at"`
+	Labels *[]string  `gorm:"column:labels"`
+}
+
+func TestPointerColumnsRoundTrip(t *testing.T) {
+	repo := NewTableLib[string, *nullableItem](openDB(t, `CREATE TABLE nullable_items (
+		id TEXT PRIMARY KEY, created_at DATETIME, created_by TEXT,
+		note TEXT, count INTEGER, seen_at DATETIME, labels TEXT)`), "nullable_items")
+	ctx := model.GenUserInfoContext(model.NewByteReqInfo())
+
+	note, count, labels := "hello", 3, []string{"a"}
+	seenAt := time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+	_, err := repo.Create(ctx, &nullableItem{
+		Entity: model.Entity[string]{ID: "set"},
+		Note:   &note, Count: &count, SeenAt: &seenAt, Labels: &labels,
+	})
+	require.NoError(t, err)
+	_, err = repo.Create(ctx, &nullableItem{Entity: model.Entity[string]{ID: "unset"}})
+	require.NoError(t, err)
+
+	got, err := repo.Get(ctx, "set")
+	require.NoError(t, err)
+	require.NotNil(t, got.Note)
+	assert.Equal(t, note, *got.Note)
+	require.NotNil(t, got.Count)
+	assert.Equal(t, count, *got.Count)
+	require.NotNil(t, got.SeenAt)
+	assert.True(t, seenAt.Truncate(time.Millisecond).Equal(*got.SeenAt))
+	require.NotNil(t, got.Labels)
+	assert.Equal(t, labels, *got.Labels)
+
+	got, err = repo.Get(ctx, "unset")
+	require.NoError(t, err)
+	assert.Nil(t, got.Note)
+	assert.Nil(t, got.Count)
+	assert.Nil(t, got.SeenAt)
+	assert.Nil(t, got.Labels)
+
+	rows, err := repo.Find(ctx, Filter{"count": 3})
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "set", rows[0].ID)
+}
+
+func names(rows []*item) []string {
+	var ret []string
+	for _, v := range rows {
+		ret = append(ret, v.Name)
+	}
+	return ret
+}
@@ -210,6 +210,10 @@ const (
 	FixedStrategyNone FixedStrategy = "none"
 	// FixedStrategyBackoff uses backoff to fix sync delay.
 	FixedStrategyBackoff FixedStrategy = "backoff"
+	// FixedStrategyPrimary reads from the primary. It is opt-in: writes do not
+	// set it, so set it with SetCtxFixedStrategy on the context of reads that
+	// must see a preceding write, or use a causally consistent session.
+	FixedStrategyPrimary FixedStrategy = "primary"
 )
 
 // UpdateRunContext defines when audit updates are applied.